@@ -2,12 +2,18 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"math/rand/v2"
+	"os"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/we11adam/uddns/internal/state"
 	"github.com/we11adam/uddns/notifier"
 	"github.com/we11adam/uddns/provider"
 	"github.com/we11adam/uddns/updater"
@@ -29,12 +35,23 @@ func (systemClock) Now() time.Time {
 }
 
 type App struct {
-	jobs         []Job
-	notifierName string
-	notifier     notifier.Notifier
-	interval     time.Duration
-	clock        clock
-	jitter       func() float64
+	jobs           []Job
+	notifiers      []notifier.Route
+	interval       time.Duration
+	maxConcurrency int
+	clock          clock
+	jitter         func() float64
+	stateStore     StateStore
+	savedState     map[string]state.Job
+	stateLoadErr   error
+	changes        *providerChanges
+	changeDebounce time.Duration
+	watchers       map[provider.Provider]context.CancelFunc
+	reloads        chan *App
+	statuses       *statusBoard
+	metrics        *appMetrics
+	dryRun         bool
+	hostname       string
 }
 
 type Job struct {
@@ -47,15 +64,31 @@ type Job struct {
 	Zone                      string
 	Families                  Families
 	Verify                    VerifyMode
+	Interval                  time.Duration
 	lastAppliedIPv4           string
 	lastAppliedIPv6           string
 	lastNotifiedIPv4          string
 	lastNotifiedIPv6          string
 	lastNotifiedUpdateFailure string
-	lastVerifiedAt            time.Time
-	recordDriftPending        bool
-	failureCount              int
-	retryAfter                time.Time
+	// pendingNotifications tracks which routes already received a
+	// notification that is retried until every route has it, keyed by
+	// reason and family.
+	pendingNotifications map[string]*pendingNotification
+	lastVerifiedAt       time.Time
+	recordDriftPending   bool
+	failureCount         int
+	retryAfter           time.Time
+	nextRunAt            time.Time
+}
+
+// pendingNotification is a notification that not every route has received
+// yet.
+type pendingNotification struct {
+	message string
+	// time is the event time of the first attempt. Retries reuse it, so a
+	// notifier can recognize a notification it already delivered.
+	time      time.Time
+	delivered map[string]struct{}
 }
 
 type jobStatus string
@@ -122,76 +155,220 @@ func NewApp(jobs []Job, notifierName string, n notifier.Notifier, interval time.
 		}
 	}
 	return &App{
-		jobs:         jobs,
-		notifierName: notifierName,
-		notifier:     n,
-		interval:     interval,
-		clock:        systemClock{},
-		jitter:       rand.Float64,
+		jobs:           jobs,
+		notifiers:      []notifier.Route{{Name: notifierName, Notifier: n}},
+		interval:       interval,
+		maxConcurrency: 1,
+		clock:          systemClock{},
+		jitter:         rand.Float64,
+		changes:        newProviderChanges(),
+		changeDebounce: changeDebounce,
+		watchers:       map[provider.Provider]context.CancelFunc{},
+		reloads:        make(chan *App),
+		statuses:       newStatusBoard(jobs),
+		metrics:        newAppMetrics(),
+		hostname:       hostname(),
 	}
 }
 
-func (a *App) schedule(ctx context.Context) {
-	duration := a.interval
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+	return name
+}
+
+// SetNotifiers replaces the notifier given to NewApp with several routes.
+// Each notification is delivered concurrently to every route that accepts it.
+// It must be called before Run.
+func (a *App) SetNotifiers(routes []notifier.Route) {
+	if len(routes) > 0 {
+		a.notifiers = routes
+	}
+}
 
+// SetMaxConcurrency bounds how many jobs run at the same time. Values below one
+// run jobs sequentially. It must be called before Run.
+func (a *App) SetMaxConcurrency(n int) {
+	a.maxConcurrency = max(n, 1)
+}
+
+// schedule runs each job on its own interval. After every pass it sleeps
+// until the earliest job is due again, either because its interval elapsed or
+// because its failure backoff expired. Provider change events wake it early,
+// after a short debounce, to run only the affected jobs. Reloads are applied
+// between passes, so no job is running while the job list is swapped.
+func (a *App) schedule(ctx context.Context) {
 	slog.Info(
 		"starting scheduler",
-		"interval", duration,
-		"notifier", a.notifierName,
+		"interval", a.interval,
+		"notifiers", a.notifierNames(),
 		"jobs", len(a.jobs),
+		"max_concurrency", a.maxConcurrency,
 	)
 
-	ticker := time.NewTicker(duration)
-	defer ticker.Stop()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	debounce := time.NewTimer(a.changeDebounce)
+	debounce.Stop()
+	defer debounce.Stop()
+	debouncing := false
 
 	for {
 		if ctx.Err() != nil {
 			slog.Info("scheduler stopped", "reason", ctx.Err())
 			return
 		}
-
-		a.runOnce(ctx)
 		select {
 		case <-ctx.Done():
 			slog.Info("scheduler stopped", "reason", ctx.Err())
 			return
-		case <-ticker.C:
+		case <-a.changes.ready:
+			if !debouncing {
+				debouncing = true
+				debounce.Reset(a.changeDebounce)
+			}
+			continue
+		case <-debounce.C:
+			debouncing = false
+			a.runChanged(ctx, a.changes.take())
+		case next := <-a.reloads:
+			a.applyReload(ctx, next)
+		case <-timer.C:
+			a.runDue(ctx)
+		}
+
+		a.saveState()
+		wait := a.untilNextRun(a.clock.Now())
+		slog.Debug("next scheduler wakeup", "after", wait)
+		timer.Reset(wait)
+	}
+}
+
+// runDue runs the jobs whose next scheduled run has arrived and advances
+// their schedules.
+func (a *App) runDue(ctx context.Context) {
+	now := a.clock.Now()
+	var due []*Job
+	for i := range a.jobs {
+		job := &a.jobs[i]
+		if now.Before(job.nextRunAt) || a.backingOff(job, now) {
+			continue
 		}
+		job.nextRunAt = job.followingRun(now, a.jobInterval(job))
+		due = append(due, job)
 	}
+	a.runJobs(ctx, due)
 }
 
+// runOnce runs every job that is not backing off, regardless of its schedule.
 func (a *App) runOnce(ctx context.Context) {
+	now := a.clock.Now()
+	var due []*Job
 	for i := range a.jobs {
+		job := &a.jobs[i]
+		if a.backingOff(job, now) {
+			continue
+		}
+		due = append(due, job)
+	}
+	a.runJobs(ctx, due)
+}
+
+func (a *App) backingOff(job *Job, now time.Time) bool {
+	if !now.Before(job.retryAfter) {
+		return false
+	}
+	slog.Debug(
+		"skipping job during failure backoff",
+		job.logAttrs(
+			"failure_count", job.failureCount,
+			"retry_after", job.retryAfter,
+			"remaining", job.retryAfter.Sub(now),
+		)...,
+	)
+	return true
+}
+
+// untilNextRun returns how long the scheduler can sleep before some job is due.
+func (a *App) untilNextRun(now time.Time) time.Duration {
+	if len(a.jobs) == 0 {
+		return a.interval
+	}
+	var next time.Time
+	for i := range a.jobs {
+		job := &a.jobs[i]
+		at := job.nextRunAt
+		if job.retryAfter.After(at) {
+			at = job.retryAfter
+		}
+		if i == 0 || at.Before(next) {
+			next = at
+		}
+	}
+	return max(next.Sub(now), 0)
+}
+
+// jobInterval returns the job's own interval, or the app interval when the job
+// does not set one.
+func (a *App) jobInterval(job *Job) time.Duration {
+	if job.Interval > 0 {
+		return job.Interval
+	}
+	return a.interval
+}
+
+// followingRun keeps a job on a fixed cadence like a ticker would. If the job
+// fell more than one interval behind, the cadence restarts from now.
+func (job *Job) followingRun(now time.Time, interval time.Duration) time.Time {
+	next := job.nextRunAt.Add(interval)
+	if job.nextRunAt.IsZero() || !next.After(now) {
+		return now.Add(interval)
+	}
+	return next
+}
+
+// runJobs runs the given jobs with at most maxConcurrency in flight and returns
+// when all started jobs have finished. Passes never overlap, so neither does a
+// job with itself. Jobs that share a provider and address families share one
+// provider lookup for the pass.
+func (a *App) runJobs(ctx context.Context, jobs []*Job) {
+	lookups := newProviderLookups(a.metrics)
+	workers := make(chan struct{}, max(a.maxConcurrency, 1))
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for _, job := range jobs {
 		if ctx.Err() != nil {
 			return
 		}
-		now := a.clock.Now()
-		job := &a.jobs[i]
-		if now.Before(job.retryAfter) {
-			slog.Debug(
-				"skipping job during failure backoff",
-				job.logAttrs(
-					"failure_count", job.failureCount,
-					"retry_after", job.retryAfter,
-					"remaining", job.retryAfter.Sub(now),
-				)...,
-			)
-			continue
+		select {
+		case workers <- struct{}{}:
+		case <-ctx.Done():
+			return
 		}
-		a.runJob(ctx, job)
+		wg.Go(func() {
+			defer func() { <-workers }()
+			a.runJob(ctx, job, lookups)
+		})
 	}
 }
 
-func (a *App) runJob(ctx context.Context, job *Job) {
+func (a *App) runJob(ctx context.Context, job *Job, lookups *providerLookups) {
 	startedAt := time.Now()
 	status := jobStatusOK
 	updated := false
+	var detected *provider.IpResult
+	var planned []RecordChange
+	var runErr error
 	defer func() {
 		if isBackoffFailure(status) {
 			// Shutdown cancellation is not an operational job failure and should
 			// not affect the next run of the same App.
 			if ctx.Err() == nil {
-				job.recordFailure(a.clock.Now(), a.interval, jobBackoffCap(a.interval), a.jitter())
+				interval := a.jobInterval(job)
+				job.recordFailure(a.clock.Now(), interval, jobBackoffCap(interval), a.jitter())
 			}
 		} else if status == jobStatusOK || status == jobStatusUnchanged {
 			job.resetBackoff()
@@ -206,6 +383,16 @@ func (a *App) runJob(ctx context.Context, job *Job) {
 				"retry_after", job.retryAfter,
 			)...,
 		)
+		if ctx.Err() == nil || !isBackoffFailure(status) {
+			a.metrics.cycles.Inc(job.Name, string(status))
+		}
+		a.publishStatus(job, runOutcome{
+			status:   status,
+			updated:  updated,
+			detected: detected,
+			planned:  planned,
+			err:      runErr,
+		})
 	}()
 
 	slog.Debug(
@@ -218,26 +405,24 @@ func (a *App) runJob(ctx context.Context, job *Job) {
 		)...,
 	)
 
-	ipResult, err := job.Provider.GetIPs(ctx, provider.FamilyRequest{
-		IPv4: job.Families.IPv4,
-		IPv6: job.Families.IPv6,
-	})
+	ipResult, err := lookups.getIPs(ctx, job)
 	if err != nil {
-		status = jobStatusProviderError
+		status, runErr = jobStatusProviderError, err
 		slog.Error("failed to get IP addresses", job.logAttrs("error", err)...)
 		return
 	}
 	if ipResult == nil {
-		status = jobStatusProviderError
+		status, runErr = jobStatusProviderError, errors.New("provider returned no IP result")
 		slog.Error("provider returned no IP result", job.logAttrs()...)
 		return
 	}
 	ipResult = filterFamilies(ipResult, job.Families)
 	if err := ipResult.Validate(); err != nil {
-		status = jobStatusProviderError
+		status, runErr = jobStatusProviderError, err
 		slog.Error("provider returned invalid IP result", job.logAttrs("error", err)...)
 		return
 	}
+	detected = ipResult
 
 	ipv4Changed := ipResult.IPv4 != "" && ipResult.IPv4 != job.lastAppliedIPv4
 	ipv6Changed := ipResult.IPv6 != "" && ipResult.IPv6 != job.lastAppliedIPv6
@@ -249,11 +434,13 @@ func (a *App) runJob(ctx context.Context, job *Job) {
 	updateNeeded := providerIPChanged || recordChanged
 	var currentIPResult *provider.IpResult
 
-	if job.shouldReadCurrentRecords(a.clock.Now(), providerIPChanged) {
+	if a.readsCurrentRecords(job, providerIPChanged) {
+		readStartedAt := time.Now()
 		currentIPResult, err = job.currentRecordIPs(ctx)
+		a.metrics.updaterDuration.Observe(time.Since(readStartedAt).Seconds(), job.Name, job.UpdaterName, "current", callResult(err))
 		if err != nil {
 			if job.Verify == VerifyUpdaterAPI {
-				status = jobStatusVerifyError
+				status, runErr = jobStatusVerifyError, err
 				slog.Error("failed to verify current DNS records", job.logAttrs("verify", job.Verify, "error", err)...)
 				return
 			}
@@ -321,8 +508,16 @@ func (a *App) runJob(ctx context.Context, job *Job) {
 		)...,
 	)
 
-	if err := job.Updater.Update(ctx, ipResult); err != nil {
-		status = jobStatusUpdaterError
+	if a.dryRun {
+		planned = a.planUpdate(job, ipResult, currentIPResult)
+		return
+	}
+
+	updateStartedAt := time.Now()
+	err = job.Updater.Update(ctx, ipResult)
+	a.metrics.updaterDuration.Observe(time.Since(updateStartedAt).Seconds(), job.Name, job.UpdaterName, "update", callResult(err))
+	if err != nil {
+		status, runErr = jobStatusUpdaterError, err
 		slog.Error(
 			"failed to update DNS records",
 			job.logAttrs(
@@ -331,14 +526,28 @@ func (a *App) runJob(ctx context.Context, job *Job) {
 				"error", err,
 			)...,
 		)
-		failureNotification := notifier.Notification{Message: jobNotificationMessage(job, fmt.Sprintf("DNS update failed for %s: %s", notificationIPSummary(ipResult), err)), Reason: notifier.ReasonUpdateFailure}
-		if failureNotification.Message != job.lastNotifiedUpdateFailure && a.notify(ctx, job, failureNotification) {
+		failureNotification := a.notification(
+			job,
+			notifier.ReasonUpdateFailure,
+			fmt.Sprintf("DNS update failed for %s: %s", notificationIPSummary(ipResult), err),
+			job.appliedIPs(),
+			ipResult,
+			err,
+		)
+		if failureNotification.Message != job.lastNotifiedUpdateFailure && a.notifyPending(ctx, job, failureNotification) {
 			job.lastNotifiedUpdateFailure = failureNotification.Message
 		}
 		return
 	}
 
 	updated = true
+	previous := job.appliedIPs()
+	if ipResult.IPv4 != "" && ipResult.IPv4 != job.lastAppliedIPv4 {
+		a.metrics.ipChanges.Inc(job.Name, "ipv4")
+	}
+	if ipResult.IPv6 != "" && ipResult.IPv6 != job.lastAppliedIPv6 {
+		a.metrics.ipChanges.Inc(job.Name, "ipv6")
+	}
 	if ipResult.IPv4 != "" {
 		job.lastAppliedIPv4 = ipResult.IPv4
 	}
@@ -347,6 +556,7 @@ func (a *App) runJob(ctx context.Context, job *Job) {
 	}
 	job.recordDriftPending = false
 	job.lastNotifiedUpdateFailure = ""
+	delete(job.pendingNotifications, pendingNotificationKey(notifier.ReasonUpdateFailure, ""))
 
 	slog.Info(
 		"updated DNS records",
@@ -356,23 +566,81 @@ func (a *App) runJob(ctx context.Context, job *Job) {
 		)...,
 	)
 	a.notifyIPChanges(ctx, job, ipResult)
-	a.notify(ctx, job, notifier.Notification{Message: jobNotificationMessage(job, fmt.Sprintf("DNS records updated for %s", notificationIPSummary(ipResult))), Reason: notifier.ReasonUpdateSuccess})
+	a.notify(ctx, job, a.notification(
+		job,
+		notifier.ReasonUpdateSuccess,
+		fmt.Sprintf("DNS records updated for %s", notificationIPSummary(ipResult)),
+		previous,
+		ipResult,
+		nil,
+	))
 }
 
 func (a *App) notifyIPChanges(ctx context.Context, job *Job, ipResult *provider.IpResult) {
 	if ipResult.IPv4 != "" && ipResult.IPv4 != job.lastNotifiedIPv4 {
-		if a.notify(ctx, job, notifier.Notification{Message: jobNotificationMessage(job, fmt.Sprintf("IPv4 address changed to %s", ipResult.IPv4)), Reason: notifier.ReasonIPChange}) {
+		notification := a.notification(
+			job,
+			notifier.ReasonIPChange,
+			fmt.Sprintf("IPv4 address changed to %s", ipResult.IPv4),
+			&provider.IpResult{IPv4: job.lastNotifiedIPv4},
+			&provider.IpResult{IPv4: ipResult.IPv4},
+			nil,
+		)
+		notification.Event.Family = "ipv4"
+		if a.notifyPending(ctx, job, notification) {
 			job.lastNotifiedIPv4 = ipResult.IPv4
 		}
 	}
 
 	if ipResult.IPv6 != "" && ipResult.IPv6 != job.lastNotifiedIPv6 {
-		if a.notify(ctx, job, notifier.Notification{Message: jobNotificationMessage(job, fmt.Sprintf("IPv6 address changed to %s", ipResult.IPv6)), Reason: notifier.ReasonIPChange}) {
+		notification := a.notification(
+			job,
+			notifier.ReasonIPChange,
+			fmt.Sprintf("IPv6 address changed to %s", ipResult.IPv6),
+			&provider.IpResult{IPv6: job.lastNotifiedIPv6},
+			&provider.IpResult{IPv6: ipResult.IPv6},
+			nil,
+		)
+		notification.Event.Family = "ipv6"
+		if a.notifyPending(ctx, job, notification) {
 			job.lastNotifiedIPv6 = ipResult.IPv6
 		}
 	}
 }
 
+// notification builds a notification with its default message and the event
+// that templates can use to replace it.
+func (a *App) notification(job *Job, reason notifier.Reason, message string, previous, current *provider.IpResult, err error) notifier.Notification {
+	event := notifier.Event{
+		Reason:   reason,
+		Job:      job.Name,
+		Record:   job.Record,
+		Zone:     job.Zone,
+		Provider: job.ProviderName,
+		Updater:  job.UpdaterName,
+		OldIPv4:  ipResultValue(previous, "ipv4"),
+		NewIPv4:  ipResultValue(current, "ipv4"),
+		OldIPv6:  ipResultValue(previous, "ipv6"),
+		NewIPv6:  ipResultValue(current, "ipv6"),
+		Time:     a.clock.Now(),
+		Hostname: a.hostname,
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	event.Message = jobNotificationMessage(job, message)
+	return notifier.Notification{
+		Message: event.Message,
+		Reason:  reason,
+		Job:     job.Name,
+		Event:   event,
+	}
+}
+
+func (job *Job) appliedIPs() *provider.IpResult {
+	return &provider.IpResult{IPv4: job.lastAppliedIPv4, IPv6: job.lastAppliedIPv6}
+}
+
 func isBackoffFailure(status jobStatus) bool {
 	switch status {
 	case jobStatusProviderError, jobStatusVerifyError, jobStatusUpdaterError:
@@ -564,19 +832,137 @@ func (job *Job) logAttrs(args ...any) []any {
 	return append(attrs, args...)
 }
 
+// notify delivers a notification to every notifier route that accepts it,
+// concurrently so that a slow or failing notifier does not hold up the others.
+// It reports whether every route received it, or none wanted it.
 func (a *App) notify(ctx context.Context, job *Job, notification notifier.Notification) bool {
-	if err := a.notifier.Notify(ctx, notification); err != nil {
+	return a.notifyRoutes(ctx, job, notification, nil)
+}
+
+// notifyPending delivers a notification that the caller retries until it
+// reports true. Routes that received it on an earlier attempt are skipped, so
+// a failing route does not cause duplicates on the others.
+func (a *App) notifyPending(ctx context.Context, job *Job, notification notifier.Notification) bool {
+	key := pendingNotificationKey(notification.Reason, notification.Event.Family)
+	pending := job.pendingNotifications[key]
+	if pending == nil || pending.message != notification.Message {
+		pending = &pendingNotification{message: notification.Message, time: notification.Event.Time, delivered: map[string]struct{}{}}
+	}
+	notification.Event.Time = pending.time
+	if a.notifyRoutes(ctx, job, notification, pending.delivered) {
+		delete(job.pendingNotifications, key)
+		return true
+	}
+	if job.pendingNotifications == nil {
+		job.pendingNotifications = make(map[string]*pendingNotification)
+	}
+	job.pendingNotifications[key] = pending
+	return false
+}
+
+func pendingNotificationKey(reason notifier.Reason, family string) string {
+	return string(reason) + "/" + family
+}
+
+// notifyRoutes sends the notification to the accepting routes that are not in
+// delivered and adds the ones that succeed to it when it is not nil.
+func (a *App) notifyRoutes(ctx context.Context, job *Job, notification notifier.Notification, delivered map[string]struct{}) bool {
+	var routes []notifier.Route
+	for _, route := range a.notifiers {
+		if _, ok := delivered[route.Name]; ok {
+			continue
+		}
+		if route.Accepts(notification) {
+			routes = append(routes, route)
+		}
+	}
+	if len(routes) == 0 {
+		return true
+	}
+	if a.dryRun {
+		for _, route := range routes {
+			a.logSkippedNotification(job, route, a.render(job, route, notification))
+		}
+		return true
+	}
+
+	errs := make([]error, len(routes))
+	var wg sync.WaitGroup
+	for i, route := range routes {
+		wg.Go(func() {
+			errs[i] = a.deliver(ctx, job, route, notification)
+		})
+	}
+	wg.Wait()
+	ok := true
+	for i, err := range errs {
+		if err != nil {
+			ok = false
+			continue
+		}
+		if delivered != nil {
+			delivered[routes[i].Name] = struct{}{}
+		}
+	}
+	return ok
+}
+
+func (a *App) deliver(ctx context.Context, job *Job, route notifier.Route, notification notifier.Notification) error {
+	notification = a.render(job, route, notification)
+	startedAt := time.Now()
+	err := route.Notifier.Notify(ctx, notification)
+	a.metrics.notifierDuration.Observe(time.Since(startedAt).Seconds(), route.Name, callResult(err))
+	if err != nil {
 		slog.Error(
 			"failed to send notification",
 			job.logAttrs(
-				"notifier", a.notifierName,
+				"notifier", route.Name,
 				"reason", notification.Reason,
 				"error", err,
 			)...,
 		)
-		return false
 	}
-	return true
+	return err
+}
+
+// render applies the route's templates. A template that fails at delivery
+// time falls back to the default text rather than losing the notification.
+func (a *App) render(job *Job, route notifier.Route, notification notifier.Notification) notifier.Notification {
+	rendered, err := route.Render(notification)
+	if err != nil {
+		slog.Warn(
+			"failed to render notification template; sending default message",
+			job.logAttrs(
+				"notifier", route.Name,
+				"reason", notification.Reason,
+				"error", err,
+			)...,
+		)
+		return notification
+	}
+	return rendered
+}
+
+// closeNotifiers closes the notifiers that hold connections, such as MQTT,
+// unless they are still used by one of the routes in keep.
+func (a *App) closeNotifiers(keep []notifier.Route) {
+	for _, route := range a.notifiers {
+		closer, ok := route.Notifier.(io.Closer)
+		if !ok || slices.ContainsFunc(keep, func(kept notifier.Route) bool { return kept.Notifier == route.Notifier }) {
+			continue
+		}
+		if err := closer.Close(); err != nil {
+			slog.Warn("failed to close notifier", "notifier", route.Name, "error", err)
+		}
+	}
+}
+
+func (a *App) notifierNames() []string {
+	names := make([]string, len(a.notifiers))
+	for i, route := range a.notifiers {
+		names[i] = route.Name
+	}
+	return names
 }
 
 func notificationIPSummary(ipResult *provider.IpResult) string {
@@ -597,9 +983,76 @@ func notificationIPSummary(ipResult *provider.IpResult) string {
 	return strings.Join(parts, ", ")
 }
 
+// RunOnce runs the named jobs, or every job when no names are given, exactly
+// once and returns their resulting status. Failure backoff is not applied
+// because the caller decides when to run again, but it is still recorded in
+// persisted state. Unknown job names are an error, and so is a ctx canceled
+// before the run finished, in which case the statuses are still returned.
+func (a *App) RunOnce(ctx context.Context, names ...string) ([]JobStatus, error) {
+	jobs, err := a.selectJobs(names)
+	if err != nil {
+		return nil, err
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	a.restoreState()
+	a.publishRestoredStatus()
+	a.runJobs(ctx, jobs)
+	a.saveState()
+	a.closeNotifiers(nil)
+
+	selected := make(map[string]struct{}, len(jobs))
+	for _, job := range jobs {
+		selected[job.Name] = struct{}{}
+	}
+	var statuses []JobStatus
+	for _, status := range a.Status() {
+		if _, ok := selected[status.Name]; ok {
+			statuses = append(statuses, status)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return statuses, fmt.Errorf("run interrupted: %w", err)
+	}
+	return statuses, nil
+}
+
+func (a *App) selectJobs(names []string) ([]*Job, error) {
+	if len(names) == 0 {
+		jobs := make([]*Job, len(a.jobs))
+		for i := range a.jobs {
+			jobs[i] = &a.jobs[i]
+		}
+		return jobs, nil
+	}
+
+	wanted := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		wanted[name] = struct{}{}
+	}
+	var jobs []*Job
+	for i := range a.jobs {
+		if _, ok := wanted[a.jobs[i].Name]; ok {
+			jobs = append(jobs, &a.jobs[i])
+			delete(wanted, a.jobs[i].Name)
+		}
+	}
+	for _, name := range names {
+		if _, missing := wanted[name]; missing {
+			return nil, fmt.Errorf("unknown job %q", name)
+		}
+	}
+	return jobs, nil
+}
+
 func (a *App) Run(ctx context.Context) {
 	if ctx == nil {
 		ctx = context.Background()
 	}
+	defer a.closeNotifiers(nil)
+	a.restoreState()
+	a.publishRestoredStatus()
+	a.startWatchers(ctx)
 	a.schedule(ctx)
 }
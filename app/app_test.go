@@ -3,6 +3,8 @@ package app
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -140,8 +142,8 @@ func TestNewAppUsesNoopForNilNotifier(t *testing.T) {
 	if u.calls != 1 {
 		t.Fatalf("expected update with no-op notifier, got %d calls", u.calls)
 	}
-	if _, ok := a.notifier.(*notifier.Noop); !ok {
-		t.Fatalf("expected nil notifier to become Noop, got %T", a.notifier)
+	if _, ok := a.notifiers[0].Notifier.(*notifier.Noop); !ok {
+		t.Fatalf("expected nil notifier to become Noop, got %T", a.notifiers[0].Notifier)
 	}
 }
 
@@ -965,3 +967,209 @@ func newTestApp(p provider.Provider, u updater.Updater, n notifier.Notifier, fam
 	job := NewJob("default", "test-provider", p, "test-updater", u, "", "", families, VerifyAuto)
 	return NewApp([]Job{job}, "test-notifier", n, time.Second)
 }
+
+// concurrencyProvider records how many GetIPs calls overlap.
+type concurrencyProvider struct {
+	mu     sync.Mutex
+	active int
+	peak   int
+}
+
+func (p *concurrencyProvider) GetIPs(ctx context.Context, _ provider.FamilyRequest) (*provider.IpResult, error) {
+	p.mu.Lock()
+	p.active++
+	p.peak = max(p.peak, p.active)
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		p.active--
+		p.mu.Unlock()
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(20 * time.Millisecond):
+	}
+	return &provider.IpResult{IPv4: "192.0.2.10"}, nil
+}
+
+func TestRunOnceBoundsConcurrentJobs(t *testing.T) {
+	shared := &concurrencyProvider{}
+	var jobs []Job
+	for i := range 6 {
+		p := &distinctProvider{inner: shared}
+		jobs = append(jobs, NewJob(fmt.Sprintf("job-%d", i), "test-provider", p, "test-updater", &recordingUpdater{}, "", "", AllFamilies(), VerifyOff))
+	}
+	a := NewApp(jobs, "test-notifier", &notifier.Noop{}, time.Second)
+	a.SetMaxConcurrency(2)
+
+	a.runOnce(context.Background())
+
+	if shared.peak != 2 {
+		t.Fatalf("expected at most 2 concurrent jobs and some overlap, got peak %d", shared.peak)
+	}
+	for _, job := range a.jobs {
+		if job.lastAppliedIPv4 != "192.0.2.10" {
+			t.Fatalf("expected every job to finish, got %q for %s", job.lastAppliedIPv4, job.Name)
+		}
+	}
+}
+
+// distinctProvider wraps a provider in a separate instance so jobs are not
+// deduplicated by the per-cycle provider lookup.
+type distinctProvider struct {
+	inner provider.Provider
+}
+
+func (p *distinctProvider) GetIPs(ctx context.Context, request provider.FamilyRequest) (*provider.IpResult, error) {
+	return p.inner.GetIPs(ctx, request)
+}
+
+func TestRunOnceCallsSharedProviderOncePerCycle(t *testing.T) {
+	p := &staticProvider{result: &provider.IpResult{IPv4: "192.0.2.10", IPv6: "2001:db8::10"}}
+	home := &recordingUpdater{}
+	office := &recordingUpdater{}
+	ipv4 := &recordingUpdater{}
+	jobs := []Job{
+		NewJob("home", "test-provider", p, "test-updater", home, "", "", AllFamilies(), VerifyOff),
+		NewJob("office", "test-provider", p, "test-updater", office, "", "", AllFamilies(), VerifyOff),
+		NewJob("ipv4", "test-provider", p, "test-updater", ipv4, "", "", Families{IPv4: true}, VerifyOff),
+	}
+	a := NewApp(jobs, "test-notifier", &notifier.Noop{}, time.Second)
+	a.SetMaxConcurrency(1)
+
+	a.runOnce(context.Background())
+
+	if p.calls != 2 {
+		t.Fatalf("expected one provider call per family set, got %d", p.calls)
+	}
+	if home.calls != 1 || office.calls != 1 || ipv4.calls != 1 {
+		t.Fatalf("expected every job to update once, got home=%d office=%d ipv4=%d", home.calls, office.calls, ipv4.calls)
+	}
+	if ipv4.last.IPv6 != "" {
+		t.Fatalf("expected shared result to be filtered per job, got %+v", ipv4.last)
+	}
+}
+
+func TestRunDueRunsJobsOnTheirOwnIntervals(t *testing.T) {
+	fast := &staticProvider{result: &provider.IpResult{IPv4: "192.0.2.10"}}
+	slow := &staticProvider{result: &provider.IpResult{IPv4: "192.0.2.20"}}
+	fastJob := NewJob("fast", "test-provider", fast, "test-updater", &recordingUpdater{}, "", "", AllFamilies(), VerifyOff)
+	fastJob.Interval = 15 * time.Second
+	slowJob := NewJob("slow", "test-provider", slow, "test-updater", &recordingUpdater{}, "", "", AllFamilies(), VerifyOff)
+	slowJob.Interval = time.Hour
+	a := NewApp([]Job{fastJob, slowJob}, "test-notifier", &recordingNotifier{}, 30*time.Second)
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	a.clock = clock
+
+	for range 4 {
+		a.runDue(context.Background())
+		if wait := a.untilNextRun(clock.now); wait != 15*time.Second {
+			t.Fatalf("expected next wakeup in 15s, got %s", wait)
+		}
+		clock.Advance(15 * time.Second)
+	}
+
+	if fast.calls != 4 {
+		t.Fatalf("expected fast job to run every 15s, got %d calls", fast.calls)
+	}
+	if slow.calls != 1 {
+		t.Fatalf("expected hourly job to run once, got %d calls", slow.calls)
+	}
+}
+
+func TestRunDueUsesAppIntervalByDefault(t *testing.T) {
+	p := &staticProvider{result: &provider.IpResult{IPv4: "192.0.2.10"}}
+	a := newTestApp(p, &recordingUpdater{}, &recordingNotifier{}, AllFamilies())
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	a.clock = clock
+
+	a.runDue(context.Background())
+	clock.Advance(a.interval - time.Millisecond)
+	a.runDue(context.Background())
+	if p.calls != 1 {
+		t.Fatalf("expected job not to run before its interval, got %d calls", p.calls)
+	}
+	clock.Advance(time.Millisecond)
+	a.runDue(context.Background())
+	if p.calls != 2 {
+		t.Fatalf("expected job to run when its interval elapsed, got %d calls", p.calls)
+	}
+}
+
+func TestUntilNextRunWaitsForFailureBackoff(t *testing.T) {
+	p := &staticProvider{err: errors.New("provider failed")}
+	job := NewJob("failing", "test-provider", p, "test-updater", &recordingUpdater{}, "", "", AllFamilies(), VerifyOff)
+	job.Interval = 15 * time.Second
+	a := NewApp([]Job{job}, "test-notifier", &recordingNotifier{}, time.Hour)
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	a.clock = clock
+	a.jitter = func() float64 { return 1 }
+
+	a.runDue(context.Background())
+	a.runDue(context.Background())
+	if p.calls != 1 {
+		t.Fatalf("expected one provider call, got %d", p.calls)
+	}
+	if got := a.jobs[0].retryAfter.Sub(clock.now); got != 15*time.Second {
+		t.Fatalf("expected backoff to start from the job interval, got %s", got)
+	}
+	a.jobs[0].retryAfter = clock.now.Add(time.Minute)
+	if wait := a.untilNextRun(clock.now); wait != time.Minute {
+		t.Fatalf("expected scheduler to wait for backoff, got %s", wait)
+	}
+	clock.Advance(time.Minute)
+	a.runDue(context.Background())
+	if p.calls != 2 {
+		t.Fatalf("expected retry after backoff expired, got %d calls", p.calls)
+	}
+}
+
+func TestRunOnceRunsSelectedJobsIgnoringBackoff(t *testing.T) {
+	home := &staticProvider{result: &provider.IpResult{IPv4: "192.0.2.10"}}
+	office := &staticProvider{err: errors.New("provider failed")}
+	jobs := []Job{
+		NewJob("home", "test-provider", home, "test-updater", &recordingUpdater{}, "", "", AllFamilies(), VerifyOff),
+		NewJob("office", "test-provider", office, "test-updater", &recordingUpdater{}, "", "", AllFamilies(), VerifyOff),
+	}
+	a := NewApp(jobs, "test-notifier", &recordingNotifier{}, time.Minute)
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	a.clock = clock
+	a.jobs[1].failureCount = 1
+	a.jobs[1].retryAfter = clock.now.Add(time.Hour)
+
+	statuses, err := a.RunOnce(context.Background(), "office")
+	if err != nil {
+		t.Fatalf("RunOnce returned error: %v", err)
+	}
+
+	if home.calls != 0 || office.calls != 1 {
+		t.Fatalf("expected only the selected job to run despite backoff, got home=%d office=%d", home.calls, office.calls)
+	}
+	if len(statuses) != 1 || statuses[0].Name != "office" || !statuses[0].Failed() {
+		t.Fatalf("expected failed office status, got %#v", statuses)
+	}
+	if _, err := a.RunOnce(context.Background(), "missing"); err == nil {
+		t.Fatal("expected unknown job to return an error")
+	}
+}
+
+func TestRunOnceReportsInterruptedRun(t *testing.T) {
+	p := &staticProvider{result: &provider.IpResult{IPv4: "192.0.2.10"}}
+	u := &recordingUpdater{}
+	a := newTestApp(p, u, &recordingNotifier{}, AllFamilies())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	statuses, err := a.RunOnce(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected canceled run to return context.Canceled, got %v", err)
+	}
+	if u.calls != 0 {
+		t.Fatalf("expected no job to run, got %d updater calls", u.calls)
+	}
+	if len(statuses) != 1 || statuses[0].Failed() {
+		t.Fatalf("expected the job that never ran to keep its status, got %#v", statuses)
+	}
+}
@@ -0,0 +1,98 @@
+package app
+
+import (
+	"log/slog"
+
+	"github.com/we11adam/uddns/internal/status"
+	"github.com/we11adam/uddns/notifier"
+	"github.com/we11adam/uddns/provider"
+	"github.com/we11adam/uddns/updater"
+)
+
+// RecordChange is one DNS record value a dry run would have written.
+type RecordChange = status.RecordChange
+
+// SetDryRun makes the app read providers and current records as usual but
+// only log the DNS updates and notifications it would send. Job state is not
+// persisted in dry-run mode. It must be called before Run or RunOnce.
+func (a *App) SetDryRun(dryRun bool) {
+	a.dryRun = dryRun
+}
+
+// readsCurrentRecords reports whether the job should read its current records
+// this run. Dry runs always read them when the updater can, so that planned
+// changes show the value they would replace.
+func (a *App) readsCurrentRecords(job *Job, providerIPChanged bool) bool {
+	if a.dryRun {
+		if _, ok := job.Updater.(updater.RecordReader); ok {
+			return true
+		}
+	}
+	return job.shouldReadCurrentRecords(a.clock.Now(), providerIPChanged)
+}
+
+// planUpdate logs and returns the record changes Update would have made.
+// current is nil when the updater's records could not be read.
+func (a *App) planUpdate(job *Job, desired, current *provider.IpResult) []RecordChange {
+	record := job.Record
+	if record == "" {
+		record = job.Name
+	}
+	families := []struct {
+		recordType string
+		desired    string
+		current    string
+		applied    string
+	}{
+		{"A", desired.IPv4, ipResultValue(current, "ipv4"), job.lastAppliedIPv4},
+		{"AAAA", desired.IPv6, ipResultValue(current, "ipv6"), job.lastAppliedIPv6},
+	}
+
+	var changes, rewrites []RecordChange
+	for _, family := range families {
+		if family.desired == "" {
+			continue
+		}
+		change := RecordChange{
+			Job:          job.Name,
+			Record:       record,
+			Type:         family.recordType,
+			Value:        family.desired,
+			Current:      family.current,
+			CurrentKnown: current != nil,
+		}
+		rewrites = append(rewrites, change)
+		if (current != nil && family.desired != family.current) || (current == nil && family.desired != family.applied) {
+			changes = append(changes, change)
+		}
+	}
+	// Update always writes every requested family, so a forced update with no
+	// visible difference still rewrites them all.
+	if len(changes) == 0 {
+		changes = rewrites
+	}
+
+	for _, change := range changes {
+		slog.Info(
+			"dry run: "+change.String(),
+			job.logAttrs(
+				"record_type", change.Type,
+				"value", change.Value,
+				"current", change.Current,
+				"current_known", change.CurrentKnown,
+			)...,
+		)
+	}
+	return changes
+}
+
+func (a *App) logSkippedNotification(job *Job, route notifier.Route, notification notifier.Notification) {
+	slog.Info(
+		"dry run: would send notification",
+		job.logAttrs(
+			"notifier", route.Name,
+			"reason", notification.Reason,
+			"message", notification.Message,
+		)...,
+	)
+}
@@ -0,0 +1,78 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/we11adam/uddns/internal/state"
+	"github.com/we11adam/uddns/provider"
+)
+
+func TestDryRunPlansChangesWithoutUpdatingOrNotifying(t *testing.T) {
+	p := &staticProvider{result: &provider.IpResult{IPv4: "192.0.2.10", IPv6: "2001:db8::10"}}
+	u := &recordReadingUpdater{current: &provider.IpResult{IPv4: "198.51.100.5", IPv6: "2001:db8::10"}}
+	n := &recordingNotifier{}
+	job := NewJob("home", "test-provider", p, "test-updater", u, "home.example.com", "", AllFamilies(), VerifyOff)
+	a := NewApp([]Job{job}, "test-notifier", n, time.Minute)
+	store := &memoryStateStore{}
+	a.UseStateStore(store)
+	a.SetDryRun(true)
+
+	statuses, err := a.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("RunOnce returned error: %v", err)
+	}
+
+	if u.calls != 0 {
+		t.Fatalf("expected dry run not to call Update, got %d calls", u.calls)
+	}
+	if u.currentCalls != 1 {
+		t.Fatalf("expected dry run to read current records even with verify off, got %d reads", u.currentCalls)
+	}
+	if len(n.notifications) != 0 {
+		t.Fatalf("expected dry run not to notify, got %+v", n.notifications)
+	}
+	if store.saves != 0 {
+		t.Fatalf("expected dry run not to persist state, got %d saves", store.saves)
+	}
+	want := RecordChange{Job: "home", Record: "home.example.com", Type: "A", Value: "192.0.2.10", Current: "198.51.100.5", CurrentKnown: true}
+	if len(statuses) != 1 || len(statuses[0].PlannedChanges) != 1 || statuses[0].PlannedChanges[0] != want {
+		t.Fatalf("expected planned change %+v, got %#v", want, statuses)
+	}
+	if got := want.String(); got != "would set A home.example.com 192.0.2.10 (currently 198.51.100.5)" {
+		t.Fatalf("unexpected plan message %q", got)
+	}
+}
+
+func TestDryRunWithoutRecordReaderPlansAgainstLastApplied(t *testing.T) {
+	p := &staticProvider{result: &provider.IpResult{IPv4: "192.0.2.10", IPv6: "2001:db8::10"}}
+	u := &recordingUpdater{}
+	job := NewJob("home", "test-provider", p, "test-updater", u, "home.example.com", "", AllFamilies(), VerifyAuto)
+	a := NewApp([]Job{job}, "test-notifier", &recordingNotifier{}, time.Minute)
+	a.UseStateStore(&memoryStateStore{jobs: map[string]state.Job{
+		"home": {
+			Provider:        "test-provider",
+			Updater:         "test-updater",
+			Record:          "home.example.com",
+			LastAppliedIPv4: "192.0.2.10",
+		},
+	}})
+	a.SetDryRun(true)
+
+	statuses, err := a.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("RunOnce returned error: %v", err)
+	}
+
+	if u.calls != 0 {
+		t.Fatalf("expected dry run not to call Update, got %d calls", u.calls)
+	}
+	changes := statuses[0].PlannedChanges
+	if len(changes) != 1 || changes[0].Type != "AAAA" || changes[0].CurrentKnown {
+		t.Fatalf("expected one AAAA change with unknown current value, got %#v", changes)
+	}
+	if got := changes[0].String(); got != "would set AAAA home.example.com 2001:db8::10 (current value unknown)" {
+		t.Fatalf("unexpected plan message %q", got)
+	}
+}
@@ -0,0 +1,72 @@
+package app
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/we11adam/uddns/provider"
+)
+
+// providerLookups deduplicates provider calls within one update cycle. Jobs
+// that use the same provider instance with the same address families share a
+// single GetIPs call instead of querying the provider once per job.
+type providerLookups struct {
+	mu      sync.Mutex
+	calls   map[providerLookupKey]*providerLookup
+	metrics *appMetrics
+}
+
+type providerLookupKey struct {
+	provider provider.Provider
+	families Families
+}
+
+type providerLookup struct {
+	once   sync.Once
+	result *provider.IpResult
+	err    error
+}
+
+func newProviderLookups(metrics *appMetrics) *providerLookups {
+	return &providerLookups{calls: map[providerLookupKey]*providerLookup{}, metrics: metrics}
+}
+
+func (l *providerLookups) getIPs(ctx context.Context, job *Job) (*provider.IpResult, error) {
+	request := provider.FamilyRequest{IPv4: job.Families.IPv4, IPv6: job.Families.IPv6}
+	if !shareableProvider(job.Provider) {
+		return l.call(ctx, job, request)
+	}
+
+	key := providerLookupKey{provider: job.Provider, families: job.Families}
+	l.mu.Lock()
+	call, ok := l.calls[key]
+	if !ok {
+		call = &providerLookup{}
+		l.calls[key] = call
+	}
+	l.mu.Unlock()
+
+	call.once.Do(func() {
+		call.result, call.err = l.call(ctx, job, request)
+	})
+	if call.err != nil || call.result == nil {
+		return call.result, call.err
+	}
+	result := *call.result
+	return &result, nil
+}
+
+func (l *providerLookups) call(ctx context.Context, job *Job, request provider.FamilyRequest) (*provider.IpResult, error) {
+	startedAt := time.Now()
+	result, err := job.Provider.GetIPs(ctx, request)
+	l.metrics.providerDuration.Observe(time.Since(startedAt).Seconds(), job.ProviderName, callResult(err))
+	return result, err
+}
+
+// shareableProvider reports whether a provider can be used as a map key.
+// Providers backed by non-comparable values are simply not deduplicated.
+func shareableProvider(p provider.Provider) bool {
+	return p != nil && reflect.TypeOf(p).Comparable()
+}
@@ -0,0 +1,108 @@
+package app
+
+import (
+	"io"
+	"time"
+
+	"github.com/we11adam/uddns/internal/metrics"
+)
+
+// appMetrics holds the counters and latency histograms recorded while jobs
+// run. Per-job gauges are derived from the status board when scraped.
+type appMetrics struct {
+	cycles           *metrics.CounterVec
+	ipChanges        *metrics.CounterVec
+	providerDuration *metrics.HistogramVec
+	updaterDuration  *metrics.HistogramVec
+	notifierDuration *metrics.HistogramVec
+}
+
+func newAppMetrics() *appMetrics {
+	return &appMetrics{
+		cycles: metrics.NewCounterVec(
+			"uddns_job_cycles_total",
+			"Completed job runs by final status.",
+			"job", "status",
+		),
+		ipChanges: metrics.NewCounterVec(
+			"uddns_ip_changes_total",
+			"Applied address changes by address family.",
+			"job", "family",
+		),
+		providerDuration: metrics.NewHistogramVec(
+			"uddns_provider_request_duration_seconds",
+			"Provider address lookup latency.",
+			metrics.DefaultBuckets,
+			"provider", "result",
+		),
+		updaterDuration: metrics.NewHistogramVec(
+			"uddns_updater_request_duration_seconds",
+			"Updater API latency by operation.",
+			metrics.DefaultBuckets,
+			"job", "updater", "operation", "result",
+		),
+		notifierDuration: metrics.NewHistogramVec(
+			"uddns_notifier_request_duration_seconds",
+			"Notification delivery latency.",
+			metrics.DefaultBuckets,
+			"notifier", "result",
+		),
+	}
+}
+
+func callResult(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
+
+// WriteMetrics writes every app metric in the Prometheus text exposition
+// format.
+func (a *App) WriteMetrics(w io.Writer) error {
+	for _, family := range []interface{ Write(io.Writer) error }{
+		a.metrics.cycles,
+		a.metrics.ipChanges,
+		a.metrics.providerDuration,
+		a.metrics.updaterDuration,
+		a.metrics.notifierDuration,
+	} {
+		if err := family.Write(w); err != nil {
+			return err
+		}
+	}
+
+	now := a.clock.Now()
+	var failures, backoff, lastSuccess, lastUpdate []metrics.Sample
+	for _, job := range a.Status() {
+		labels := []string{job.Name}
+		failures = append(failures, metrics.Sample{LabelValues: labels, Value: float64(job.FailureCount)})
+		backoff = append(backoff, metrics.Sample{LabelValues: labels, Value: max(job.RetryAfter.Sub(now), 0).Seconds()})
+		if !job.LastSuccessAt.IsZero() {
+			lastSuccess = append(lastSuccess, metrics.Sample{LabelValues: labels, Value: unixSeconds(job.LastSuccessAt)})
+		}
+		if !job.LastUpdatedAt.IsZero() {
+			lastUpdate = append(lastUpdate, metrics.Sample{LabelValues: labels, Value: unixSeconds(job.LastUpdatedAt)})
+		}
+	}
+	gauges := []struct {
+		name    string
+		help    string
+		samples []metrics.Sample
+	}{
+		{"uddns_job_failure_count", "Consecutive failed runs of the job.", failures},
+		{"uddns_job_backoff_remaining_seconds", "Time left before a backed-off job may run again.", backoff},
+		{"uddns_job_last_success_timestamp_seconds", "Unix time of the job's last successful run.", lastSuccess},
+		{"uddns_job_last_update_timestamp_seconds", "Unix time of the job's last successful DNS update.", lastUpdate},
+	}
+	for _, gauge := range gauges {
+		if err := metrics.WriteFamily(w, gauge.name, gauge.help, "gauge", []string{"job"}, gauge.samples); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func unixSeconds(t time.Time) float64 {
+	return float64(t.UnixNano()) / float64(time.Second)
+}
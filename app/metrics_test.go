@@ -0,0 +1,75 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/we11adam/uddns/provider"
+)
+
+func TestRunJobRecordsMetrics(t *testing.T) {
+	p := &staticProvider{result: &provider.IpResult{IPv4: "192.0.2.10"}}
+	u := &recordingUpdater{}
+	n := &recordingNotifier{}
+	a := newTestApp(p, u, n, AllFamilies())
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	a.clock = clock
+
+	a.runOnce(context.Background())
+	a.runOnce(context.Background())
+	p.result = &provider.IpResult{IPv4: "192.0.2.20"}
+	u.err = errors.New("update failed")
+	a.runOnce(context.Background())
+
+	m := a.metrics
+	if got := m.cycles.Value("default", string(jobStatusOK)); got != 1 {
+		t.Fatalf("expected one ok cycle, got %v", got)
+	}
+	if got := m.cycles.Value("default", string(jobStatusUnchanged)); got != 1 {
+		t.Fatalf("expected one unchanged cycle, got %v", got)
+	}
+	if got := m.cycles.Value("default", string(jobStatusUpdaterError)); got != 1 {
+		t.Fatalf("expected one updater_error cycle, got %v", got)
+	}
+	if got := m.ipChanges.Value("default", "ipv4"); got != 1 {
+		t.Fatalf("expected one applied IPv4 change, got %v", got)
+	}
+	if got := m.providerDuration.Count("test-provider", "success"); got != 3 {
+		t.Fatalf("expected three provider observations, got %d", got)
+	}
+	if got := m.updaterDuration.Count("default", "test-updater", "update", "error"); got != 1 {
+		t.Fatalf("expected one failed update observation, got %d", got)
+	}
+	if got := m.notifierDuration.Count("test-notifier", "success"); got == 0 {
+		t.Fatal("expected notifier observations")
+	}
+}
+
+func TestWriteMetricsIncludesJobGauges(t *testing.T) {
+	p := &staticProvider{err: errors.New("provider failed")}
+	a := newTestApp(p, &recordingUpdater{}, &recordingNotifier{}, AllFamilies())
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	a.clock = clock
+	a.jitter = func() float64 { return 1 }
+
+	a.runOnce(context.Background())
+	var out strings.Builder
+	if err := a.WriteMetrics(&out); err != nil {
+		t.Fatalf("WriteMetrics returned error: %v", err)
+	}
+
+	body := out.String()
+	for _, want := range []string{
+		`uddns_job_cycles_total{job="default",status="provider_error"} 1`,
+		`uddns_job_failure_count{job="default"} 1`,
+		`uddns_job_backoff_remaining_seconds{job="default"} 1`,
+		"# TYPE uddns_job_last_success_timestamp_seconds gauge\n# HELP",
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected metrics to contain %q, got:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/we11adam/uddns/notifier"
+	"github.com/we11adam/uddns/provider"
+)
+
+func TestRunOnceRoutesNotificationsByReasonAndJob(t *testing.T) {
+	p := &staticProvider{result: &provider.IpResult{IPv4: "192.0.2.10"}}
+	jobs := []Job{
+		NewJob("home", "test-provider", p, "test-updater", &recordingUpdater{}, "", "", AllFamilies(), VerifyOff),
+		NewJob("office", "test-provider", p, "test-updater", &recordingUpdater{err: errors.New("update failed")}, "", "", AllFamilies(), VerifyOff),
+	}
+	everything := &recordingNotifier{}
+	failures := &recordingNotifier{}
+	homeOnly := &recordingNotifier{}
+	a := NewApp(jobs, "", nil, time.Minute)
+	a.SetNotifiers([]notifier.Route{
+		{Name: "everything", Notifier: everything},
+		{Name: "failures", Notifier: failures, Reasons: []notifier.Reason{notifier.ReasonUpdateFailure}},
+		{Name: "home-only", Notifier: homeOnly, Jobs: []string{"home"}},
+	})
+
+	a.runOnce(context.Background())
+
+	if len(everything.notifications) != 3 {
+		t.Fatalf("expected every notification for the unfiltered route, got %d", len(everything.notifications))
+	}
+	if len(failures.notifications) != 1 || failures.notifications[0].Job != "office" {
+		t.Fatalf("expected only the office update failure, got %#v", failures.notifications)
+	}
+	if len(homeOnly.notifications) != 2 {
+		t.Fatalf("expected the home IP change and update success, got %#v", homeOnly.notifications)
+	}
+	for _, notification := range homeOnly.notifications {
+		if notification.Job != "home" {
+			t.Fatalf("expected only home notifications, got %#v", notification)
+		}
+	}
+}
+
+func TestRunOnceFailingNotifierDoesNotBlockOthers(t *testing.T) {
+	p := &staticProvider{result: &provider.IpResult{IPv4: "192.0.2.10"}}
+	job := NewJob("home", "test-provider", p, "test-updater", &recordingUpdater{}, "", "", AllFamilies(), VerifyOff)
+	failing := &recordingNotifier{err: errors.New("notifier down")}
+	working := &recordingNotifier{}
+	a := NewApp([]Job{job}, "", nil, time.Minute)
+	a.SetNotifiers([]notifier.Route{
+		{Name: "failing", Notifier: failing},
+		{Name: "working", Notifier: working},
+	})
+
+	a.runOnce(context.Background())
+	a.runOnce(context.Background())
+
+	if len(working.notifications) != 2 {
+		t.Fatalf("expected the working notifier to get each notification once, got %d", len(working.notifications))
+	}
+	if a.jobs[0].lastNotifiedIPv4 != "" {
+		t.Fatalf("expected the IP change to stay pending while a route fails, got %q", a.jobs[0].lastNotifiedIPv4)
+	}
+	if got := a.metrics.notifierDuration.Count("failing", "error"); got != 3 {
+		t.Fatalf("expected failing notifier errors to be recorded, got %d", got)
+	}
+}
+
+func TestRunOnceRetriesOnlyFailedNotificationRoutes(t *testing.T) {
+	notifyErr := errors.New("notifier down")
+	p := &staticProvider{result: &provider.IpResult{IPv4: "192.0.2.10"}}
+	job := NewJob("home", "test-provider", p, "test-updater", &recordingUpdater{}, "", "", AllFamilies(), VerifyOff)
+	failing := &recordingNotifier{errs: []error{notifyErr, notifyErr}}
+	working := &recordingNotifier{}
+	a := NewApp([]Job{job}, "", nil, time.Minute)
+	a.SetNotifiers([]notifier.Route{
+		{Name: "failing", Notifier: failing, Reasons: []notifier.Reason{notifier.ReasonIPChange}},
+		{Name: "working", Notifier: working, Reasons: []notifier.Reason{notifier.ReasonIPChange}},
+	})
+
+	a.runOnce(context.Background())
+	if a.jobs[0].lastNotifiedIPv4 != "" {
+		t.Fatal("expected a partially delivered notification to be retried")
+	}
+	a.runOnce(context.Background())
+	if a.jobs[0].lastNotifiedIPv4 != "" {
+		t.Fatal("expected a partially delivered notification to be retried")
+	}
+	a.runOnce(context.Background())
+	a.runOnce(context.Background())
+
+	if len(working.notifications) != 1 {
+		t.Fatalf("expected the working route to be notified once, got %d", len(working.notifications))
+	}
+	if len(failing.notifications) != 3 {
+		t.Fatalf("expected the failing route to be retried until it succeeds, got %d attempts", len(failing.notifications))
+	}
+	for _, notification := range failing.notifications[1:] {
+		if !notification.Event.Time.Equal(failing.notifications[0].Event.Time) {
+			t.Fatal("expected retries to keep the time of the first attempt")
+		}
+	}
+	if a.jobs[0].lastNotifiedIPv4 != "192.0.2.10" {
+		t.Fatalf("expected the notification to be complete, got %q", a.jobs[0].lastNotifiedIPv4)
+	}
+	if len(a.jobs[0].pendingNotifications) != 0 {
+		t.Fatalf("expected no pending notifications, got %v", a.jobs[0].pendingNotifications)
+	}
+}
+
+func TestRunOnceRendersNotificationTemplates(t *testing.T) {
+	p := &staticProvider{result: &provider.IpResult{IPv4: "192.0.2.20"}}
+	job := NewJob("home", "test-provider", p, "test-updater", &recordingUpdater{}, "home.example.com", "", AllFamilies(), VerifyOff)
+	job.lastAppliedIPv4 = "192.0.2.10"
+	job.lastNotifiedIPv4 = "192.0.2.10"
+	templates, err := notifier.ParseTemplates(
+		map[string]string{"ip_change": "{{.Record}} {{.Family}}: {{.OldIPv4}} -> {{.NewIPv4}} via {{.Updater}}"},
+		map[string]string{"default": "{{.Job}} {{.Reason}}"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := &recordingNotifier{}
+	a := NewApp([]Job{job}, "", nil, time.Minute)
+	a.SetNotifiers([]notifier.Route{{Name: "templated", Notifier: n, Templates: templates}})
+
+	a.runOnce(context.Background())
+
+	if len(n.notifications) != 2 {
+		t.Fatalf("expected ip_change and update_success notifications, got %#v", n.notifications)
+	}
+	ipChange, success := n.notifications[0], n.notifications[1]
+	if ipChange.Message != "home.example.com ipv4: 192.0.2.10 -> 192.0.2.20 via test-updater" || ipChange.Title != "home ip_change" {
+		t.Fatalf("unexpected templated ip_change: %#v", ipChange)
+	}
+	if success.Message != "home: DNS records updated for IPv4 192.0.2.20" || success.Title != "home update_success" {
+		t.Fatalf("expected the default message with a templated title, got %#v", success)
+	}
+}
@@ -0,0 +1,110 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// Reload hands the jobs, notifiers, interval, and concurrency of next to the
+// running scheduler, which swaps them in between passes. Jobs whose name,
+// provider, updater, record, and zone are unchanged keep their applied and
+// notified addresses, backoff, and schedule; every other job starts fresh.
+// The state store and dry-run mode of the running App are kept. Reload
+// returns once the scheduler has accepted next, or when ctx is done.
+func (a *App) Reload(ctx context.Context, next *App) error {
+	select {
+	case a.reloads <- next:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// reloadSummary lists job names by how a reload affected them.
+type reloadSummary struct {
+	added     []string
+	removed   []string
+	modified  []string
+	unchanged []string
+}
+
+func (a *App) applyReload(ctx context.Context, next *App) reloadSummary {
+	now := a.clock.Now()
+	previous := make(map[string]*Job, len(a.jobs))
+	for i := range a.jobs {
+		previous[a.jobs[i].Name] = &a.jobs[i]
+	}
+
+	var summary reloadSummary
+	kept := map[string]struct{}{}
+	jobs := slices.Clone(next.jobs)
+	for i := range jobs {
+		job := &jobs[i]
+		old, ok := previous[job.Name]
+		if !ok {
+			summary.added = append(summary.added, job.Name)
+			continue
+		}
+		delete(previous, job.Name)
+		if old.sameDefinition(job) {
+			summary.unchanged = append(summary.unchanged, job.Name)
+		} else {
+			summary.modified = append(summary.modified, job.Name)
+		}
+		if old.stateTarget().SameTarget(job.stateTarget()) {
+			job.keepState(old, now, next.jobInterval(job))
+			kept[job.Name] = struct{}{}
+		}
+	}
+	for i := range a.jobs {
+		if _, ok := previous[a.jobs[i].Name]; ok {
+			summary.removed = append(summary.removed, a.jobs[i].Name)
+		}
+	}
+
+	a.closeNotifiers(next.notifiers)
+	a.jobs = jobs
+	a.notifiers = next.notifiers
+	a.interval = next.interval
+	a.maxConcurrency = next.maxConcurrency
+	a.statuses.reset(jobs, kept)
+	a.publishRestoredStatus()
+	a.startWatchers(ctx)
+
+	slog.Info(
+		"reloaded configuration",
+		"jobs", len(jobs),
+		"added", summary.added,
+		"removed", summary.removed,
+		"modified", summary.modified,
+		"unchanged", len(summary.unchanged),
+		"notifiers", a.notifierNames(),
+		"interval", a.interval,
+		"max_concurrency", a.maxConcurrency,
+	)
+	return summary
+}
+
+// sameDefinition reports whether other describes the same job. Provider and
+// updater settings such as credentials are not compared; they are always taken
+// from the new configuration.
+func (job *Job) sameDefinition(other *Job) bool {
+	return job.stateTarget().SameTarget(other.stateTarget()) &&
+		job.Families == other.Families &&
+		job.Verify == other.Verify &&
+		job.Interval == other.Interval
+}
+
+// keepState carries the runtime state of old over to job. A job whose
+// interval shrank is pulled forward so it does not wait out the old interval.
+func (job *Job) keepState(old *Job, now time.Time, interval time.Duration) {
+	job.restoreState(old.persistentState())
+	job.lastVerifiedAt = old.lastVerifiedAt
+	job.recordDriftPending = old.recordDriftPending
+	job.nextRunAt = old.nextRunAt
+	if limit := now.Add(interval); job.nextRunAt.After(limit) {
+		job.nextRunAt = limit
+	}
+}
@@ -0,0 +1,129 @@
+package app
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/we11adam/uddns/provider"
+)
+
+func TestApplyReloadKeepsStateForUnchangedJobs(t *testing.T) {
+	p := &staticProvider{result: &provider.IpResult{IPv4: "192.0.2.10"}}
+	jobs := []Job{
+		NewJob("home", "test-provider", p, "test-updater", &recordingUpdater{}, "home.example.com", "", AllFamilies(), VerifyOff),
+		NewJob("office", "test-provider", p, "test-updater", &recordingUpdater{}, "office.example.com", "", AllFamilies(), VerifyOff),
+		NewJob("lab", "test-provider", p, "test-updater", &recordingUpdater{}, "lab.example.com", "", AllFamilies(), VerifyOff),
+	}
+	a := NewApp(jobs, "test-notifier", &recordingNotifier{}, time.Minute)
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	a.clock = clock
+	a.runDue(context.Background())
+
+	u := &recordingUpdater{}
+	nextJobs := []Job{
+		NewJob("home", "test-provider", p, "test-updater", u, "home.example.com", "", AllFamilies(), VerifyOff),
+		NewJob("office", "test-provider", p, "test-updater", &recordingUpdater{}, "vpn.example.com", "", AllFamilies(), VerifyOff),
+		NewJob("nas", "test-provider", p, "test-updater", &recordingUpdater{}, "nas.example.com", "", AllFamilies(), VerifyOff),
+	}
+	summary := a.applyReload(context.Background(), NewApp(nextJobs, "other-notifier", &recordingNotifier{}, time.Minute))
+
+	if !slices.Equal(summary.added, []string{"nas"}) ||
+		!slices.Equal(summary.removed, []string{"lab"}) ||
+		!slices.Equal(summary.modified, []string{"office"}) ||
+		!slices.Equal(summary.unchanged, []string{"home"}) {
+		t.Fatalf("unexpected reload summary: %+v", summary)
+	}
+	if a.jobs[0].lastAppliedIPv4 != "192.0.2.10" || !a.jobs[0].nextRunAt.Equal(clock.now.Add(time.Minute)) {
+		t.Fatalf("expected unchanged job to keep its state, got applied=%q next_run=%s", a.jobs[0].lastAppliedIPv4, a.jobs[0].nextRunAt)
+	}
+	if a.jobs[1].lastAppliedIPv4 != "" || !a.jobs[1].nextRunAt.IsZero() {
+		t.Fatalf("expected job with a new record to start fresh, got applied=%q", a.jobs[1].lastAppliedIPv4)
+	}
+	if names := a.notifierNames(); !slices.Equal(names, []string{"other-notifier"}) {
+		t.Fatalf("expected notifier to be replaced, got %v", names)
+	}
+
+	statuses := a.Status()
+	names := make([]string, 0, len(statuses))
+	for _, status := range statuses {
+		names = append(names, status.Name)
+	}
+	if !slices.Equal(names, []string{"home", "office", "nas"}) {
+		t.Fatalf("expected status to follow the reloaded jobs, got %v", names)
+	}
+	if statuses[0].LastStatus != string(jobStatusOK) || statuses[1].LastStatus != "" {
+		t.Fatalf("expected only the kept job to retain its status, got %q and %q", statuses[0].LastStatus, statuses[1].LastStatus)
+	}
+
+	a.runDue(context.Background())
+	if u.calls != 0 {
+		t.Fatalf("expected kept job to wait for its schedule, got %d updates", u.calls)
+	}
+}
+
+func TestApplyReloadPullsShortenedIntervalForward(t *testing.T) {
+	p := &staticProvider{result: &provider.IpResult{IPv4: "192.0.2.10"}}
+	job := NewJob("home", "test-provider", p, "test-updater", &recordingUpdater{}, "home.example.com", "", AllFamilies(), VerifyOff)
+	job.Interval = time.Hour
+	a := NewApp([]Job{job}, "test-notifier", &recordingNotifier{}, time.Minute)
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	a.clock = clock
+	a.runDue(context.Background())
+
+	job.Interval = 15 * time.Second
+	summary := a.applyReload(context.Background(), NewApp([]Job{job}, "test-notifier", &recordingNotifier{}, time.Minute))
+
+	if !slices.Equal(summary.modified, []string{"home"}) {
+		t.Fatalf("expected interval change to count as modified, got %+v", summary)
+	}
+	if wait := a.untilNextRun(clock.now); wait != 15*time.Second {
+		t.Fatalf("expected next run within the new interval, got %s", wait)
+	}
+	if a.jobs[0].lastAppliedIPv4 != "192.0.2.10" {
+		t.Fatalf("expected state to survive an interval change, got %q", a.jobs[0].lastAppliedIPv4)
+	}
+}
+
+func TestReloadRunsAddedJobsAndRewatchesProviders(t *testing.T) {
+	first := &watchingProvider{called: make(chan struct{}, 4), trigger: make(chan struct{})}
+	job := NewJob("home", "first-provider", first, "test-updater", &recordingUpdater{}, "", "", AllFamilies(), VerifyOff)
+	a := NewApp([]Job{job}, "test-notifier", &recordingNotifier{}, time.Hour)
+	a.changeDebounce = 10 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		a.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	waitForCall := func(p *watchingProvider, reason string) {
+		t.Helper()
+		select {
+		case <-p.called:
+		case <-time.After(time.Second):
+			t.Fatalf("provider was not called %s", reason)
+		}
+	}
+	waitForCall(first, "on startup")
+
+	second := &watchingProvider{called: make(chan struct{}, 4), trigger: make(chan struct{})}
+	added := NewJob("office", "second-provider", second, "test-updater", &recordingUpdater{}, "", "", AllFamilies(), VerifyOff)
+	if err := a.Reload(ctx, NewApp([]Job{added}, "test-notifier", &recordingNotifier{}, time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	waitForCall(second, "for the added job")
+
+	second.trigger <- struct{}{}
+	waitForCall(second, "after a change event from the new provider")
+	select {
+	case first.trigger <- struct{}{}:
+		t.Fatal("expected the removed job's provider to no longer be watched")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
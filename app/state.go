@@ -0,0 +1,168 @@
+package app
+
+import (
+	"log/slog"
+	"sort"
+
+	"github.com/we11adam/uddns/internal/state"
+)
+
+// StateStore persists job state between process restarts.
+type StateStore interface {
+	Load() (map[string]state.Job, error)
+	Save(map[string]state.Job) error
+}
+
+// UseStateStore enables durable job state. It must be called before Run.
+func (a *App) UseStateStore(store StateStore) {
+	a.stateStore = store
+}
+
+// restoreState applies persisted state to jobs whose provider, updater, and
+// record are unchanged. State recorded under a job name that no longer exists
+// is reused by a new job name with the same target, which covers renames;
+// anything else is discarded so stale addresses never suppress an update.
+// A state file that cannot be loaded is left untouched rather than
+// overwritten, since it may be corrupt or written by a newer version.
+func (a *App) restoreState() {
+	if a.stateStore == nil {
+		return
+	}
+	saved, err := a.stateStore.Load()
+	a.stateLoadErr = err
+	if err != nil {
+		slog.Warn("failed to load job state; starting without persisted state and leaving the state file unchanged", "error", err)
+		return
+	}
+
+	current := make(map[string]struct{}, len(a.jobs))
+	for i := range a.jobs {
+		current[a.jobs[i].Name] = struct{}{}
+	}
+	claimed := make(map[string]struct{}, len(saved))
+	pending := make([]*Job, 0, len(a.jobs))
+	for i := range a.jobs {
+		job := &a.jobs[i]
+		entry, ok := saved[job.Name]
+		if !ok {
+			pending = append(pending, job)
+			continue
+		}
+		claimed[job.Name] = struct{}{}
+		if !entry.SameTarget(job.stateTarget()) {
+			slog.Info(
+				"discarding persisted job state for changed job",
+				job.logAttrs(
+					"previous_provider", entry.Provider,
+					"previous_updater", entry.Updater,
+					"previous_record", entry.Record,
+					"previous_zone", entry.Zone,
+				)...,
+			)
+			continue
+		}
+		job.restoreState(entry)
+		slog.Debug("restored persisted job state", job.logAttrs()...)
+	}
+
+	names := make([]string, 0, len(saved))
+	for name := range saved {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, job := range pending {
+		for _, name := range names {
+			if _, ok := claimed[name]; ok {
+				continue
+			}
+			if _, ok := current[name]; ok {
+				continue
+			}
+			entry := saved[name]
+			if !entry.SameTarget(job.stateTarget()) {
+				continue
+			}
+			claimed[name] = struct{}{}
+			job.restoreState(entry)
+			slog.Info("restored persisted state from renamed job", job.logAttrs("previous_job", name)...)
+			break
+		}
+	}
+
+	for _, name := range names {
+		if _, ok := claimed[name]; !ok {
+			slog.Info("discarding persisted state for removed job", "job", name)
+		}
+	}
+	a.savedState = a.stateSnapshot()
+}
+
+// saveState writes job state when it differs from the last successful save,
+// so stable cycles do not rewrite the state file.
+func (a *App) saveState() {
+	if a.stateStore == nil || a.dryRun || a.stateLoadErr != nil {
+		return
+	}
+	snapshot := a.stateSnapshot()
+	if a.savedState != nil && stateEqual(a.savedState, snapshot) {
+		return
+	}
+	if err := a.stateStore.Save(snapshot); err != nil {
+		slog.Warn("failed to save job state", "error", err)
+		return
+	}
+	a.savedState = snapshot
+}
+
+func (a *App) stateSnapshot() map[string]state.Job {
+	snapshot := make(map[string]state.Job, len(a.jobs))
+	for i := range a.jobs {
+		snapshot[a.jobs[i].Name] = a.jobs[i].persistentState()
+	}
+	return snapshot
+}
+
+func stateEqual(left, right map[string]state.Job) bool {
+	if len(left) != len(right) {
+		return false
+	}
+	for name, job := range left {
+		other, ok := right[name]
+		if !ok || !job.Equal(other) {
+			return false
+		}
+	}
+	return true
+}
+
+func (job *Job) stateTarget() state.Job {
+	return state.Job{
+		Provider: job.ProviderName,
+		Updater:  job.UpdaterName,
+		Record:   job.Record,
+		Zone:     job.Zone,
+	}
+}
+
+func (job *Job) persistentState() state.Job {
+	saved := job.stateTarget()
+	saved.LastAppliedIPv4 = job.lastAppliedIPv4
+	saved.LastAppliedIPv6 = job.lastAppliedIPv6
+	saved.LastNotifiedIPv4 = job.lastNotifiedIPv4
+	saved.LastNotifiedIPv6 = job.lastNotifiedIPv6
+	saved.LastNotifiedUpdateFailure = job.lastNotifiedUpdateFailure
+	saved.FailureCount = job.failureCount
+	saved.RetryAfter = job.retryAfter
+	return saved
+}
+
+func (job *Job) restoreState(saved state.Job) {
+	job.lastAppliedIPv4 = saved.LastAppliedIPv4
+	job.lastAppliedIPv6 = saved.LastAppliedIPv6
+	job.lastNotifiedIPv4 = saved.LastNotifiedIPv4
+	job.lastNotifiedIPv6 = saved.LastNotifiedIPv6
+	job.lastNotifiedUpdateFailure = saved.LastNotifiedUpdateFailure
+	job.failureCount = saved.FailureCount
+	job.retryAfter = saved.RetryAfter
+}
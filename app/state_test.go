@@ -0,0 +1,186 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/we11adam/uddns/internal/state"
+	"github.com/we11adam/uddns/provider"
+)
+
+type memoryStateStore struct {
+	jobs    map[string]state.Job
+	loadErr error
+	saves   int
+}
+
+func (s *memoryStateStore) Load() (map[string]state.Job, error) {
+	if s.loadErr != nil {
+		return nil, s.loadErr
+	}
+	jobs := make(map[string]state.Job, len(s.jobs))
+	for name, job := range s.jobs {
+		jobs[name] = job
+	}
+	return jobs, nil
+}
+
+func (s *memoryStateStore) Save(jobs map[string]state.Job) error {
+	s.saves++
+	s.jobs = jobs
+	return nil
+}
+
+func TestRestoreStateSuppressesRepeatedUpdateAndNotification(t *testing.T) {
+	p := &staticProvider{result: &provider.IpResult{IPv4: "192.0.2.10"}}
+	u := &recordingUpdater{}
+	n := &recordingNotifier{}
+	a := newTestApp(p, u, n, AllFamilies())
+	store := &memoryStateStore{jobs: map[string]state.Job{
+		"default": {
+			Provider:         "test-provider",
+			Updater:          "test-updater",
+			LastAppliedIPv4:  "192.0.2.10",
+			LastNotifiedIPv4: "192.0.2.10",
+		},
+	}}
+	a.UseStateStore(store)
+
+	a.restoreState()
+	a.runOnce(context.Background())
+	a.saveState()
+
+	if u.calls != 0 {
+		t.Fatalf("expected restored state to skip the update, got %d calls", u.calls)
+	}
+	if len(n.notifications) != 0 {
+		t.Fatalf("expected restored state to skip notifications, got %+v", n.notifications)
+	}
+	if store.saves != 0 {
+		t.Fatalf("expected unchanged state not to be rewritten, got %d saves", store.saves)
+	}
+}
+
+func TestRestoreStateDiscardsChangedTarget(t *testing.T) {
+	p := &staticProvider{result: &provider.IpResult{IPv4: "192.0.2.10"}}
+	u := &recordingUpdater{}
+	job := NewJob("home", "test-provider", p, "test-updater", u, "home.example.com", "", AllFamilies(), VerifyOff)
+	a := NewApp([]Job{job}, "test-notifier", &recordingNotifier{}, time.Second)
+	a.UseStateStore(&memoryStateStore{jobs: map[string]state.Job{
+		"home": {
+			Provider:        "test-provider",
+			Updater:         "test-updater",
+			Record:          "old.example.com",
+			LastAppliedIPv4: "192.0.2.10",
+		},
+	}})
+
+	a.restoreState()
+	a.runOnce(context.Background())
+
+	if u.calls != 1 {
+		t.Fatalf("expected changed record to be updated, got %d calls", u.calls)
+	}
+}
+
+func TestRestoreStateFollowsRenamedJob(t *testing.T) {
+	retryAfter := time.Unix(1_700_000_100, 0)
+	job := NewJob("home-v2", "test-provider", &staticProvider{}, "test-updater", &recordingUpdater{}, "home.example.com", "", AllFamilies(), VerifyOff)
+	a := NewApp([]Job{job}, "test-notifier", &recordingNotifier{}, time.Second)
+	a.UseStateStore(&memoryStateStore{jobs: map[string]state.Job{
+		"home": {
+			Provider:        "test-provider",
+			Updater:         "test-updater",
+			Record:          "home.example.com",
+			LastAppliedIPv4: "192.0.2.10",
+			FailureCount:    2,
+			RetryAfter:      retryAfter,
+		},
+		"removed": {
+			Provider: "test-provider",
+			Updater:  "test-updater",
+			Record:   "gone.example.com",
+		},
+	}})
+
+	a.restoreState()
+
+	if a.jobs[0].lastAppliedIPv4 != "192.0.2.10" {
+		t.Fatalf("expected renamed job to inherit applied IPv4, got %q", a.jobs[0].lastAppliedIPv4)
+	}
+	if a.jobs[0].failureCount != 2 || !a.jobs[0].retryAfter.Equal(retryAfter) {
+		t.Fatalf("expected renamed job to inherit backoff, got failures=%d retry_after=%s", a.jobs[0].failureCount, a.jobs[0].retryAfter)
+	}
+}
+
+func TestSaveStateWritesCurrentJobsOnly(t *testing.T) {
+	p := &staticProvider{result: &provider.IpResult{IPv4: "192.0.2.10"}}
+	a := newTestApp(p, &recordingUpdater{}, &recordingNotifier{}, AllFamilies())
+	store := &memoryStateStore{jobs: map[string]state.Job{
+		"removed": {Provider: "test-provider", Updater: "test-updater"},
+	}}
+	a.UseStateStore(store)
+
+	a.restoreState()
+	a.runOnce(context.Background())
+	a.saveState()
+
+	if store.saves != 1 {
+		t.Fatalf("expected one save, got %d", store.saves)
+	}
+	if _, ok := store.jobs["removed"]; ok {
+		t.Fatal("expected removed job state to be dropped")
+	}
+	if got := store.jobs["default"].LastAppliedIPv4; got != "192.0.2.10" {
+		t.Fatalf("expected saved applied IPv4, got %q", got)
+	}
+}
+
+func TestRestoreStateIgnoresLoadFailure(t *testing.T) {
+	p := &staticProvider{result: &provider.IpResult{IPv4: "192.0.2.10"}}
+	u := &recordingUpdater{}
+	a := newTestApp(p, u, &recordingNotifier{}, AllFamilies())
+	a.UseStateStore(&memoryStateStore{loadErr: errors.New("corrupt")})
+
+	a.restoreState()
+	a.runOnce(context.Background())
+
+	if u.calls != 1 {
+		t.Fatalf("expected update after state load failure, got %d calls", u.calls)
+	}
+}
+
+func TestSaveStateKeepsUnreadableStateFile(t *testing.T) {
+	dir := t.TempDir()
+	store, err := state.New(dir)
+	if err != nil {
+		t.Fatalf("state.New returned error: %v", err)
+	}
+	future := []byte(`{"version": 2, "jobs": {"default": {"future_field": true}}}`)
+	if err := os.WriteFile(filepath.Join(dir, state.FileName), future, 0o600); err != nil {
+		t.Fatalf("write state file: %v", err)
+	}
+
+	p := &staticProvider{result: &provider.IpResult{IPv4: "192.0.2.10"}}
+	u := &recordingUpdater{}
+	a := newTestApp(p, u, &recordingNotifier{}, AllFamilies())
+	a.UseStateStore(store)
+
+	a.restoreState()
+	a.runOnce(context.Background())
+
+	if u.calls != 1 {
+		t.Fatalf("expected update after state load failure, got %d calls", u.calls)
+	}
+	got, err := os.ReadFile(store.Path())
+	if err != nil {
+		t.Fatalf("read state file: %v", err)
+	}
+	if string(got) != string(future) {
+		t.Fatalf("expected state file to be left intact, got %s", got)
+	}
+}
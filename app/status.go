@@ -0,0 +1,164 @@
+package app
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/we11adam/uddns/internal/status"
+	"github.com/we11adam/uddns/provider"
+)
+
+// JobStatus is a point-in-time view of one job for health and status
+// reporting.
+type JobStatus = status.Job
+
+// StatusObserver is implemented by notifiers that also mirror the status of
+// every job.
+type StatusObserver = status.Observer
+
+// statusBoard holds the latest JobStatus of every job. Jobs publish to it when
+// a run finishes, so readers never touch job state while a run is in flight.
+type statusBoard struct {
+	mu    sync.RWMutex
+	jobs  []JobStatus
+	index map[string]int
+}
+
+func newStatusBoard(jobs []Job) *statusBoard {
+	board := &statusBoard{
+		jobs:  make([]JobStatus, len(jobs)),
+		index: make(map[string]int, len(jobs)),
+	}
+	for i := range jobs {
+		board.jobs[i] = JobStatus{
+			Name:     jobs[i].Name,
+			Provider: jobs[i].ProviderName,
+			Updater:  jobs[i].UpdaterName,
+			Record:   jobs[i].Record,
+			Zone:     jobs[i].Zone,
+		}
+		board.index[jobs[i].Name] = i
+	}
+	return board
+}
+
+// reset replaces the board's jobs after a reload. Jobs named in kept retain
+// their previous status; all others start empty.
+func (b *statusBoard) reset(jobs []Job, kept map[string]struct{}) {
+	next := newStatusBoard(jobs)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i := range next.jobs {
+		if _, ok := kept[next.jobs[i].Name]; !ok {
+			continue
+		}
+		if index, ok := b.index[next.jobs[i].Name]; ok {
+			next.jobs[i] = b.jobs[index]
+		}
+	}
+	b.jobs, b.index = next.jobs, next.index
+}
+
+// Status returns the latest status of every job in configuration order.
+func (a *App) Status() []JobStatus {
+	a.statuses.mu.RLock()
+	defer a.statuses.mu.RUnlock()
+	jobs := make([]JobStatus, len(a.statuses.jobs))
+	copy(jobs, a.statuses.jobs)
+	return jobs
+}
+
+// Ready reports whether every job has completed at least one successful run.
+func (a *App) Ready() bool {
+	a.statuses.mu.RLock()
+	defer a.statuses.mu.RUnlock()
+	for _, job := range a.statuses.jobs {
+		if job.LastSuccessAt.IsZero() {
+			return false
+		}
+	}
+	return true
+}
+
+// runOutcome is what a finished run reports to the status board.
+type runOutcome struct {
+	status   jobStatus
+	updated  bool
+	detected *provider.IpResult
+	planned  []RecordChange
+	err      error
+}
+
+// publishStatus records the outcome of a finished run. It runs on the job's
+// goroutine, which owns the job state until the run completes.
+func (a *App) publishStatus(job *Job, outcome runOutcome) {
+	a.statuses.mu.Lock()
+	i, ok := a.statuses.index[job.Name]
+	if !ok {
+		a.statuses.mu.Unlock()
+		return
+	}
+	current := &a.statuses.jobs[i]
+	now := a.clock.Now()
+	current.LastStatus = string(outcome.status)
+	current.LastError = ""
+	if outcome.err != nil {
+		current.LastError = outcome.err.Error()
+	}
+	if outcome.detected != nil {
+		current.LastDetectedIPv4 = outcome.detected.IPv4
+		current.LastDetectedIPv6 = outcome.detected.IPv6
+	}
+	current.LastRunAt = now
+	if !isBackoffFailure(outcome.status) {
+		current.LastSuccessAt = now
+	}
+	if outcome.updated {
+		current.LastUpdatedAt = now
+	}
+	current.PlannedChanges = outcome.planned
+	job.copyStateTo(current)
+	status := *current
+	a.statuses.mu.Unlock()
+
+	a.observeStatus(status)
+}
+
+// publishRestoredStatus exposes state restored from disk before the first run.
+func (a *App) publishRestoredStatus() {
+	a.statuses.mu.Lock()
+	for i := range a.jobs {
+		if index, ok := a.statuses.index[a.jobs[i].Name]; ok {
+			a.jobs[i].copyStateTo(&a.statuses.jobs[index])
+		}
+	}
+	a.statuses.mu.Unlock()
+
+	for _, status := range a.Status() {
+		a.observeStatus(status)
+	}
+}
+
+// observeStatus hands status to every notifier that observes it.
+func (a *App) observeStatus(status JobStatus) {
+	if a.dryRun {
+		return
+	}
+	for _, route := range a.notifiers {
+		observer, ok := route.Notifier.(StatusObserver)
+		if !ok || (len(route.Jobs) > 0 && !slices.Contains(route.Jobs, status.Name)) {
+			continue
+		}
+		observer.ObserveStatus(status)
+	}
+}
+
+// copyStateTo copies the job's runtime state into its status.
+func (job *Job) copyStateTo(s *JobStatus) {
+	s.LastAppliedIPv4 = job.lastAppliedIPv4
+	s.LastAppliedIPv6 = job.lastAppliedIPv6
+	s.LastVerifiedAt = job.lastVerifiedAt
+	s.FailureCount = job.failureCount
+	s.RetryAfter = job.retryAfter
+	s.NextRunAt = job.nextRunAt
+}
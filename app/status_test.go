@@ -0,0 +1,145 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/we11adam/uddns/notifier"
+	"github.com/we11adam/uddns/provider"
+)
+
+func TestStatusReportsLastRunOfEachJob(t *testing.T) {
+	healthy := &staticProvider{result: &provider.IpResult{IPv4: "192.0.2.10"}}
+	failing := &staticProvider{err: errors.New("provider failed")}
+	jobs := []Job{
+		NewJob("healthy", "test-provider", healthy, "test-updater", &recordingUpdater{}, "home.example.com", "", AllFamilies(), VerifyOff),
+		NewJob("failing", "test-provider", failing, "test-updater", &recordingUpdater{}, "office.example.com", "", AllFamilies(), VerifyOff),
+	}
+	a := NewApp(jobs, "test-notifier", &recordingNotifier{}, time.Minute)
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	a.clock = clock
+	a.jitter = func() float64 { return 1 }
+
+	if a.Ready() {
+		t.Fatal("expected app not to be ready before any run")
+	}
+	a.runOnce(context.Background())
+
+	statuses := a.Status()
+	if len(statuses) != 2 || statuses[0].Name != "healthy" || statuses[1].Name != "failing" {
+		t.Fatalf("expected statuses in job order, got %#v", statuses)
+	}
+	got := statuses[0]
+	if got.LastStatus != string(jobStatusOK) || got.LastDetectedIPv4 != "192.0.2.10" || got.LastAppliedIPv4 != "192.0.2.10" || !got.LastSuccessAt.Equal(clock.now) {
+		t.Fatalf("unexpected healthy status: %#v", got)
+	}
+	got = statuses[1]
+	if got.LastStatus != string(jobStatusProviderError) || got.LastError != "provider failed" || got.FailureCount != 1 || got.RetryAfter.IsZero() || !got.LastSuccessAt.IsZero() {
+		t.Fatalf("unexpected failing status: %#v", got)
+	}
+	if a.Ready() {
+		t.Fatal("expected app not to be ready while a job has never succeeded")
+	}
+
+	failing.err = nil
+	failing.result = &provider.IpResult{IPv4: "192.0.2.20"}
+	clock.Advance(time.Hour)
+	a.runOnce(context.Background())
+
+	if !a.Ready() {
+		t.Fatal("expected app to be ready after every job succeeded")
+	}
+	if got := a.Status()[1]; got.LastError != "" || got.FailureCount != 0 {
+		t.Fatalf("expected recovered job to clear its error, got %#v", got)
+	}
+}
+
+type observingNotifier struct {
+	recordingNotifier
+	mu       sync.Mutex
+	statuses []JobStatus
+	closed   int
+}
+
+func (n *observingNotifier) ObserveStatus(status JobStatus) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.statuses = append(n.statuses, status)
+}
+
+func (n *observingNotifier) Close() error {
+	n.closed++
+	return nil
+}
+
+func TestStatusObserversReceiveRestoredAndRunStatus(t *testing.T) {
+	p := &staticProvider{result: &provider.IpResult{IPv4: "192.0.2.10"}}
+	jobs := []Job{
+		NewJob("home", "test-provider", p, "test-updater", &recordingUpdater{}, "home.example.com", "", AllFamilies(), VerifyOff),
+		NewJob("office", "test-provider", p, "test-updater", &recordingUpdater{}, "office.example.com", "", AllFamilies(), VerifyOff),
+	}
+	all := &observingNotifier{}
+	homeOnly := &observingNotifier{}
+	a := NewApp(jobs, "", nil, time.Minute)
+	a.SetNotifiers([]notifier.Route{
+		{Name: "all", Notifier: all},
+		{Name: "home-only", Notifier: homeOnly, Jobs: []string{"home"}},
+	})
+
+	if _, err := a.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(all.statuses) != 4 {
+		t.Fatalf("expected a restored and a run status per job, got %#v", all.statuses)
+	}
+	last := all.statuses[len(all.statuses)-1]
+	if last.LastStatus != string(jobStatusOK) || last.LastAppliedIPv4 != "192.0.2.10" {
+		t.Fatalf("unexpected observed status: %#v", last)
+	}
+	for _, status := range homeOnly.statuses {
+		if status.Name != "home" {
+			t.Fatalf("expected the route's job filter to apply, got %q", status.Name)
+		}
+	}
+	if len(homeOnly.statuses) != 2 {
+		t.Fatalf("expected two statuses for the filtered route, got %d", len(homeOnly.statuses))
+	}
+	if all.closed != 1 || homeOnly.closed != 1 {
+		t.Fatalf("expected notifiers to be closed after RunOnce, got %d and %d", all.closed, homeOnly.closed)
+	}
+}
+
+func TestStatusObserversAreSkippedInDryRun(t *testing.T) {
+	p := &staticProvider{result: &provider.IpResult{IPv4: "192.0.2.10"}}
+	observer := &observingNotifier{}
+	a := newTestApp(p, &recordingUpdater{}, observer, AllFamilies())
+	a.SetDryRun(true)
+
+	if _, err := a.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(observer.statuses) != 0 {
+		t.Fatalf("expected no observed status in dry-run mode, got %#v", observer.statuses)
+	}
+}
+
+func TestApplyReloadClosesReplacedNotifiers(t *testing.T) {
+	p := &staticProvider{result: &provider.IpResult{IPv4: "192.0.2.10"}}
+	job := NewJob("home", "test-provider", p, "test-updater", &recordingUpdater{}, "home.example.com", "", AllFamilies(), VerifyOff)
+	old := &observingNotifier{}
+	a := NewApp([]Job{job}, "old", old, time.Minute)
+	next := &observingNotifier{}
+
+	a.applyReload(context.Background(), NewApp([]Job{job}, "next", next, time.Minute))
+
+	if old.closed != 1 || next.closed != 0 {
+		t.Fatalf("expected only the replaced notifier to be closed, got old=%d next=%d", old.closed, next.closed)
+	}
+	if len(next.statuses) != 1 {
+		t.Fatalf("expected the new notifier to observe the reloaded job, got %#v", next.statuses)
+	}
+}
@@ -0,0 +1,113 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/we11adam/uddns/provider"
+)
+
+// changeDebounce collapses bursts of provider change events, such as the
+// address removals and additions of a PPPoE reconnect, into one run.
+const changeDebounce = 2 * time.Second
+
+// providerChanges collects providers that reported a change until the
+// scheduler picks them up.
+type providerChanges struct {
+	mu      sync.Mutex
+	pending map[provider.Provider]struct{}
+	ready   chan struct{}
+}
+
+func newProviderChanges() *providerChanges {
+	return &providerChanges{
+		pending: map[provider.Provider]struct{}{},
+		ready:   make(chan struct{}, 1),
+	}
+}
+
+func (c *providerChanges) add(p provider.Provider) {
+	c.mu.Lock()
+	c.pending[p] = struct{}{}
+	c.mu.Unlock()
+	select {
+	case c.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (c *providerChanges) take() map[provider.Provider]struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	pending := c.pending
+	c.pending = map[provider.Provider]struct{}{}
+	return pending
+}
+
+// startWatchers subscribes to change events from every distinct provider that
+// implements provider.Watcher. Providers that fail to subscribe are still
+// polled on their jobs' intervals. Calling it again after the jobs change, as
+// a reload does, stops watching providers that are no longer used and starts
+// watching new ones.
+func (a *App) startWatchers(ctx context.Context) {
+	used := map[provider.Provider]struct{}{}
+	for i := range a.jobs {
+		job := &a.jobs[i]
+		watcher, ok := job.Provider.(provider.Watcher)
+		if !ok || !shareableProvider(job.Provider) {
+			continue
+		}
+		used[job.Provider] = struct{}{}
+		if _, ok := a.watchers[job.Provider]; ok {
+			continue
+		}
+
+		watchCtx, cancel := context.WithCancel(ctx)
+		a.watchers[job.Provider] = cancel
+		p, name := job.Provider, job.ProviderName
+		slog.Info("watching provider for address changes", "provider", name)
+		go func() {
+			err := watcher.Watch(watchCtx, func() {
+				slog.Debug("provider reported address change", "provider", name)
+				a.changes.add(p)
+			})
+			if err != nil && watchCtx.Err() == nil {
+				slog.Warn("provider change events unavailable; polling only", "provider", name, "error", err)
+			}
+		}()
+	}
+
+	for p, cancel := range a.watchers {
+		if _, ok := used[p]; !ok {
+			cancel()
+			delete(a.watchers, p)
+		}
+	}
+}
+
+// runChanged immediately runs every job that uses one of the changed
+// providers. A change event is fresh evidence, so it also cuts short any
+// failure backoff, for example after the provider failed while a link was
+// down. Each job's schedule restarts from now.
+func (a *App) runChanged(ctx context.Context, changed map[provider.Provider]struct{}) {
+	now := a.clock.Now()
+	var jobs []*Job
+	for i := range a.jobs {
+		job := &a.jobs[i]
+		if !shareableProvider(job.Provider) {
+			continue
+		}
+		if _, ok := changed[job.Provider]; !ok {
+			continue
+		}
+		job.nextRunAt = now.Add(a.jobInterval(job))
+		jobs = append(jobs, job)
+	}
+	if len(jobs) == 0 {
+		return
+	}
+	slog.Info("running jobs after provider change", "jobs", len(jobs))
+	a.runJobs(ctx, jobs)
+}
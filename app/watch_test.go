@@ -0,0 +1,127 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/we11adam/uddns/provider"
+)
+
+// watchingProvider reports a change each time a value is sent on trigger.
+type watchingProvider struct {
+	mu      sync.Mutex
+	calls   int
+	called  chan struct{}
+	trigger chan struct{}
+}
+
+func (p *watchingProvider) GetIPs(_ context.Context, _ provider.FamilyRequest) (*provider.IpResult, error) {
+	p.mu.Lock()
+	p.calls++
+	p.mu.Unlock()
+	p.called <- struct{}{}
+	return &provider.IpResult{IPv4: "192.0.2.10"}, nil
+}
+
+func (p *watchingProvider) Watch(ctx context.Context, changed func()) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-p.trigger:
+			changed()
+		}
+	}
+}
+
+func TestRunTriggersImmediateRunOnProviderChange(t *testing.T) {
+	p := &watchingProvider{called: make(chan struct{}, 4), trigger: make(chan struct{})}
+	job := NewJob("netif", "test-provider", p, "test-updater", &recordingUpdater{}, "", "", AllFamilies(), VerifyOff)
+	a := NewApp([]Job{job}, "test-notifier", &recordingNotifier{}, time.Hour)
+	a.changeDebounce = 10 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		a.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	waitForCall := func(reason string) {
+		t.Helper()
+		select {
+		case <-p.called:
+		case <-time.After(time.Second):
+			t.Fatalf("provider was not called %s", reason)
+		}
+	}
+	waitForCall("on startup")
+	for range 3 {
+		p.trigger <- struct{}{}
+	}
+	waitForCall("after a change event")
+
+	time.Sleep(50 * time.Millisecond)
+	p.mu.Lock()
+	calls := p.calls
+	p.mu.Unlock()
+	if calls != 2 {
+		t.Fatalf("expected a burst of events to trigger one run, got %d calls", calls)
+	}
+}
+
+func TestRunChangedRunsOnlyAffectedJobsAndSkipsBackoff(t *testing.T) {
+	changed := &staticProvider{result: &provider.IpResult{IPv4: "192.0.2.10"}}
+	other := &staticProvider{result: &provider.IpResult{IPv4: "192.0.2.20"}}
+	jobs := []Job{
+		NewJob("changed", "test-provider", changed, "test-updater", &recordingUpdater{}, "", "", AllFamilies(), VerifyOff),
+		NewJob("other", "test-provider", other, "test-updater", &recordingUpdater{}, "", "", AllFamilies(), VerifyOff),
+	}
+	a := NewApp(jobs, "test-notifier", &recordingNotifier{}, time.Minute)
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	a.clock = clock
+	a.jobs[0].failureCount = 3
+	a.jobs[0].retryAfter = clock.now.Add(time.Hour)
+
+	a.runChanged(context.Background(), map[provider.Provider]struct{}{changed: {}})
+
+	if changed.calls != 1 || other.calls != 0 {
+		t.Fatalf("expected only the changed provider's job to run, got changed=%d other=%d", changed.calls, other.calls)
+	}
+	if a.jobs[0].failureCount != 0 {
+		t.Fatalf("expected successful run to clear backoff, got %d failures", a.jobs[0].failureCount)
+	}
+	if !a.jobs[0].nextRunAt.Equal(clock.now.Add(time.Minute)) {
+		t.Fatalf("expected schedule to restart from the change, got %s", a.jobs[0].nextRunAt)
+	}
+}
+
+type failingWatcher struct {
+	staticProvider
+}
+
+func (p *failingWatcher) Watch(context.Context, func()) error {
+	return errors.New("netlink unavailable")
+}
+
+func TestRunPollsWhenWatchFails(t *testing.T) {
+	p := &failingWatcher{staticProvider{result: &provider.IpResult{IPv4: "192.0.2.10"}}}
+	u := &recordingUpdater{}
+	job := NewJob("netif", "test-provider", p, "test-updater", u, "", "", AllFamilies(), VerifyOff)
+	a := NewApp([]Job{job}, "test-notifier", &recordingNotifier{}, time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	a.startWatchers(ctx)
+	a.runDue(ctx)
+	cancel()
+
+	if u.calls != 1 {
+		t.Fatalf("expected polling to continue when watching fails, got %d updates", u.calls)
+	}
+}
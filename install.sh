@@ -819,6 +819,7 @@ EOF
 		printf 'LoadCredential=%s\n' "$(systemd_quote "${SERVICE_CREDENTIAL}:${CONFIG_FILE}")"
 		printf 'Environment="UDDNS_CONFIG=%%d/%s"\n' "$SERVICE_CREDENTIAL"
 		systemd_env_line UDDNS_INTERVAL "$SERVICE_INTERVAL"
+		printf 'Environment="UDDNS_STATE_DIR=%%S/uddns"\n'
 
 		if [ -n "$LOG_DIR" ]; then
 			systemd_env_line UDDNS_LOG_DIR "$LOG_DIR"
@@ -851,6 +852,8 @@ LockPersonality=true
 SystemCallArchitectures=native
 LogsDirectory=uddns
 LogsDirectoryMode=0700
+StateDirectory=uddns
+StateDirectoryMode=0700
 UMask=0077
 EOF
 
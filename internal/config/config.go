@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,6 +15,8 @@ const (
 	DefaultInterval = 30 * time.Second
 	MinInterval     = 10 * time.Second
 	MaxInterval     = 24 * time.Hour
+
+	DefaultMaxConcurrency = 4
 )
 
 type Config struct {
@@ -29,6 +32,7 @@ type Job struct {
 	Zone     string   `mapstructure:"zone"`
 	Families []string `mapstructure:"families"`
 	Verify   string   `mapstructure:"verify"`
+	Interval string   `mapstructure:"interval"`
 }
 
 func Load(providedPath string) (*Config, error) {
@@ -144,6 +148,44 @@ func (j Job) VerifyMode() string {
 	return j.Verify
 }
 
+// StateDir returns the directory used to persist job state between restarts.
+// UDDNS_STATE_DIR overrides the state_dir config key; an empty value disables
+// persistence.
+func (c *Config) StateDir() string {
+	if value := strings.TrimSpace(os.Getenv("UDDNS_STATE_DIR")); value != "" {
+		return value
+	}
+	return strings.TrimSpace(c.GetString("state_dir"))
+}
+
+// HTTPListen returns the address for the health and status endpoints. An
+// empty value disables the HTTP server.
+func (c *Config) HTTPListen() string {
+	return strings.TrimSpace(c.GetString("http.listen"))
+}
+
+// WatchConfig reports whether the config file should be reloaded when its
+// content changes, in addition to on SIGHUP.
+func (c *Config) WatchConfig() bool {
+	return c.v.GetBool("watch_config")
+}
+
+// MaxConcurrency returns how many jobs may run at the same time.
+func (c *Config) MaxConcurrency() (int, error) {
+	if !c.IsSet("max_concurrency") {
+		return DefaultMaxConcurrency, nil
+	}
+	value := strings.TrimSpace(c.GetString("max_concurrency"))
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("max_concurrency must be an integer: %q", value)
+	}
+	if n < 1 {
+		return 0, fmt.Errorf("max_concurrency must be at least 1, got %d", n)
+	}
+	return n, nil
+}
+
 func (c *Config) WithOverrides(overrides map[string]any) *Config {
 	v := viper.New()
 	for key, value := range c.v.AllSettings() {
@@ -161,14 +203,24 @@ func (c *Config) Interval() (time.Duration, string, error) {
 		return DefaultInterval, "", nil
 	}
 
-	duration, err := time.ParseDuration(value)
+	duration, err := ParseInterval(value)
 	if err != nil {
 		return DefaultInterval, value, err
 	}
+	return duration, value, nil
+}
+
+// ParseInterval parses a Go duration and checks that it is an allowed update
+// interval.
+func ParseInterval(value string) (time.Duration, error) {
+	duration, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil {
+		return 0, err
+	}
 	if duration < MinInterval || duration > MaxInterval {
-		return DefaultInterval, value, fmt.Errorf("interval must be between %s and %s", MinInterval, MaxInterval)
+		return 0, fmt.Errorf("interval must be between %s and %s", MinInterval, MaxInterval)
 	}
-	return duration, value, nil
+	return duration, nil
 }
 
 func isReadable(p string) bool {
@@ -181,6 +181,7 @@ jobs:
     record: home
     families: [ipv4]
     verify: off
+    interval: 15s
 `)
 
 	cfg, err := Load(path)
@@ -195,11 +196,67 @@ jobs:
 	if !ok {
 		t.Fatal("expected jobs to be configured")
 	}
-	if len(jobs) != 1 || jobs[0].Name != "home" || jobs[0].Record != "home" || jobs[0].VerifyMode() != "off" {
+	if len(jobs) != 1 || jobs[0].Name != "home" || jobs[0].Record != "home" || jobs[0].VerifyMode() != "off" || jobs[0].Interval != "15s" {
 		t.Fatalf("unexpected jobs: %#v", jobs)
 	}
 }
 
+func TestStateDirLetsEnvironmentOverrideConfig(t *testing.T) {
+	path := writeConfigFile(t, `
+state_dir: /var/lib/uddns
+`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+
+	t.Setenv("UDDNS_STATE_DIR", "")
+	if got := cfg.StateDir(); got != "/var/lib/uddns" {
+		t.Fatalf("expected config state dir, got %q", got)
+	}
+	t.Setenv("UDDNS_STATE_DIR", " /run/uddns ")
+	if got := cfg.StateDir(); got != "/run/uddns" {
+		t.Fatalf("expected environment state dir, got %q", got)
+	}
+}
+
+func TestMaxConcurrency(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		want    int
+		wantErr bool
+	}{
+		{name: "default", config: "interval: 30s\n", want: DefaultMaxConcurrency},
+		{name: "configured", config: "max_concurrency: 8\n", want: 8},
+		{name: "zero", config: "max_concurrency: 0\n", wantErr: true},
+		{name: "not a number", config: "max_concurrency: many\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Load(writeConfigFile(t, tt.config))
+			if err != nil {
+				t.Fatalf("Load returned error: %v", err)
+			}
+
+			got, err := cfg.MaxConcurrency()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %d", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("MaxConcurrency returned error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("expected %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
 func TestWithOverridesAppliesNestedValues(t *testing.T) {
 	path := writeConfigFile(t, `
 updaters:
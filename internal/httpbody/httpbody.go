@@ -1,6 +1,10 @@
 package httpbody
 
-import "io"
+import (
+	"io"
+	"strings"
+	"unicode/utf8"
+)
 
 type limitedReadCloser struct {
 	io.Reader
@@ -13,3 +17,16 @@ func Limit(body io.ReadCloser, bytes int64) io.ReadCloser {
 		Closer: body,
 	}
 }
+
+// Truncate shortens a response body for an error message to at most limit
+// bytes without splitting a UTF-8 sequence. Invalid UTF-8 is dropped.
+func Truncate(body string, limit int) string {
+	if len(body) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(body[cut]) {
+			cut--
+		}
+		body = body[:cut]
+	}
+	return strings.ToValidUTF8(body, "")
+}
@@ -34,3 +34,21 @@ func TestLimitBoundsReadsAndForwardsClose(t *testing.T) {
 		t.Fatal("expected the original body to be closed")
 	}
 }
+
+func TestTruncateKeepsWholeRunes(t *testing.T) {
+	for _, tt := range []struct {
+		body  string
+		limit int
+		want  string
+	}{
+		{"short", 10, "short"},
+		{"123456789", 4, "1234"},
+		{"状态错误", 4, "状"},
+		{"状态错误", 6, "状态"},
+		{"bad \xff byte", 20, "bad  byte"},
+	} {
+		if got := Truncate(tt.body, tt.limit); got != tt.want {
+			t.Errorf("Truncate(%q, %d) = %q, want %q", tt.body, tt.limit, got, tt.want)
+		}
+	}
+}
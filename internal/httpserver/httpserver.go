@@ -0,0 +1,123 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/we11adam/uddns/app"
+	"github.com/we11adam/uddns/internal/metrics"
+)
+
+const (
+	readHeaderTimeout = 5 * time.Second
+	shutdownTimeout   = 5 * time.Second
+
+	metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+// Source reports job status and metrics. *app.App implements it.
+type Source interface {
+	Status() []app.JobStatus
+	Ready() bool
+	WriteMetrics(io.Writer) error
+}
+
+type Server struct {
+	listener net.Listener
+	server   *http.Server
+}
+
+type statusResponse struct {
+	Ready bool            `json:"ready"`
+	Jobs  []app.JobStatus `json:"jobs"`
+}
+
+// Listen binds addr so configuration errors surface before the app starts.
+// version is reported by the uddns_build_info metric.
+func Listen(addr string, source Source, version string) (*Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen on %s: %w", addr, err)
+	}
+	return &Server{
+		listener: listener,
+		server: &http.Server{
+			Handler:           Handler(source, version),
+			ReadHeaderTimeout: readHeaderTimeout,
+		},
+	}, nil
+}
+
+// Addr returns the bound address, which differs from the configured one when
+// the configured port is 0.
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Serve handles requests until ctx is canceled, then shuts down gracefully.
+func (s *Server) Serve(ctx context.Context) error {
+	stop := context.AfterFunc(ctx, func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := s.server.Shutdown(shutdownCtx); err != nil {
+			slog.Warn("failed to shut down HTTP server", "error", err)
+		}
+	})
+	defer stop()
+
+	if err := s.server.Serve(s.listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Handler returns the HTTP routes for source.
+func Handler(source Source, version string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		writeText(w, http.StatusOK, "ok")
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, _ *http.Request) {
+		if !source.Ready() {
+			writeText(w, http.StatusServiceUnavailable, "not ready")
+			return
+		}
+		writeText(w, http.StatusOK, "ready")
+	})
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		response := statusResponse{Ready: source.Ready(), Jobs: source.Status()}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			slog.Debug("failed to write status response", "error", err)
+		}
+	})
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", metricsContentType)
+		w.Header().Set("Cache-Control", "no-store")
+		buildInfo := metrics.Sample{LabelValues: []string{version, runtime.Version()}, Value: 1}
+		err := metrics.WriteFamily(w, "uddns_build_info", "Build information of the running binary.", "gauge", []string{"version", "goversion"}, []metrics.Sample{buildInfo})
+		if err == nil {
+			err = source.WriteMetrics(w)
+		}
+		if err != nil {
+			slog.Debug("failed to write metrics response", "error", err)
+		}
+	})
+	return mux
+}
+
+func writeText(w http.ResponseWriter, status int, body string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	fmt.Fprintln(w, body)
+}
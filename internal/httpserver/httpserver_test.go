@@ -0,0 +1,148 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/we11adam/uddns/app"
+)
+
+type fakeSource struct {
+	ready bool
+	jobs  []app.JobStatus
+}
+
+func (s *fakeSource) Status() []app.JobStatus {
+	return s.jobs
+}
+
+func (s *fakeSource) Ready() bool {
+	return s.ready
+}
+
+func (s *fakeSource) WriteMetrics(w io.Writer) error {
+	_, err := io.WriteString(w, "uddns_fake 1\n")
+	return err
+}
+
+func TestHealthzAlwaysReportsLive(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	Handler(&fakeSource{}, "test").ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", recorder.Code)
+	}
+}
+
+func TestReadyzReflectsSource(t *testing.T) {
+	tests := []struct {
+		ready bool
+		want  int
+	}{
+		{ready: false, want: http.StatusServiceUnavailable},
+		{ready: true, want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		recorder := httptest.NewRecorder()
+		Handler(&fakeSource{ready: tt.ready}, "test").ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+		if recorder.Code != tt.want {
+			t.Fatalf("ready=%t: expected %d, got %d", tt.ready, tt.want, recorder.Code)
+		}
+	}
+}
+
+func TestStatusReturnsJobsAsJSON(t *testing.T) {
+	retryAfter := time.Date(2026, 7, 26, 12, 0, 0, 0, time.UTC)
+	source := &fakeSource{jobs: []app.JobStatus{{
+		Name:             "home",
+		Provider:         "IpService",
+		Updater:          "Cloudflare",
+		LastStatus:       "provider_error",
+		LastError:        "timeout",
+		LastDetectedIPv4: "192.0.2.10",
+		FailureCount:     2,
+		RetryAfter:       retryAfter,
+	}}}
+	recorder := httptest.NewRecorder()
+	Handler(source, "test").ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/status", nil))
+
+	if got := recorder.Header().Get("Content-Type"); got != "application/json" {
+		t.Fatalf("expected JSON content type, got %q", got)
+	}
+	var body map[string]any
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode status: %v", err)
+	}
+	job := body["jobs"].([]any)[0].(map[string]any)
+	if body["ready"] != false || job["name"] != "home" || job["last_status"] != "provider_error" || job["failure_count"] != float64(2) || job["retry_after"] != "2026-07-26T12:00:00Z" {
+		t.Fatalf("unexpected status body: %s", recorder.Body.String())
+	}
+	if _, ok := job["last_success_at"]; ok {
+		t.Fatalf("expected zero times to be omitted, got %s", recorder.Body.String())
+	}
+}
+
+func TestHandlerRejectsOtherMethods(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	Handler(&fakeSource{}, "test").ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/status", nil))
+
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", recorder.Code)
+	}
+}
+
+func TestServeStopsWhenContextIsCanceled(t *testing.T) {
+	server, err := Listen("127.0.0.1:0", &fakeSource{ready: true}, "test")
+	if err != nil {
+		t.Fatalf("Listen returned error: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Serve(ctx)
+	}()
+
+	response, err := http.Get("http://" + server.Addr().String() + "/readyz")
+	if err != nil {
+		t.Fatalf("GET /readyz: %v", err)
+	}
+	body, _ := io.ReadAll(response.Body)
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK || string(body) != "ready\n" {
+		t.Fatalf("expected ready response, got %d %q", response.StatusCode, body)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Serve returned error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Serve did not return after cancellation")
+	}
+}
+
+func TestMetricsIncludesBuildInfoAndSourceMetrics(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	Handler(&fakeSource{}, "v1.2.3").ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	body := recorder.Body.String()
+	if recorder.Code != http.StatusOK || !strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("unexpected metrics response: %d %q", recorder.Code, recorder.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(body, `uddns_build_info{version="v1.2.3",goversion="`+runtime.Version()+`"} 1`) {
+		t.Fatalf("expected build info, got:\n%s", body)
+	}
+	if !strings.Contains(body, "uddns_fake 1\n") {
+		t.Fatalf("expected source metrics, got:\n%s", body)
+	}
+}
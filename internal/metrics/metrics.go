@@ -0,0 +1,225 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets suits network calls that usually take milliseconds to a few
+// seconds and time out after tens of seconds.
+var DefaultBuckets = []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// Sample is one labeled value of a metric family.
+type Sample struct {
+	LabelValues []string
+	Value       float64
+}
+
+// CounterVec is a monotonically increasing value per label set.
+type CounterVec struct {
+	name       string
+	help       string
+	labelNames []string
+	mu         sync.Mutex
+	values     map[string]*Sample
+}
+
+func NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	return &CounterVec{name: name, help: help, labelNames: labelNames, values: map[string]*Sample{}}
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := labelKey(labelValues)
+	sample, ok := c.values[key]
+	if !ok {
+		sample = &Sample{LabelValues: slices.Clone(labelValues)}
+		c.values[key] = sample
+	}
+	sample.Value += delta
+}
+
+// Value returns the current value for the label set, mainly for tests.
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if sample, ok := c.values[labelKey(labelValues)]; ok {
+		return sample.Value
+	}
+	return 0
+}
+
+func (c *CounterVec) Write(w io.Writer) error {
+	c.mu.Lock()
+	samples := make([]Sample, 0, len(c.values))
+	for _, sample := range c.values {
+		samples = append(samples, *sample)
+	}
+	c.mu.Unlock()
+	return WriteFamily(w, c.name, c.help, "counter", c.labelNames, samples)
+}
+
+// HistogramVec counts observations into cumulative buckets per label set.
+type HistogramVec struct {
+	name       string
+	help       string
+	labelNames []string
+	buckets    []float64
+	mu         sync.Mutex
+	values     map[string]*histogram
+}
+
+type histogram struct {
+	labelValues []string
+	counts      []uint64
+	count       uint64
+	sum         float64
+}
+
+func NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	return &HistogramVec{
+		name:       name,
+		help:       help,
+		labelNames: labelNames,
+		buckets:    slices.Sorted(slices.Values(buckets)),
+		values:     map[string]*histogram{},
+	}
+}
+
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := labelKey(labelValues)
+	entry, ok := h.values[key]
+	if !ok {
+		entry = &histogram{labelValues: slices.Clone(labelValues), counts: make([]uint64, len(h.buckets))}
+		h.values[key] = entry
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			entry.counts[i]++
+		}
+	}
+	entry.count++
+	entry.sum += value
+}
+
+// Count returns the number of observations for the label set, mainly for
+// tests.
+func (h *HistogramVec) Count(labelValues ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if entry, ok := h.values[labelKey(labelValues)]; ok {
+		return entry.count
+	}
+	return 0
+}
+
+func (h *HistogramVec) Write(w io.Writer) error {
+	h.mu.Lock()
+	entries := make([]histogram, 0, len(h.values))
+	for _, entry := range h.values {
+		copied := *entry
+		copied.counts = slices.Clone(entry.counts)
+		entries = append(entries, copied)
+	}
+	h.mu.Unlock()
+	slices.SortFunc(entries, func(a, b histogram) int {
+		return slices.Compare(a.labelValues, b.labelValues)
+	})
+
+	out := bufio.NewWriter(w)
+	writeHeader(out, h.name, h.help, "histogram")
+	bucketLabels := append(slices.Clone(h.labelNames), "le")
+	for _, entry := range entries {
+		for i, bound := range h.buckets {
+			writeSample(out, h.name+"_bucket", bucketLabels, append(slices.Clone(entry.labelValues), formatValue(bound)), float64(entry.counts[i]))
+		}
+		writeSample(out, h.name+"_bucket", bucketLabels, append(slices.Clone(entry.labelValues), "+Inf"), float64(entry.count))
+		writeSample(out, h.name+"_sum", h.labelNames, entry.labelValues, entry.sum)
+		writeSample(out, h.name+"_count", h.labelNames, entry.labelValues, float64(entry.count))
+	}
+	return out.Flush()
+}
+
+// WriteFamily writes one metric family in the Prometheus text exposition
+// format. Samples are sorted by label values so output is stable.
+func WriteFamily(w io.Writer, name, help, metricType string, labelNames []string, samples []Sample) error {
+	samples = slices.Clone(samples)
+	slices.SortFunc(samples, func(a, b Sample) int {
+		return slices.Compare(a.LabelValues, b.LabelValues)
+	})
+
+	out := bufio.NewWriter(w)
+	writeHeader(out, name, help, metricType)
+	for _, sample := range samples {
+		writeSample(out, name, labelNames, sample.LabelValues, sample.Value)
+	}
+	return out.Flush()
+}
+
+func writeHeader(w *bufio.Writer, name, help, metricType string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, escapeHelp(help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, metricType)
+}
+
+func writeSample(w *bufio.Writer, name string, labelNames, labelValues []string, value float64) {
+	w.WriteString(name)
+	if len(labelNames) > 0 {
+		w.WriteByte('{')
+		for i, labelName := range labelNames {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			labelValue := ""
+			if i < len(labelValues) {
+				labelValue = labelValues[i]
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", labelName, escapeLabelValue(labelValue))
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatValue(value))
+	w.WriteByte('\n')
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(value string) string {
+	return helpEscaper.Replace(value)
+}
+
+func escapeLabelValue(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func labelKey(labelValues []string) string {
+	return strings.Join(labelValues, "\xff")
+}
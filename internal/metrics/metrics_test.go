@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCounterVecWritesSortedSamples(t *testing.T) {
+	counter := NewCounterVec("uddns_test_total", "Test counter.", "job", "status")
+	counter.Inc("office", "ok")
+	counter.Add(2, "home", "ok")
+	counter.Inc("home", "ok")
+
+	var out strings.Builder
+	if err := counter.Write(&out); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+
+	want := `# HELP uddns_test_total Test counter.
+# TYPE uddns_test_total counter
+uddns_test_total{job="home",status="ok"} 3
+uddns_test_total{job="office",status="ok"} 1
+`
+	if out.String() != want {
+		t.Fatalf("expected:\n%s\ngot:\n%s", want, out.String())
+	}
+}
+
+func TestHistogramVecWritesCumulativeBuckets(t *testing.T) {
+	histogram := NewHistogramVec("uddns_test_seconds", "Test histogram.", []float64{1, 0.1}, "job")
+	histogram.Observe(0.05, "home")
+	histogram.Observe(0.5, "home")
+	histogram.Observe(3, "home")
+
+	var out strings.Builder
+	if err := histogram.Write(&out); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+
+	want := `# HELP uddns_test_seconds Test histogram.
+# TYPE uddns_test_seconds histogram
+uddns_test_seconds_bucket{job="home",le="0.1"} 1
+uddns_test_seconds_bucket{job="home",le="1"} 2
+uddns_test_seconds_bucket{job="home",le="+Inf"} 3
+uddns_test_seconds_sum{job="home"} 3.55
+uddns_test_seconds_count{job="home"} 3
+`
+	if out.String() != want {
+		t.Fatalf("expected:\n%s\ngot:\n%s", want, out.String())
+	}
+	if got := histogram.Count("home"); got != 3 {
+		t.Fatalf("expected 3 observations, got %d", got)
+	}
+}
+
+func TestWriteFamilyEscapesLabelValuesAndHelp(t *testing.T) {
+	var out strings.Builder
+	err := WriteFamily(&out, "uddns_test", "Line one\nline two.", "gauge", []string{"job"}, []Sample{
+		{LabelValues: []string{`a"b\c` + "\n"}, Value: 1},
+	})
+	if err != nil {
+		t.Fatalf("WriteFamily returned error: %v", err)
+	}
+
+	want := `# HELP uddns_test Line one\nline two.
+# TYPE uddns_test gauge
+uddns_test{job="a\"b\\c\n"} 1
+`
+	if out.String() != want {
+		t.Fatalf("expected:\n%s\ngot:\n%s", want, out.String())
+	}
+}
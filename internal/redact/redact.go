@@ -3,8 +3,10 @@ package redact
 import (
 	"errors"
 	"net/url"
+	"slices"
 	"sort"
 	"strings"
+	"unicode"
 )
 
 const replacement = "[REDACTED]"
@@ -44,3 +46,57 @@ func Error(err error, secrets ...string) error {
 	}
 	return errors.New(String(err.Error(), secrets...))
 }
+
+// URLSecrets returns rawURL together with the parts of it that carry
+// credentials: the userinfo password, path segments that look like tokens or
+// IDs, such as the secret segments of Slack and Teams webhook URLs, and the
+// values of query parameters whose names suggest a credential. Errors often
+// quote such parts on their own rather than the whole URL.
+func URLSecrets(rawURL string) []string {
+	secrets := []string{rawURL}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return secrets
+	}
+	if password, ok := parsed.User.Password(); ok {
+		secrets = append(secrets, password)
+	}
+	for segment := range strings.SplitSeq(parsed.Path, "/") {
+		if isTokenLike(segment) {
+			secrets = append(secrets, segment)
+		}
+	}
+	for key, values := range parsed.Query() {
+		if isCredentialKey(key) {
+			secrets = append(secrets, values...)
+		}
+	}
+	return secrets
+}
+
+// isTokenLike reports whether a path segment is long enough and mixed enough
+// to be a token or ID rather than a word such as "services" or "hooks".
+// Short segments are never redacted, since replacing them would mangle
+// unrelated text.
+func isTokenLike(segment string) bool {
+	if len(segment) < 8 {
+		return false
+	}
+	return strings.IndexFunc(segment, func(r rune) bool {
+		return unicode.IsDigit(r) || unicode.IsUpper(r)
+	}) >= 0
+}
+
+// credentialKeys are query parameter names, lowercased and without - and _,
+// that carry credentials in webhook URLs.
+var credentialKeys = []string{"auth", "authorization", "code", "pass", "passwd", "password", "pwd", "sig", "signature"}
+
+// isCredentialKey reports whether a query parameter is known to carry a
+// credential. Other values, such as api-version, are left readable.
+func isCredentialKey(key string) bool {
+	key = strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(key))
+	if slices.Contains(credentialKeys, key) {
+		return true
+	}
+	return strings.Contains(key, "token") || strings.Contains(key, "secret") || strings.Contains(key, "key")
+}
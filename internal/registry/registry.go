@@ -27,17 +27,25 @@ type Entry[T any] struct {
 	New       Constructor[T]
 }
 
+// Registry builds values from registered entries. Besides an entry's own name
+// or config key, a selector may name an instance: a config section under the
+// registry root, such as updaters.cloudflare_personal, whose type key names
+// the entry that builds it. The entry then reads the instance section as if it
+// were its own config key, so one implementation can be configured many times.
 type Registry[T any] struct {
 	kind        string
 	selectorKey string
+	root        string
 	mu          sync.RWMutex
 	entries     []Entry[T]
 }
 
 func New[T any](kind, selectorKey string) *Registry[T] {
+	root, _, _ := strings.Cut(selectorKey, ".")
 	return &Registry[T]{
 		kind:        kind,
 		selectorKey: selectorKey,
+		root:        root,
 	}
 }
 
@@ -116,33 +124,99 @@ func (r *Registry[T]) GetOptional(config ConfigReader, fallbackName string, fall
 	return fallbackName, fallback, nil
 }
 
-func (r *Registry[T]) ConfigKey(selector string) (string, bool) {
-	for _, entry := range r.snapshot() {
+// GetSelected builds the entry or named instance that selector names.
+func (r *Registry[T]) GetSelected(config ConfigReader, selector string) (string, T, error) {
+	return r.getSelected(config, selector, r.snapshot())
+}
+
+// ConfigKey returns the config section a selector reads: the instance section
+// for a named instance, or the entry's own config key.
+func (r *Registry[T]) ConfigKey(config ConfigReader, selector string) (string, error) {
+	selected, err := r.resolve(config, selector, r.snapshot())
+	if err != nil {
+		return "", err
+	}
+	return selected.configKey, nil
+}
+
+// selection is a resolved selector.
+type selection[T any] struct {
+	entry     Entry[T]
+	name      string
+	configKey string
+}
+
+func (r *Registry[T]) resolve(config ConfigReader, selector string, entries []Entry[T]) (selection[T], error) {
+	selector = strings.TrimSpace(selector)
+	if instanceKey := r.root + "." + selector; selector != "" && config.IsSet(instanceKey+".type") {
+		instanceType := strings.TrimSpace(config.GetString(instanceKey + ".type"))
+		for _, entry := range entries {
+			if matches(entry, instanceType) {
+				return selection[T]{entry: entry, name: selector, configKey: instanceKey}, nil
+			}
+		}
+		return selection[T]{}, fmt.Errorf("%s instance %q has unknown type %q; supported values: %s", r.kind, selector, instanceType, strings.Join(names(entries), ", "))
+	}
+	for _, entry := range entries {
 		if matches(entry, selector) {
-			return entry.ConfigKey, true
+			return selection[T]{entry: entry, name: entry.Name, configKey: entry.ConfigKey}, nil
 		}
 	}
-	return "", false
+	return selection[T]{}, fmt.Errorf(
+		"unknown %s %q; supported values: %s, or a named instance with %s.%s.type",
+		r.kind, selector, strings.Join(names(entries), ", "), r.root, selector,
+	)
 }
 
 func (r *Registry[T]) getSelected(config ConfigReader, selector string, entries []Entry[T]) (string, T, error) {
 	var zero T
-	for _, entry := range entries {
-		if !matches(entry, selector) {
-			continue
-		}
+	selected, err := r.resolve(config, selector, entries)
+	if err != nil {
+		return "", zero, err
+	}
 
-		value, err := construct(entry, config)
-		if err == nil {
-			return entry.Name, value, nil
-		}
-		if errors.Is(err, ErrNotConfigured) {
-			return "", zero, fmt.Errorf("selected %s %q requires config key %q", r.kind, selector, entry.ConfigKey)
-		}
-		return "", zero, fmt.Errorf("selected %s %q configuration error: %w", r.kind, selector, err)
+	entryConfig := config
+	if selected.configKey != selected.entry.ConfigKey {
+		entryConfig = instanceConfig{ConfigReader: config, entryKey: selected.entry.ConfigKey, instanceKey: selected.configKey}
+	}
+	value, err := construct(selected.entry, entryConfig)
+	if err == nil {
+		return selected.name, value, nil
 	}
+	if errors.Is(err, ErrNotConfigured) {
+		return "", zero, fmt.Errorf("selected %s %q requires config key %q", r.kind, selector, selected.configKey)
+	}
+	return "", zero, fmt.Errorf("selected %s %q configuration error: %w", r.kind, selector, err)
+}
+
+// instanceConfig presents an instance section at the config key its entry
+// reads from. Keys outside the entry's section pass through unchanged.
+type instanceConfig struct {
+	ConfigReader
+	entryKey    string
+	instanceKey string
+}
 
-	return "", zero, fmt.Errorf("unknown %s %q; supported values: %s", r.kind, selector, strings.Join(names(entries), ", "))
+func (c instanceConfig) GetString(key string) string {
+	return c.ConfigReader.GetString(c.key(key))
+}
+
+func (c instanceConfig) IsSet(key string) bool {
+	return c.ConfigReader.IsSet(c.key(key))
+}
+
+func (c instanceConfig) UnmarshalKey(key string, rawVal any) error {
+	return c.ConfigReader.UnmarshalKey(c.key(key), rawVal)
+}
+
+func (c instanceConfig) key(key string) string {
+	if strings.EqualFold(key, c.entryKey) {
+		return c.instanceKey
+	}
+	if len(key) > len(c.entryKey) && key[len(c.entryKey)] == '.' && strings.EqualFold(key[:len(c.entryKey)], c.entryKey) {
+		return c.instanceKey + key[len(c.entryKey):]
+	}
+	return key
 }
 
 func (r *Registry[T]) snapshot() []Entry[T] {
@@ -311,13 +311,60 @@ func TestRegistryConfigKeyResolvesAliases(t *testing.T) {
 	})
 
 	for _, selector := range []string{"SecondThing", "second_thing", "things.second_thing", "second-thing"} {
-		configKey, ok := r.ConfigKey(selector)
-		if !ok || configKey != "things.second_thing" {
-			t.Fatalf("ConfigKey(%q) = %q, %v", selector, configKey, ok)
+		configKey, err := r.ConfigKey(testConfig{}, selector)
+		if err != nil || configKey != "things.second_thing" {
+			t.Fatalf("ConfigKey(%q) = %q, %v", selector, configKey, err)
 		}
 	}
-	if configKey, ok := r.ConfigKey("missing"); ok || configKey != "" {
-		t.Fatalf("expected missing selector to return no config key, got %q, %v", configKey, ok)
+	if configKey, err := r.ConfigKey(testConfig{}, "missing"); err == nil || configKey != "" {
+		t.Fatalf("expected missing selector to return no config key, got %q, %v", configKey, err)
+	}
+	configKey, err := r.ConfigKey(testConfig{"things.work.type": "second_thing"}, "work")
+	if err != nil || configKey != "things.work" {
+		t.Fatalf("expected instance config key, got %q, %v", configKey, err)
+	}
+}
+
+func TestRegistryGetSelectedBuildsNamedInstance(t *testing.T) {
+	r := New[string]("thing", "things.use")
+	r.Register("Router", "things.router", func(v ConfigReader) (string, error) {
+		if !v.IsSet("things.router") {
+			return "", ErrNotConfigured
+		}
+		return v.GetString("things.router.address") + " via " + v.GetString("things.use"), nil
+	})
+	config := testConfig{
+		"things.use":            "office",
+		"things.router":         "",
+		"things.router.address": "192.0.2.1",
+		"things.office":         "",
+		"things.office.type":    "router",
+		"things.office.address": "192.0.2.2",
+	}
+
+	name, value, err := r.Get(config)
+	if err != nil {
+		t.Fatalf("expected instance lookup to succeed, got %v", err)
+	}
+	if name != "office" || value != "192.0.2.2 via office" {
+		t.Fatalf("expected the office instance settings, got %s/%s", name, value)
+	}
+
+	config["things.use"] = "router"
+	if name, value, _ := r.Get(config); name != "Router" || value != "192.0.2.1 via router" {
+		t.Fatalf("expected the implementation's own settings, got %s/%s", name, value)
+	}
+}
+
+func TestRegistryGetSelectedRejectsUnknownInstanceType(t *testing.T) {
+	r := New[string]("thing", "things.use")
+	r.Register("Router", "things.router", func(ConfigReader) (string, error) {
+		return "router", nil
+	})
+
+	_, _, err := r.Get(testConfig{"things.use": "office", "things.office.type": "switch"})
+	if err == nil || !strings.Contains(err.Error(), `thing instance "office" has unknown type "switch"`) {
+		t.Fatalf("expected unknown instance type error, got %v", err)
 	}
 }
 
@@ -0,0 +1,162 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// SchemaVersion is incremented whenever the persisted document changes in a
+	// way older binaries cannot read safely.
+	SchemaVersion = 1
+	FileName      = "state.json"
+
+	dirMode  = 0700
+	fileMode = 0600
+)
+
+// Job is the durable subset of a job's runtime state. Provider, Updater,
+// Record, and Zone identify the job configuration the state was recorded for,
+// so state is never applied to a job that now points at a different record.
+type Job struct {
+	Provider                  string    `json:"provider"`
+	Updater                   string    `json:"updater"`
+	Record                    string    `json:"record,omitempty"`
+	Zone                      string    `json:"zone,omitempty"`
+	LastAppliedIPv4           string    `json:"last_applied_ipv4,omitempty"`
+	LastAppliedIPv6           string    `json:"last_applied_ipv6,omitempty"`
+	LastNotifiedIPv4          string    `json:"last_notified_ipv4,omitempty"`
+	LastNotifiedIPv6          string    `json:"last_notified_ipv6,omitempty"`
+	LastNotifiedUpdateFailure string    `json:"last_notified_update_failure,omitempty"`
+	FailureCount              int       `json:"failure_count,omitempty"`
+	RetryAfter                time.Time `json:"retry_after,omitzero"`
+}
+
+// SameTarget reports whether two states were recorded for the same provider,
+// updater, and DNS record.
+func (j Job) SameTarget(other Job) bool {
+	return j.Provider == other.Provider &&
+		j.Updater == other.Updater &&
+		j.Record == other.Record &&
+		j.Zone == other.Zone
+}
+
+// Equal reports whether two states would be persisted identically.
+func (j Job) Equal(other Job) bool {
+	if !j.RetryAfter.Equal(other.RetryAfter) {
+		return false
+	}
+	j.RetryAfter, other.RetryAfter = time.Time{}, time.Time{}
+	return j == other
+}
+
+type document struct {
+	Version int            `json:"version"`
+	Jobs    map[string]Job `json:"jobs"`
+}
+
+type Store struct {
+	path string
+}
+
+func New(dir string) (*Store, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("state directory is empty")
+	}
+	return &Store{path: filepath.Join(filepath.Clean(dir), FileName)}, nil
+}
+
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Load returns the persisted jobs keyed by job name. A missing state file is
+// not an error and yields an empty map.
+func (s *Store) Load() (map[string]Job, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return map[string]Job{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file %s: %w", s.path, err)
+	}
+
+	var doc document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode state file %s: %w", s.path, err)
+	}
+	if doc.Version != SchemaVersion {
+		return nil, fmt.Errorf("state file %s has unsupported schema version %d; expected %d", s.path, doc.Version, SchemaVersion)
+	}
+	if doc.Jobs == nil {
+		doc.Jobs = map[string]Job{}
+	}
+	return doc.Jobs, nil
+}
+
+// Save atomically replaces the state file. The document is written to a
+// temporary file in the same directory, synced, and renamed over the previous
+// file so a crash never leaves a truncated state file behind.
+func (s *Store) Save(jobs map[string]Job) error {
+	if jobs == nil {
+		jobs = map[string]Job{}
+	}
+	data, err := json.MarshalIndent(document{Version: SchemaVersion, Jobs: jobs}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+	data = append(data, '\n')
+
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, dirMode); err != nil {
+		return fmt.Errorf("failed to create state directory %s: %w", dir, err)
+	}
+
+	temp, err := os.CreateTemp(dir, "."+FileName+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary state file in %s: %w", dir, err)
+	}
+	tempPath := temp.Name()
+	committed := false
+	defer func() {
+		if !committed {
+			_ = temp.Close()
+			_ = os.Remove(tempPath)
+		}
+	}()
+
+	if err := temp.Chmod(fileMode); err != nil {
+		return fmt.Errorf("failed to set state file permissions: %w", err)
+	}
+	if _, err := temp.Write(data); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := temp.Sync(); err != nil {
+		return fmt.Errorf("failed to sync state file: %w", err)
+	}
+	if err := temp.Close(); err != nil {
+		return fmt.Errorf("failed to close state file: %w", err)
+	}
+	if err := os.Rename(tempPath, s.path); err != nil {
+		return fmt.Errorf("failed to replace state file %s: %w", s.path, err)
+	}
+	committed = true
+	syncDir(dir)
+	return nil
+}
+
+// syncDir makes the rename durable where the platform supports syncing
+// directories. Failures are ignored because the new file is already in place.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	_ = d.Sync()
+	_ = d.Close()
+}
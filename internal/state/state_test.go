@@ -0,0 +1,122 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestLoadMissingFileReturnsEmptyState(t *testing.T) {
+	store, err := New(filepath.Join(t.TempDir(), "missing"))
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	jobs, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if len(jobs) != 0 {
+		t.Fatalf("expected empty state, got %#v", jobs)
+	}
+}
+
+func TestSaveAndLoadRoundTrip(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "state")
+	store, err := New(dir)
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	retryAfter := time.Date(2026, 7, 26, 12, 0, 0, 0, time.UTC)
+	want := map[string]Job{
+		"home": {
+			Provider:         "IpService",
+			Updater:          "Cloudflare",
+			Record:           "home.example.com",
+			LastAppliedIPv4:  "192.0.2.10",
+			LastNotifiedIPv4: "192.0.2.10",
+			FailureCount:     2,
+			RetryAfter:       retryAfter,
+		},
+	}
+
+	if err := store.Save(want); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+	got, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if len(got) != 1 || !got["home"].Equal(want["home"]) {
+		t.Fatalf("expected %#v, got %#v", want, got)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read state dir: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != FileName {
+		t.Fatalf("expected only %s in state dir, got %v", FileName, entries)
+	}
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(store.Path())
+		if err != nil {
+			t.Fatalf("stat state file: %v", err)
+		}
+		if info.Mode().Perm() != fileMode {
+			t.Fatalf("expected state file mode %04o, got %04o", fileMode, info.Mode().Perm())
+		}
+	}
+}
+
+func TestLoadRejectsUnsupportedSchemaVersion(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte(`{"version":99,"jobs":{}}`), 0600); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+	store, err := New(dir)
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	if _, err := store.Load(); err == nil {
+		t.Fatal("expected unsupported schema version to return an error")
+	}
+}
+
+func TestLoadRejectsCorruptFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte(`{"version":`), 0600); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+	store, err := New(dir)
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	if _, err := store.Load(); err == nil {
+		t.Fatal("expected corrupt state file to return an error")
+	}
+}
+
+func TestNewRejectsEmptyDirectory(t *testing.T) {
+	if _, err := New(""); err == nil {
+		t.Fatal("expected empty state directory to return an error")
+	}
+}
+
+func TestJobEqualComparesRetryInstant(t *testing.T) {
+	now := time.Date(2026, 7, 26, 12, 0, 0, 0, time.UTC)
+	left := Job{Provider: "p", RetryAfter: now}
+	right := Job{Provider: "p", RetryAfter: now.In(time.FixedZone("UTC+8", 8*60*60))}
+
+	if !left.Equal(right) {
+		t.Fatal("expected equal retry instants in different locations to compare equal")
+	}
+	right.RetryAfter = now.Add(time.Second)
+	if left.Equal(right) {
+		t.Fatal("expected different retry instants to compare unequal")
+	}
+}
@@ -0,0 +1,72 @@
+// Package status holds the job status types shared by the app and the
+// notifiers that mirror it, so that notifiers do not depend on the app.
+package status
+
+import (
+	"fmt"
+	"time"
+)
+
+// Job is a point-in-time view of one job for health and status reporting.
+type Job struct {
+	Name             string         `json:"name"`
+	Provider         string         `json:"provider"`
+	Updater          string         `json:"updater"`
+	Record           string         `json:"record,omitempty"`
+	Zone             string         `json:"zone,omitempty"`
+	LastStatus       string         `json:"last_status,omitempty"`
+	LastError        string         `json:"last_error,omitempty"`
+	LastDetectedIPv4 string         `json:"last_detected_ipv4,omitempty"`
+	LastDetectedIPv6 string         `json:"last_detected_ipv6,omitempty"`
+	LastAppliedIPv4  string         `json:"last_applied_ipv4,omitempty"`
+	LastAppliedIPv6  string         `json:"last_applied_ipv6,omitempty"`
+	LastRunAt        time.Time      `json:"last_run_at,omitzero"`
+	LastSuccessAt    time.Time      `json:"last_success_at,omitzero"`
+	LastUpdatedAt    time.Time      `json:"last_updated_at,omitzero"`
+	LastVerifiedAt   time.Time      `json:"last_verified_at,omitzero"`
+	FailureCount     int            `json:"failure_count"`
+	RetryAfter       time.Time      `json:"retry_after,omitzero"`
+	NextRunAt        time.Time      `json:"next_run_at,omitzero"`
+	PlannedChanges   []RecordChange `json:"planned_changes,omitempty"`
+}
+
+// Failed reports whether the job's last run ended in an error that would
+// trigger failure backoff.
+func (s Job) Failed() bool {
+	switch s.LastStatus {
+	case "provider_error", "verify_error", "updater_error":
+		return true
+	default:
+		return false
+	}
+}
+
+// RecordChange is one DNS record value a dry run would have written.
+type RecordChange struct {
+	Job          string `json:"job"`
+	Record       string `json:"record"`
+	Type         string `json:"type"`
+	Value        string `json:"value"`
+	Current      string `json:"current,omitempty"`
+	CurrentKnown bool   `json:"current_known"`
+}
+
+func (c RecordChange) String() string {
+	current := "current value unknown"
+	switch {
+	case c.CurrentKnown && c.Current == "":
+		current = "currently unset"
+	case c.CurrentKnown:
+		current = "currently " + c.Current
+	}
+	return fmt.Sprintf("would set %s %s %s (%s)", c.Type, c.Record, c.Value, current)
+}
+
+// Observer is implemented by notifiers that also mirror the status of every
+// job, such as the MQTT notifier's retained state topics. The App calls
+// ObserveStatus after each run and when jobs are restored or reloaded, on the
+// job's goroutine, so implementations must not block. Observers are skipped in
+// dry-run mode and honor their route's job filter.
+type Observer interface {
+	ObserveStatus(Job)
+}
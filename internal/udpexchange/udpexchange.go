@@ -0,0 +1,82 @@
+package udpexchange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// MaxDatagramSize is the largest response Exchange reads.
+const MaxDatagramSize = 65535
+
+// ErrForeignMessage marks a datagram that is not a response to the request.
+// Exchange ignores it and keeps waiting.
+var ErrForeignMessage = errors.New("not a response to this request")
+
+// Exchange sends req over a connected UDP socket and returns the first
+// datagram that match accepts. The request is sent again whenever the next of
+// timeouts passes without a response. match returns ErrForeignMessage for
+// stray datagrams; any other error ends the exchange. Cancelling ctx unblocks
+// the exchange and returns ctx's error.
+func Exchange(ctx context.Context, conn net.Conn, req []byte, timeouts []time.Duration, match func([]byte) error) ([]byte, error) {
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	buf := make([]byte, MaxDatagramSize)
+	for _, timeout := range timeouts {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if _, err := conn.Write(req); err != nil {
+			return nil, err
+		}
+		if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+			return nil, err
+		}
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				var netErr net.Error
+				if errors.As(err, &netErr) && netErr.Timeout() {
+					break
+				}
+				return nil, err
+			}
+			err = match(buf[:n])
+			if errors.Is(err, ErrForeignMessage) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			return append([]byte(nil), buf[:n]...), nil
+		}
+	}
+	return nil, fmt.Errorf("no response from %s after %d attempts", conn.RemoteAddr(), len(timeouts))
+}
+
+// HostPort normalizes a host or host:port, with IPv6 addresses optionally in
+// brackets, to host:port using defaultPort when the port is missing.
+func HostPort(addr, defaultPort string) (string, error) {
+	addr = strings.TrimSpace(addr)
+	if host, port, err := net.SplitHostPort(addr); err == nil {
+		if host == "" || port == "" {
+			return "", fmt.Errorf("invalid address %q", addr)
+		}
+		return addr, nil
+	}
+	host := strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+	if _, err := netip.ParseAddr(host); err != nil && (host == "" || strings.ContainsAny(host, ":[]/ ")) {
+		return "", fmt.Errorf("invalid address %q", addr)
+	}
+	return net.JoinHostPort(host, defaultPort), nil
+}
@@ -0,0 +1,129 @@
+package udpexchange
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// echoServer answers every request after dropping the first drop requests,
+// sending a stray datagram before each answer.
+func echoServer(t *testing.T, drop int32) (string, *atomic.Int32) {
+	t.Helper()
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	var requests atomic.Int32
+	go func() {
+		buf := make([]byte, MaxDatagramSize)
+		for {
+			n, client, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if requests.Add(1) <= drop {
+				continue
+			}
+			_, _ = conn.WriteTo([]byte("stray"), client)
+			_, _ = conn.WriteTo(append([]byte("re:"), buf[:n]...), client)
+		}
+	}()
+	return conn.LocalAddr().String(), &requests
+}
+
+func dial(t *testing.T, addr string) net.Conn {
+	t.Helper()
+	conn, err := net.Dial("udp4", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func matchReply(message []byte) error {
+	if !strings.HasPrefix(string(message), "re:") {
+		return ErrForeignMessage
+	}
+	return nil
+}
+
+func TestExchangeRetransmitsAndSkipsForeignMessages(t *testing.T) {
+	addr, requests := echoServer(t, 1)
+	timeouts := []time.Duration{50 * time.Millisecond, time.Second}
+
+	response, err := Exchange(context.Background(), dial(t, addr), []byte("ping"), timeouts, matchReply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(response) != "re:ping" || requests.Load() != 2 {
+		t.Fatalf("unexpected response %q after %d requests", response, requests.Load())
+	}
+}
+
+func TestExchangeReportsMatchErrorsAndSilence(t *testing.T) {
+	addr, _ := echoServer(t, 0)
+	rejected := errors.New("rejected")
+	_, err := Exchange(context.Background(), dial(t, addr), []byte("ping"), []time.Duration{time.Second}, func(message []byte) error {
+		if err := matchReply(message); err != nil {
+			return err
+		}
+		return rejected
+	})
+	if !errors.Is(err, rejected) {
+		t.Fatalf("expected the match error, got %v", err)
+	}
+
+	silent, requests := echoServer(t, 100)
+	_, err = Exchange(context.Background(), dial(t, silent), []byte("ping"), []time.Duration{10 * time.Millisecond, 10 * time.Millisecond}, matchReply)
+	if err == nil || !strings.Contains(err.Error(), "no response from") || !strings.Contains(err.Error(), "after 2 attempts") {
+		t.Fatalf("expected no response error, got %v", err)
+	}
+	if requests.Load() != 2 {
+		t.Fatalf("expected 2 requests, got %d", requests.Load())
+	}
+}
+
+func TestExchangeStopsWhenContextIsCanceled(t *testing.T) {
+	addr, _ := echoServer(t, 100)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := Exchange(ctx, dial(t, addr), []byte("ping"), []time.Duration{10 * time.Second}, matchReply)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("exchange was not interrupted, took %s", elapsed)
+	}
+}
+
+func TestHostPort(t *testing.T) {
+	for _, tt := range []struct {
+		addr string
+		want string
+	}{
+		{"192.0.2.1", "192.0.2.1:53"},
+		{" 192.0.2.1:5353 ", "192.0.2.1:5353"},
+		{"2001:db8::1", "[2001:db8::1]:53"},
+		{"[2001:db8::1]", "[2001:db8::1]:53"},
+		{"[2001:db8::1]:5353", "[2001:db8::1]:5353"},
+		{"dns.example.com", "dns.example.com:53"},
+	} {
+		if got, err := HostPort(tt.addr, "53"); err != nil || got != tt.want {
+			t.Errorf("HostPort(%q) = %q, %v; want %q", tt.addr, got, err, tt.want)
+		}
+	}
+	for _, addr := range []string{"", ":53", "192.0.2.1:", "dns example.com", "dns.example.com/path"} {
+		if got, err := HostPort(addr, "53"); err == nil {
+			t.Errorf("HostPort(%q) = %q; want an error", addr, got)
+		}
+	}
+}
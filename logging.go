@@ -43,6 +43,10 @@ type logConfigReader interface {
 
 var activeLogFile *calendarRotatingWriter
 
+// consoleLogOutput receives console logs. Commands that print machine-readable
+// output to stdout move logs to stderr.
+var consoleLogOutput = os.Stdout
+
 func configureLogger() {
 	configureLoggerFromConfig(nil)
 }
@@ -51,8 +55,8 @@ func configureLoggerFromConfig(v logConfigReader) {
 	config := resolveLogConfig(v)
 	level, levelOK := parseLogLevel(config.level.value)
 	handlers := []slog.Handler{
-		tint.NewTextHandler(os.Stdout, &tint.Options{
-			NoColor:    !isatty.IsTerminal(os.Stdout.Fd()),
+		tint.NewTextHandler(consoleLogOutput, &tint.Options{
+			NoColor:    !isatty.IsTerminal(consoleLogOutput.Fd()),
 			Level:      level,
 			TimeFormat: time.DateTime,
 		}),
@@ -6,27 +6,48 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"net"
 	"os"
 	"os/signal"
+	"slices"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/we11adam/uddns/app"
 	"github.com/we11adam/uddns/internal/config"
+	"github.com/we11adam/uddns/internal/httpserver"
+	"github.com/we11adam/uddns/internal/state"
 	"github.com/we11adam/uddns/notifier"
 	"github.com/we11adam/uddns/provider"
 	"github.com/we11adam/uddns/updater"
 
+	_ "github.com/we11adam/uddns/notifier/bark"
+	_ "github.com/we11adam/uddns/notifier/dingtalk"
 	_ "github.com/we11adam/uddns/notifier/discord"
+	_ "github.com/we11adam/uddns/notifier/email"
+	_ "github.com/we11adam/uddns/notifier/feishu"
+	_ "github.com/we11adam/uddns/notifier/gotify"
+	_ "github.com/we11adam/uddns/notifier/matrix"
+	_ "github.com/we11adam/uddns/notifier/mqtt"
+	_ "github.com/we11adam/uddns/notifier/ntfy"
+	_ "github.com/we11adam/uddns/notifier/pushover"
+	_ "github.com/we11adam/uddns/notifier/slack"
+	_ "github.com/we11adam/uddns/notifier/teams"
 	_ "github.com/we11adam/uddns/notifier/telegram"
+	_ "github.com/we11adam/uddns/notifier/webhook"
+	_ "github.com/we11adam/uddns/notifier/wecom"
+	_ "github.com/we11adam/uddns/provider/dns_lookup"
 	_ "github.com/we11adam/uddns/provider/ip_service"
 	_ "github.com/we11adam/uddns/provider/netif"
 	_ "github.com/we11adam/uddns/provider/routeros"
+	_ "github.com/we11adam/uddns/provider/stun"
+	_ "github.com/we11adam/uddns/provider/upnp"
 	_ "github.com/we11adam/uddns/updater/aliyun"
 	_ "github.com/we11adam/uddns/updater/cloudflare"
 	_ "github.com/we11adam/uddns/updater/duckdns"
 	_ "github.com/we11adam/uddns/updater/lightdns"
+	_ "github.com/we11adam/uddns/updater/rfc2136"
 	_ "github.com/we11adam/uddns/updater/scaleway"
 )
 
@@ -35,10 +56,15 @@ func main() {
 }
 
 type runtimeConfig struct {
-	notifierName string
-	notifier     notifier.Notifier
-	jobs         []app.Job
-	interval     time.Duration
+	configPath     string
+	watchConfig    bool
+	notifiers      []notifier.Route
+	jobs           []app.Job
+	interval       time.Duration
+	maxConcurrency int
+	stateStore     *state.Store
+	httpListen     string
+	dryRun         bool
 }
 
 func run(args []string) int {
@@ -56,6 +82,8 @@ func runWithIO(
 		case "config":
 			configureLogger()
 			return runConfigCommand(args[1:])
+		case "run":
+			return runRunCommand(args[1:], stdout, stderr)
 		case "version":
 			return runVersionCommand(args[1:], stdout, stderr)
 		case "self-update":
@@ -76,15 +104,49 @@ func runWithIO(
 	}
 
 	configureLogger()
-	rt, ok := loadRuntimeFromFlags("uddns", args)
+	var dryRun bool
+	rt, ok := loadRuntimeFromFlags("uddns", args, &dryRun)
 	if !ok {
 		return 2
 	}
+	rt.dryRun = dryRun
+	return runDaemon(rt)
+}
 
+func newApp(rt *runtimeConfig) *app.App {
+	a := app.NewApp(rt.jobs, "", nil, rt.interval)
+	a.SetNotifiers(rt.notifiers)
+	a.SetMaxConcurrency(rt.maxConcurrency)
+	a.SetDryRun(rt.dryRun)
+	if rt.dryRun {
+		slog.Warn("dry run enabled; DNS records and notifications will not be changed")
+	}
+	if rt.stateStore != nil {
+		a.UseStateStore(rt.stateStore)
+	}
+	return a
+}
+
+func runDaemon(rt *runtimeConfig) int {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	app.NewApp(rt.jobs, rt.notifierName, rt.notifier, rt.interval).Run(ctx)
+	a := newApp(rt)
+	if rt.httpListen != "" {
+		server, err := httpserver.Listen(rt.httpListen, a, version)
+		if err != nil {
+			slog.Error("failed to start HTTP server", "error", err)
+			return 1
+		}
+		slog.Info("HTTP server listening", "address", server.Addr().String())
+		go func() {
+			if err := server.Serve(ctx); err != nil {
+				slog.Error("HTTP server stopped", "error", err)
+			}
+		}()
+	}
+	go watchReloads(ctx, a, rt)
+	a.Run(ctx)
 	return 0
 }
 
@@ -96,15 +158,18 @@ func runConfigCommand(args []string) int {
 
 	switch args[0] {
 	case "check":
-		rt, ok := loadRuntimeFromFlags("uddns config check", args[1:])
+		rt, ok := loadRuntimeFromFlags("uddns config check", args[1:], nil)
 		if !ok {
 			return 1
 		}
 		slog.Info(
 			"config valid",
-			"notifier", rt.notifierName,
+			"notifiers", notifierNames(rt.notifiers),
 			"jobs", len(rt.jobs),
 			"interval", rt.interval,
+			"max_concurrency", rt.maxConcurrency,
+			"http_listen", rt.httpListen,
+			"state_file", stateFileLogValue(rt.stateStore),
 		)
 		return 0
 	default:
@@ -113,10 +178,14 @@ func runConfigCommand(args []string) int {
 	}
 }
 
-func loadRuntimeFromFlags(name string, args []string) (*runtimeConfig, bool) {
+// loadRuntimeFromFlags parses -c and, when dryRun is not nil, --dry-run.
+func loadRuntimeFromFlags(name string, args []string, dryRun *bool) (*runtimeConfig, bool) {
 	var configPath string
 	flags := flag.NewFlagSet(name, flag.ContinueOnError)
 	flags.StringVar(&configPath, "c", "", "Path to the configuration file")
+	if dryRun != nil {
+		flags.BoolVar(dryRun, "dry-run", false, dryRunUsage)
+	}
 	if err := flags.Parse(args); err != nil {
 		return nil, false
 	}
@@ -141,31 +210,118 @@ func loadRuntime(configPath string) (*runtimeConfig, error) {
 	}
 	configureLoggerFromConfig(cfg)
 	slog.Info("using config file", "config", cfg.Path())
+	return buildRuntime(cfg)
+}
 
+// buildRuntime validates cfg and builds every job, provider, updater, and
+// notifier it describes.
+func buildRuntime(cfg *config.Config) (*runtimeConfig, error) {
 	jobs, err := loadJobs(cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	notifierName, n, err := notifier.GetNotifier(cfg)
+	interval, rawInterval, err := cfg.Interval()
 	if err != nil {
-		return nil, fmt.Errorf("notifier configuration error: %w", err)
+		slog.Warn("invalid update interval, using default", "env_var", "UDDNS_INTERVAL", "value", rawInterval, "default", config.DefaultInterval, "error", err)
 	}
-	slog.Info("notifier selected", "notifier", notifierName)
 
-	interval, rawInterval, err := cfg.Interval()
+	maxConcurrency, err := cfg.MaxConcurrency()
 	if err != nil {
-		slog.Warn("invalid update interval, using default", "env_var", "UDDNS_INTERVAL", "value", rawInterval, "default", config.DefaultInterval, "error", err)
+		return nil, fmt.Errorf("concurrency configuration error: %w", err)
+	}
+
+	httpListen := cfg.HTTPListen()
+	if httpListen != "" {
+		if _, _, err := net.SplitHostPort(httpListen); err != nil {
+			return nil, fmt.Errorf("http configuration error: invalid listen address %q: %w", httpListen, err)
+		}
+	}
+
+	var stateStore *state.Store
+	if stateDir := cfg.StateDir(); stateDir != "" {
+		stateStore, err = state.New(stateDir)
+		if err != nil {
+			return nil, fmt.Errorf("state configuration error: %w", err)
+		}
+		slog.Info("job state persistence enabled", "state_file", stateStore.Path())
+	}
+
+	// Notifiers are built last because some, such as MQTT, hold connections
+	// that would leak if a later setting failed validation.
+	notifiers, err := loadNotifiers(cfg, jobs)
+	if err != nil {
+		return nil, fmt.Errorf("notifier configuration error: %w", err)
 	}
 
 	return &runtimeConfig{
-		notifierName: notifierName,
-		notifier:     n,
-		jobs:         jobs,
-		interval:     interval,
+		configPath:     cfg.Path(),
+		watchConfig:    cfg.WatchConfig(),
+		notifiers:      notifiers,
+		jobs:           jobs,
+		interval:       interval,
+		maxConcurrency: maxConcurrency,
+		stateStore:     stateStore,
+		httpListen:     httpListen,
 	}, nil
 }
 
+// loadNotifiers builds the selected notifiers and checks that their job
+// filters name configured jobs and that they accept the job names.
+func loadNotifiers(cfg *config.Config, jobs []app.Job) ([]notifier.Route, error) {
+	routes, err := notifier.GetNotifiers(cfg)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(jobs))
+	for i, job := range jobs {
+		names[i] = job.Name
+	}
+	for _, route := range routes {
+		for _, name := range route.Jobs {
+			if !slices.Contains(names, name) {
+				closeNotifiers(routes)
+				return nil, fmt.Errorf("notifier %q filters on unknown job %q", route.Name, name)
+			}
+		}
+		if validator, ok := route.Notifier.(notifier.JobValidator); ok {
+			if err := validator.ValidateJobs(names); err != nil {
+				closeNotifiers(routes)
+				return nil, fmt.Errorf("notifier %q: %w", route.Name, err)
+			}
+		}
+		slog.Info("notifier selected", "notifier", route.Name, "reasons", route.Reasons, "jobs", route.Jobs)
+	}
+	return routes, nil
+}
+
+// closeNotifiers closes the notifiers of routes that were built but will not
+// be used.
+func closeNotifiers(routes []notifier.Route) {
+	for _, route := range routes {
+		if closer, ok := route.Notifier.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				slog.Warn("failed to close notifier", "notifier", route.Name, "error", err)
+			}
+		}
+	}
+}
+
+func notifierNames(routes []notifier.Route) []string {
+	names := make([]string, len(routes))
+	for i, route := range routes {
+		names[i] = route.Name
+	}
+	return names
+}
+
+func stateFileLogValue(store *state.Store) string {
+	if store == nil {
+		return ""
+	}
+	return store.Path()
+}
+
 func loadJobs(cfg *config.Config) ([]app.Job, error) {
 	jobConfigs, ok, err := cfg.Jobs()
 	if err != nil {
@@ -184,8 +340,11 @@ func loadJobs(cfg *config.Config) ([]app.Job, error) {
 
 	jobs := make([]app.Job, 0, len(jobConfigs))
 	seen := map[string]struct{}{}
+	// Jobs naming the same provider share one instance so the app can look up
+	// its addresses once per cycle.
+	providers := map[string]provider.Provider{}
 	for i, jobConfig := range jobConfigs {
-		job, err := loadConfiguredJob(cfg, jobConfig, i)
+		job, err := loadConfiguredJob(cfg, jobConfig, i, providers)
 		if err != nil {
 			return nil, err
 		}
@@ -220,7 +379,7 @@ func loadDefaultJob(cfg *config.Config) (app.Job, error) {
 	return app.NewJob("default", providerName, p, updaterName, u, record, zone, app.AllFamilies(), verify), nil
 }
 
-func loadConfiguredJob(cfg *config.Config, jobConfig config.Job, index int) (app.Job, error) {
+func loadConfiguredJob(cfg *config.Config, jobConfig config.Job, index int, providers map[string]provider.Provider) (app.Job, error) {
 	name := strings.TrimSpace(jobConfig.Name)
 	if name == "" {
 		name = fmt.Sprintf("job-%d", index+1)
@@ -245,8 +404,15 @@ func loadConfiguredJob(cfg *config.Config, jobConfig config.Job, index int) (app
 	if err != nil {
 		return app.Job{}, fmt.Errorf("job %q verify error: %w", name, err)
 	}
+	var interval time.Duration
+	if value := strings.TrimSpace(jobConfig.Interval); value != "" {
+		interval, err = config.ParseInterval(value)
+		if err != nil {
+			return app.Job{}, fmt.Errorf("job %q invalid interval: %w", name, err)
+		}
+	}
 
-	overrides, err := jobOverrides(jobConfig)
+	overrides, err := jobOverrides(cfg, jobConfig)
 	if err != nil {
 		return app.Job{}, fmt.Errorf("job %q updater error: %w", name, err)
 	}
@@ -255,6 +421,11 @@ func loadConfiguredJob(cfg *config.Config, jobConfig config.Job, index int) (app
 	if err != nil {
 		return app.Job{}, fmt.Errorf("job %q provider error: %w", name, err)
 	}
+	if shared, ok := providers[providerName]; ok {
+		p = shared
+	} else {
+		providers[providerName] = p
+	}
 	updaterName, u, err := updater.GetUpdater(jobReader)
 	if err != nil {
 		return app.Job{}, fmt.Errorf("job %q updater error: %w", name, err)
@@ -263,24 +434,26 @@ func loadConfiguredJob(cfg *config.Config, jobConfig config.Job, index int) (app
 		return app.Job{}, err
 	}
 
-	slog.Info("job selected", "job", name, "provider", providerName, "updater", updaterName, "record", record, "zone", zone, "families", families.String(), "verify", verify)
-	return app.NewJob(name, providerName, p, updaterName, u, record, zone, families, verify), nil
+	slog.Info("job selected", "job", name, "provider", providerName, "updater", updaterName, "record", record, "zone", zone, "families", families.String(), "verify", verify, "interval", interval)
+	job := app.NewJob(name, providerName, p, updaterName, u, record, zone, families, verify)
+	job.Interval = interval
+	return job, nil
 }
 
 func defaultJobRecord(cfg *config.Config, updaterName string) (string, string) {
-	configKey, ok := updater.ConfigKey(updaterName)
-	if !ok {
+	configKey, err := updater.ConfigKey(cfg, updaterName)
+	if err != nil {
 		return "", ""
 	}
 	return strings.TrimSpace(cfg.GetString(configKey + ".domain")), strings.TrimSpace(cfg.GetString(configKey + ".zone"))
 }
 
-func jobOverrides(job config.Job) (map[string]any, error) {
+func jobOverrides(cfg *config.Config, job config.Job) (map[string]any, error) {
 	record := strings.TrimSpace(job.Record)
 	zone := strings.TrimSpace(job.Zone)
-	configKey, ok := updater.ConfigKey(job.Updater)
-	if !ok {
-		return nil, fmt.Errorf("unknown updater %q", strings.TrimSpace(job.Updater))
+	configKey, err := updater.ConfigKey(cfg, job.Updater)
+	if err != nil {
+		return nil, err
 	}
 	overrides := map[string]any{
 		"providers.use":       strings.TrimSpace(job.Provider),
@@ -4,10 +4,14 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/spf13/viper"
 	"github.com/we11adam/uddns/internal/config"
+	"github.com/we11adam/uddns/notifier"
 )
 
 func TestParseLogLevel(t *testing.T) {
@@ -175,6 +179,225 @@ jobs:
 	}
 }
 
+func TestLoadRuntimeSharesProviderAcrossJobs(t *testing.T) {
+	t.Setenv("UDDNS_INTERVAL", "")
+	path := writeTempConfig(t, `
+max_concurrency: 2
+providers:
+  ip_service:
+    - ifconfig.me
+updaters:
+  duckdns:
+    token: test-token
+jobs:
+  - name: home
+    provider: ip_service
+    updater: duckdns
+    record: home
+  - name: office
+    provider: ip_service
+    updater: duckdns
+    record: office
+`)
+
+	rt, err := loadRuntime(path)
+	if err != nil {
+		t.Fatalf("loadRuntime returned error: %v", err)
+	}
+	if rt.maxConcurrency != 2 {
+		t.Fatalf("expected max_concurrency 2, got %d", rt.maxConcurrency)
+	}
+	if len(rt.jobs) != 2 || rt.jobs[0].Provider != rt.jobs[1].Provider {
+		t.Fatalf("expected jobs to share one provider instance, got %#v", rt.jobs)
+	}
+}
+
+func TestLoadRuntimeUsesNamedInstances(t *testing.T) {
+	t.Setenv("UDDNS_INTERVAL", "")
+	config := `
+providers:
+  router_home:
+    type: routeros
+    endpoint: https://192.0.2.1
+    username: home
+  router_office:
+    type: routeros
+    endpoint: https://192.0.2.2
+    username: office
+updaters:
+  duckdns_personal:
+    type: duckdns
+    token: personal-token
+  duckdns_work:
+    type: duckdns
+    token: work-token
+jobs:
+  - name: home
+    provider: router_home
+    updater: duckdns_personal
+    record: home
+  - name: office
+    provider: router_office
+    updater: duckdns_work
+    record: office
+`
+
+	rt, err := loadRuntime(writeTempConfig(t, config))
+	if err != nil {
+		t.Fatalf("loadRuntime returned error: %v", err)
+	}
+	home, office := rt.jobs[0], rt.jobs[1]
+	if home.ProviderName != "router_home" || office.ProviderName != "router_office" || home.Provider == office.Provider {
+		t.Fatalf("expected one provider per instance, got %q and %q", home.ProviderName, office.ProviderName)
+	}
+	if home.UpdaterName != "duckdns_personal" || office.UpdaterName != "duckdns_work" || home.Record != "home" {
+		t.Fatalf("expected jobs to use their updater instances, got %#v", rt.jobs)
+	}
+
+	_, err = loadRuntime(writeTempConfig(t, strings.Replace(config, "    username: office\n", "", 1)))
+	if err == nil || !strings.Contains(err.Error(), "missing required RouterOS fields") {
+		t.Fatalf("expected the instance's own settings to be validated, got %v", err)
+	}
+}
+
+func TestLoadRuntimeSelectsSeveralNotifiers(t *testing.T) {
+	t.Setenv("UDDNS_INTERVAL", "")
+	config := `
+providers:
+  ip_service:
+    - ifconfig.me
+updaters:
+  duckdns:
+    token: test-token
+jobs:
+  - name: home
+    provider: ip_service
+    updater: duckdns
+    record: home
+notifiers:
+  use: [telegram_alerts, discord]
+  telegram_alerts:
+    type: telegram
+    token: 1234567890:telegram-bot-token
+    chat_id: "-1001234567890"
+    reasons: [update_failure]
+    jobs: [home]
+  discord:
+    url: https://discord.com/api/webhooks/1/token
+`
+
+	rt, err := loadRuntime(writeTempConfig(t, config))
+	if err != nil {
+		t.Fatalf("loadRuntime returned error: %v", err)
+	}
+	if names := notifierNames(rt.notifiers); !slices.Equal(names, []string{"telegram_alerts", "Discord"}) {
+		t.Fatalf("expected both notifiers, got %v", names)
+	}
+	alerts := rt.notifiers[0]
+	if !slices.Equal(alerts.Reasons, []notifier.Reason{notifier.ReasonUpdateFailure}) || !slices.Equal(alerts.Jobs, []string{"home"}) {
+		t.Fatalf("expected telegram filters to be read, got %#v", alerts)
+	}
+	if len(rt.notifiers[1].Reasons) != 0 || len(rt.notifiers[1].Jobs) != 0 {
+		t.Fatalf("expected discord to receive everything, got %#v", rt.notifiers[1])
+	}
+
+	for _, tt := range []struct {
+		name    string
+		from    string
+		to      string
+		message string
+	}{
+		{"unknown reason", "[update_failure]", "[outage]", `unsupported reason "outage"`},
+		{"unknown job", "jobs: [home]", "jobs: [office]", `filters on unknown job "office"`},
+		{"duplicate", "use: [telegram_alerts, discord]", "use: telegram_alerts, discord, Discord", `selected more than once`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadRuntime(writeTempConfig(t, strings.Replace(config, tt.from, tt.to, 1)))
+			if err == nil || !strings.Contains(err.Error(), tt.message) {
+				t.Fatalf("expected error containing %q, got %v", tt.message, err)
+			}
+		})
+	}
+}
+
+func TestLoadRuntimeRejectsJobNamesANotifierCannotUse(t *testing.T) {
+	t.Setenv("UDDNS_INTERVAL", "")
+	_, err := loadRuntime(writeTempConfig(t, `
+providers:
+  ip_service:
+    - ifconfig.me
+updaters:
+  duckdns:
+    token: test-token
+jobs:
+  - name: home.lan
+    provider: ip_service
+    updater: duckdns
+    record: home
+  - name: home_lan
+    provider: ip_service
+    updater: duckdns
+    record: office
+notifiers:
+  mqtt:
+    broker: tcp://broker.example:1883
+`))
+	if err == nil || !strings.Contains(err.Error(), `MQTT topics of jobs "home.lan" and "home_lan"`) {
+		t.Fatalf("expected colliding MQTT topics to be rejected, got %v", err)
+	}
+}
+
+func TestRunConfigCheckRejectsInvalidMaxConcurrency(t *testing.T) {
+	t.Setenv("UDDNS_INTERVAL", "")
+	path := writeTempConfig(t, `
+max_concurrency: 0
+providers:
+  ip_service:
+    - ifconfig.me
+updaters:
+  duckdns:
+    token: test-token
+    domain: home
+`)
+
+	code := run([]string{"config", "check", "-c", path})
+	if code != 1 {
+		t.Fatalf("expected invalid max_concurrency to fail, got exit code %d", code)
+	}
+}
+
+func TestRunConfigCheckValidatesHTTPListenAddress(t *testing.T) {
+	t.Setenv("UDDNS_INTERVAL", "")
+	tests := []struct {
+		listen string
+		want   int
+	}{
+		{listen: "127.0.0.1:9090", want: 0},
+		{listen: ":9090", want: 0},
+		{listen: "9090", want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.listen, func(t *testing.T) {
+			path := writeTempConfig(t, `
+http:
+  listen: "`+tt.listen+`"
+providers:
+  ip_service:
+    - ifconfig.me
+updaters:
+  duckdns:
+    token: test-token
+    domain: home
+`)
+
+			code := run([]string{"config", "check", "-c", path})
+			if code != tt.want {
+				t.Fatalf("expected exit code %d, got %d", tt.want, code)
+			}
+		})
+	}
+}
+
 func TestRunConfigCheckSupportsScaleway(t *testing.T) {
 	t.Setenv("UDDNS_INTERVAL", "")
 	path := writeTempConfig(t, `
@@ -201,8 +424,35 @@ jobs:
 	}
 }
 
+func TestRunConfigCheckSupportsRFC2136(t *testing.T) {
+	t.Setenv("UDDNS_INTERVAL", "")
+	path := writeTempConfig(t, `
+providers:
+  ip_service:
+    - ifconfig.me
+updaters:
+  rfc2136:
+    server: 192.0.2.53
+    tsig_key: uddns-key
+    tsig_algorithm: hmac-sha512
+    tsig_secret: c2VjcmV0
+jobs:
+  - name: home
+    provider: ip_service
+    updater: rfc2136
+    record: home.internal.example
+    zone: internal.example
+    verify: updater_api
+`)
+
+	code := run([]string{"config", "check", "-c", path})
+	if code != 0 {
+		t.Fatalf("expected RFC2136 config check to succeed, got exit code %d", code)
+	}
+}
+
 func TestJobOverridesOnlySelectedUpdater(t *testing.T) {
-	overrides, err := jobOverrides(config.Job{
+	overrides, err := jobOverrides(loadTestConfig(t, "interval: 30s\n"), config.Job{
 		Provider: "ip_service",
 		Updater:  "duckdns",
 		Record:   "home",
@@ -225,7 +475,7 @@ func TestJobOverridesOnlySelectedUpdater(t *testing.T) {
 }
 
 func TestJobOverridesClearInheritedZone(t *testing.T) {
-	overrides, err := jobOverrides(config.Job{
+	overrides, err := jobOverrides(loadTestConfig(t, "interval: 30s\n"), config.Job{
 		Provider: "ip_service",
 		Updater:  "cloudflare",
 		Record:   "home.example.com",
@@ -284,6 +534,63 @@ jobs:
 	}
 }
 
+func TestLoadRuntimeReadsPerJobInterval(t *testing.T) {
+	t.Setenv("UDDNS_INTERVAL", "")
+	path := writeTempConfig(t, `
+providers:
+  ip_service:
+    - ifconfig.me
+updaters:
+  duckdns:
+    token: test-token
+jobs:
+  - name: hourly
+    provider: ip_service
+    updater: duckdns
+    record: hourly
+    interval: 1h
+  - name: default-interval
+    provider: ip_service
+    updater: duckdns
+    record: other
+`)
+
+	rt, err := loadRuntime(path)
+	if err != nil {
+		t.Fatalf("loadRuntime returned error: %v", err)
+	}
+	if rt.jobs[0].Interval != time.Hour || rt.jobs[1].Interval != 0 {
+		t.Fatalf("expected intervals 1h/0s, got %s/%s", rt.jobs[0].Interval, rt.jobs[1].Interval)
+	}
+}
+
+func TestRunConfigCheckRejectsInvalidJobInterval(t *testing.T) {
+	t.Setenv("UDDNS_INTERVAL", "")
+	for _, interval := range []string{"soon", "1s"} {
+		t.Run(interval, func(t *testing.T) {
+			path := writeTempConfig(t, `
+providers:
+  ip_service:
+    - ifconfig.me
+updaters:
+  duckdns:
+    token: test-token
+jobs:
+  - name: home
+    provider: ip_service
+    updater: duckdns
+    record: home
+    interval: `+interval+`
+`)
+
+			code := run([]string{"config", "check", "-c", path})
+			if code != 1 {
+				t.Fatalf("expected invalid job interval to fail, got exit code %d", code)
+			}
+		})
+	}
+}
+
 func TestRunConfigCheckRejectsUnsupportedUpdaterAPIVerify(t *testing.T) {
 	t.Setenv("UDDNS_INTERVAL", "")
 	path := writeTempConfig(t, `
@@ -360,3 +667,13 @@ func writeTempConfig(t *testing.T, content string) string {
 	}
 	return path
 }
+
+func loadTestConfig(t *testing.T, content string) *config.Config {
+	t.Helper()
+
+	cfg, err := config.Load(writeTempConfig(t, content))
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	return cfg
+}
@@ -0,0 +1,147 @@
+package bark
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+
+	"github.com/we11adam/uddns/internal/proxyurl"
+	"github.com/we11adam/uddns/internal/redact"
+	"github.com/we11adam/uddns/notifier"
+)
+
+const (
+	requestTimeout    = 10 * time.Second
+	responseBodyLimit = 256 << 10
+	defaultServer     = "https://api.day.app"
+)
+
+type Bark struct {
+	// Server is the Bark server base URL. Empty means https://api.day.app.
+	Server string `mapstructure:"server"`
+	// DeviceKey identifies the iOS device to push to.
+	DeviceKey string `mapstructure:"device_key"`
+	// Group optionally groups the notifications on the device.
+	Group string `mapstructure:"group"`
+	Proxy string `mapstructure:"proxy"`
+	hc    *resty.Client
+}
+
+type message struct {
+	DeviceKey string `json:"device_key"`
+	Title     string `json:"title,omitempty"`
+	Body      string `json:"body"`
+	Level     string `json:"level"`
+	Group     string `json:"group,omitempty"`
+}
+
+type apiResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func init() {
+	notifier.Register("Bark", "notifiers.bark", func(v notifier.ConfigReader) (notifier.Notifier, error) {
+		if !v.IsSet("notifiers.bark") {
+			return nil, notifier.ErrNotConfigured
+		}
+
+		bark := Bark{}
+		err := v.UnmarshalKey("notifiers.bark", &bark)
+		if err != nil {
+			return nil, err
+		}
+
+		return New(&bark)
+	})
+}
+
+func New(config *Bark) (*Bark, error) {
+	if config == nil {
+		return nil, fmt.Errorf("Bark config is nil")
+	}
+	if config.DeviceKey == "" {
+		return nil, fmt.Errorf("Bark device_key is required")
+	}
+
+	bark := *config
+	if bark.Server == "" {
+		bark.Server = defaultServer
+	}
+	server, err := url.Parse(bark.Server)
+	if err != nil || (server.Scheme != "http" && server.Scheme != "https") || server.Host == "" {
+		return nil, fmt.Errorf("Bark server must be an absolute http or https URL")
+	}
+
+	var proxy *url.URL
+	if bark.Proxy != "" {
+		if proxy, err = proxyurl.Parse(bark.Proxy); err != nil {
+			return nil, fmt.Errorf("invalid Bark proxy configuration: %w", err)
+		}
+	}
+	bark.hc = bark.newHTTPClient(proxy)
+	return &bark, nil
+}
+
+func (b *Bark) newHTTPClient(proxy *url.URL) *resty.Client {
+	client := resty.New().
+		SetTimeout(requestTimeout).
+		SetResponseBodyLimit(responseBodyLimit).
+		SetHeader("Content-Type", "application/json; charset=utf-8").
+		SetBaseURL(strings.TrimRight(b.Server, "/"))
+	if proxy != nil {
+		client.SetProxy(proxy.String())
+	}
+	return client
+}
+
+// Notify pushes the notification. Bark interruption levels map to iOS focus
+// behavior: failures are timeSensitive so they break through focus modes,
+// successes are passive and do not light up the screen.
+func (b *Bark) Notify(ctx context.Context, notification notifier.Notification) error {
+	level := "active"
+	switch notification.Reason {
+	case notifier.ReasonUpdateFailure:
+		level = "timeSensitive"
+	case notifier.ReasonUpdateSuccess:
+		level = "passive"
+	}
+
+	resp, err := b.hc.R().SetContext(ctx).SetBody(&message{
+		DeviceKey: b.DeviceKey,
+		Title:     notification.Title,
+		Body:      notification.Message,
+		Level:     level,
+		Group:     b.Group,
+	}).Post("/push")
+	if err != nil {
+		return b.redactError(err)
+	}
+
+	apiResp := apiResponse{}
+	decodeErr := json.Unmarshal(resp.Body(), &apiResp)
+	if !resp.IsSuccess() || (decodeErr == nil && apiResp.Code != http.StatusOK) {
+		return b.redactError(b.apiError(resp.StatusCode(), apiResp))
+	}
+	if decodeErr != nil {
+		return b.redactError(fmt.Errorf("failed to decode Bark API response: %w", decodeErr))
+	}
+	return nil
+}
+
+func (b *Bark) apiError(statusCode int, response apiResponse) error {
+	if response.Message == "" {
+		return fmt.Errorf("Bark API request failed: HTTP status %d, code %d", statusCode, response.Code)
+	}
+	return fmt.Errorf("Bark API request failed: HTTP status %d, code %d, message %q", statusCode, response.Code, response.Message)
+}
+
+func (b *Bark) redactError(err error) error {
+	return redact.Error(err, b.DeviceKey)
+}
@@ -0,0 +1,85 @@
+package bark
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/we11adam/uddns/internal/testutil"
+	"github.com/we11adam/uddns/notifier"
+)
+
+func TestNewValidatesConfig(t *testing.T) {
+	bark, err := New(&Bark{DeviceKey: "device-key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bark.hc.BaseURL != defaultServer {
+		t.Fatalf("expected default server, got %q", bark.hc.BaseURL)
+	}
+	for _, config := range []*Bark{
+		{},
+		{DeviceKey: "device-key", Server: "bark.example.com"},
+		{DeviceKey: "device-key", Proxy: "socks5://proxy.example"},
+	} {
+		if _, err := New(config); err == nil {
+			t.Fatalf("expected %+v to be rejected", config)
+		}
+	}
+}
+
+func TestNotifyPushesWithLevelByReason(t *testing.T) {
+	tests := []struct {
+		reason notifier.Reason
+		level  string
+	}{
+		{notifier.ReasonIPChange, "active"},
+		{notifier.ReasonUpdateSuccess, "passive"},
+		{notifier.ReasonUpdateFailure, "timeSensitive"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.reason), func(t *testing.T) {
+			var body message
+			var path string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path = r.URL.Path
+				_ = json.NewDecoder(r.Body).Decode(&body)
+				_, _ = w.Write([]byte(`{"code":200,"message":"success"}`))
+			}))
+			defer server.Close()
+
+			bark, err := New(&Bark{Server: server.URL, DeviceKey: "device-key", Group: "uddns"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := bark.Notify(context.Background(), notifier.Notification{Title: "home", Message: "test", Reason: tt.reason}); err != nil {
+				t.Fatal(err)
+			}
+			want := message{DeviceKey: "device-key", Title: "home", Body: "test", Level: tt.level, Group: "uddns"}
+			if path != "/push" || body != want {
+				t.Fatalf("unexpected request: path=%q message=%+v", path, body)
+			}
+		})
+	}
+}
+
+func TestNotifyChecksBarkAPIResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"code":400,"message":"failed to get device token: device-key not found"}`))
+	}))
+	defer server.Close()
+
+	bark, err := New(&Bark{Server: server.URL, DeviceKey: "device-key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = bark.Notify(context.Background(), notifier.Notification{Message: "test"})
+	if err == nil || !strings.Contains(err.Error(), "HTTP status 400") || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("expected Bark API error, got %v", err)
+	}
+	testutil.AssertTokenRedacted(t, err.Error(), "device-key")
+}
@@ -0,0 +1,148 @@
+package dingtalk
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+
+	"github.com/we11adam/uddns/internal/proxyurl"
+	"github.com/we11adam/uddns/internal/redact"
+	"github.com/we11adam/uddns/notifier"
+)
+
+const (
+	requestTimeout    = 10 * time.Second
+	responseBodyLimit = 256 << 10
+	webhookURL        = "https://oapi.dingtalk.com/robot/send"
+)
+
+type DingTalk struct {
+	// AccessToken is the access_token of the group robot webhook URL.
+	AccessToken string `mapstructure:"access_token"`
+	// Secret is the robot's signing secret, starting with SEC. It is required
+	// when the robot uses the signature security setting.
+	Secret string `mapstructure:"secret"`
+	Proxy  string `mapstructure:"proxy"`
+	hc     *resty.Client
+}
+
+type textMessage struct {
+	MsgType string `json:"msgtype"`
+	Text    struct {
+		Content string `json:"content"`
+	} `json:"text"`
+}
+
+type apiResponse struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+func init() {
+	notifier.Register("DingTalk", "notifiers.dingtalk", func(v notifier.ConfigReader) (notifier.Notifier, error) {
+		if !v.IsSet("notifiers.dingtalk") {
+			return nil, notifier.ErrNotConfigured
+		}
+
+		dingtalk := DingTalk{}
+		err := v.UnmarshalKey("notifiers.dingtalk", &dingtalk)
+		if err != nil {
+			return nil, err
+		}
+
+		return New(&dingtalk)
+	})
+}
+
+func New(config *DingTalk) (*DingTalk, error) {
+	if config == nil {
+		return nil, fmt.Errorf("DingTalk config is nil")
+	}
+	if config.AccessToken == "" {
+		return nil, fmt.Errorf("DingTalk access_token is required")
+	}
+
+	var proxy *url.URL
+	if config.Proxy != "" {
+		parsed, err := proxyurl.Parse(config.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DingTalk proxy configuration: %w", err)
+		}
+		proxy = parsed
+	}
+
+	dingtalk := *config
+	dingtalk.hc = newHTTPClient(proxy)
+	return &dingtalk, nil
+}
+
+func newHTTPClient(proxy *url.URL) *resty.Client {
+	client := resty.New().
+		SetTimeout(requestTimeout).
+		SetResponseBodyLimit(responseBodyLimit).
+		SetHeader("Content-Type", "application/json").
+		SetBaseURL(webhookURL)
+	if proxy != nil {
+		client.SetProxy(proxy.String())
+	}
+	return client
+}
+
+func (d *DingTalk) Notify(ctx context.Context, notification notifier.Notification) error {
+	message := textMessage{MsgType: "text"}
+	message.Text.Content = notification.Text()
+
+	request := d.hc.R().
+		SetContext(ctx).
+		SetQueryParam("access_token", d.AccessToken).
+		SetBody(&message)
+	if d.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().UnixMilli(), 10)
+		request.SetQueryParam("timestamp", timestamp).SetQueryParam("sign", sign(d.Secret, timestamp))
+	}
+	resp, err := request.Post("")
+	if err != nil {
+		return d.redactError(err)
+	}
+
+	apiResp := apiResponse{}
+	decodeErr := json.Unmarshal(resp.Body(), &apiResp)
+	if !resp.IsSuccess() {
+		return d.redactError(d.apiError(resp.StatusCode(), apiResp))
+	}
+	if decodeErr != nil {
+		return d.redactError(fmt.Errorf("failed to decode DingTalk API response: %w", decodeErr))
+	}
+	if apiResp.ErrCode != 0 {
+		return d.redactError(d.apiError(resp.StatusCode(), apiResp))
+	}
+	return nil
+}
+
+// sign implements DingTalk's robot signature: the Base64 HMAC-SHA256, keyed
+// by the secret, of the millisecond timestamp and the secret joined by a
+// newline. DingTalk rejects timestamps more than an hour old.
+func sign(secret, timestamp string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "\n" + secret))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (d *DingTalk) apiError(statusCode int, response apiResponse) error {
+	if response.ErrMsg == "" {
+		return fmt.Errorf("DingTalk API request failed: HTTP status %d, errcode %d", statusCode, response.ErrCode)
+	}
+	return fmt.Errorf("DingTalk API request failed: HTTP status %d, errcode %d, errmsg %q", statusCode, response.ErrCode, response.ErrMsg)
+}
+
+func (d *DingTalk) redactError(err error) error {
+	return redact.Error(err, d.AccessToken, d.Secret)
+}
@@ -0,0 +1,73 @@
+package dingtalk
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/we11adam/uddns/internal/testutil"
+	"github.com/we11adam/uddns/notifier"
+)
+
+func TestSign(t *testing.T) {
+	// Expected value computed independently with Python's hmac module.
+	if got := sign("SECsecret", "1700000000000"); got != "0QWYb8Ux63Sm4BhHaJNL3lv5mqW1sLFoks7vu+HFFi4=" {
+		t.Fatalf("sign() = %q", got)
+	}
+}
+
+func TestNotifySendsSignedTextMessage(t *testing.T) {
+	var body textMessage
+	var query map[string][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		_, _ = w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+	}))
+	defer server.Close()
+
+	dingtalk, err := New(&DingTalk{AccessToken: "access-token", Secret: "SECsecret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dingtalk.hc.SetBaseURL(server.URL)
+	if err := dingtalk.Notify(context.Background(), notifier.Notification{Message: "IPv4 address changed"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if body.MsgType != "text" || body.Text.Content != "IPv4 address changed" {
+		t.Fatalf("unexpected message: %+v", body)
+	}
+	timestamp := query["timestamp"]
+	if len(timestamp) != 1 || query["access_token"][0] != "access-token" || query["sign"][0] != sign("SECsecret", timestamp[0]) {
+		t.Fatalf("unexpected query: %v", query)
+	}
+}
+
+func TestNotifyChecksDingTalkAPIResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("sign") {
+			t.Error("expected no signature without a secret")
+		}
+		_, _ = w.Write([]byte(`{"errcode":310000,"errmsg":"keywords not in content, token access-token"}`))
+	}))
+	defer server.Close()
+
+	dingtalk, err := New(&DingTalk{AccessToken: "access-token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dingtalk.hc.SetBaseURL(server.URL)
+	err = dingtalk.Notify(context.Background(), notifier.Notification{Message: "test"})
+	if err == nil || !strings.Contains(err.Error(), "errcode 310000") || !strings.Contains(err.Error(), "keywords not in content") {
+		t.Fatalf("expected DingTalk API error, got %v", err)
+	}
+	testutil.AssertTokenRedacted(t, err.Error(), "access-token")
+
+	if _, err := New(&DingTalk{}); err == nil {
+		t.Fatal("expected missing access_token to be rejected")
+	}
+}
@@ -0,0 +1,319 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/we11adam/uddns/internal/redact"
+	"github.com/we11adam/uddns/notifier"
+)
+
+const (
+	requestTimeout = 10 * time.Second
+
+	securityStartTLS = "starttls"
+	securityTLS      = "tls"
+	securityNone     = "none"
+
+	authPlain = "plain"
+	authLogin = "login"
+)
+
+type Email struct {
+	Host string `mapstructure:"host"`
+	// Port defaults to 465 with implicit TLS, 587 with STARTTLS, and 25
+	// without encryption.
+	Port int `mapstructure:"port"`
+	// Security is starttls (default), tls for implicit TLS, or none.
+	Security string `mapstructure:"security"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	// Auth is plain (default) or login. It is used when Username is set.
+	Auth string   `mapstructure:"auth"`
+	From string   `mapstructure:"from"`
+	To   []string `mapstructure:"to"`
+	CC   []string `mapstructure:"cc"`
+	// HTML sends the message as text/html, for templates that produce HTML.
+	HTML       bool `mapstructure:"html"`
+	from       *mail.Address
+	recipients []*mail.Address
+	tlsConfig  *tls.Config
+}
+
+func init() {
+	notifier.Register("Email", "notifiers.email", func(v notifier.ConfigReader) (notifier.Notifier, error) {
+		if !v.IsSet("notifiers.email") {
+			return nil, notifier.ErrNotConfigured
+		}
+
+		email := Email{}
+		err := v.UnmarshalKey("notifiers.email", &email)
+		if err != nil {
+			return nil, err
+		}
+
+		return New(&email)
+	})
+}
+
+func New(config *Email) (*Email, error) {
+	if config == nil {
+		return nil, fmt.Errorf("email config is nil")
+	}
+	if config.Host == "" {
+		return nil, fmt.Errorf("email host is required")
+	}
+
+	email := *config
+	email.Security = strings.ToLower(strings.TrimSpace(email.Security))
+	switch email.Security {
+	case "":
+		email.Security = securityStartTLS
+	case securityStartTLS, securityTLS, securityNone:
+	default:
+		return nil, fmt.Errorf("unsupported email security %q; supported values: starttls, tls, none", config.Security)
+	}
+	if email.Port == 0 {
+		email.Port = defaultPort(email.Security)
+	}
+	if email.Port < 1 || email.Port > 65535 {
+		return nil, fmt.Errorf("email port must be between 1 and 65535")
+	}
+
+	email.Auth = strings.ToLower(strings.TrimSpace(email.Auth))
+	switch email.Auth {
+	case "":
+		email.Auth = authPlain
+	case authPlain, authLogin:
+	default:
+		return nil, fmt.Errorf("unsupported email auth %q; supported values: plain, login", config.Auth)
+	}
+	if email.Password != "" && email.Username == "" {
+		return nil, fmt.Errorf("email password requires username")
+	}
+
+	var err error
+	if email.from, err = mail.ParseAddress(email.From); err != nil {
+		return nil, fmt.Errorf("invalid email from address: %w", err)
+	}
+	if len(email.To) == 0 {
+		return nil, fmt.Errorf("email to must list at least one address")
+	}
+	for _, list := range [][]string{email.To, email.CC} {
+		for _, value := range list {
+			address, err := mail.ParseAddress(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid email recipient %q: %w", value, err)
+			}
+			email.recipients = append(email.recipients, address)
+		}
+	}
+	email.tlsConfig = &tls.Config{ServerName: email.Host, MinVersion: tls.VersionTLS12}
+	return &email, nil
+}
+
+func defaultPort(security string) int {
+	switch security {
+	case securityTLS:
+		return 465
+	case securityNone:
+		return 25
+	default:
+		return 587
+	}
+}
+
+func (e *Email) Notify(ctx context.Context, notification notifier.Notification) error {
+	err := e.send(ctx, e.message(notification, time.Now()))
+	// Servers may echo the base64 AUTH arguments in their replies.
+	return redact.Error(
+		err,
+		e.Password,
+		base64.StdEncoding.EncodeToString([]byte(e.Password)),
+		base64.StdEncoding.EncodeToString([]byte("\x00"+e.Username+"\x00"+e.Password)),
+	)
+}
+
+func (e *Email) send(ctx context.Context, message []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	address := net.JoinHostPort(e.Host, strconv.Itoa(e.Port))
+	dialer := &net.Dialer{}
+	var conn net.Conn
+	var err error
+	if e.Security == securityTLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: e.tlsConfig}).DialContext(ctx, "tcp", address)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	// net/smtp has no context support; closing the connection unblocks it.
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	client, err := smtp.NewClient(conn, e.Host)
+	if err != nil {
+		_ = conn.Close()
+		return e.contextError(ctx, fmt.Errorf("SMTP handshake failed: %w", err))
+	}
+	defer client.Close()
+
+	if e.Security == securityStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("SMTP server does not support STARTTLS")
+		}
+		if err := client.StartTLS(e.tlsConfig); err != nil {
+			return e.contextError(ctx, fmt.Errorf("SMTP STARTTLS failed: %w", err))
+		}
+	}
+	if e.Username != "" {
+		if err := client.Auth(e.auth()); err != nil {
+			return e.contextError(ctx, fmt.Errorf("SMTP authentication failed: %w", err))
+		}
+	}
+	if err := client.Mail(e.from.Address); err != nil {
+		return e.contextError(ctx, fmt.Errorf("SMTP MAIL FROM failed: %w", err))
+	}
+	for _, recipient := range e.recipients {
+		if err := client.Rcpt(recipient.Address); err != nil {
+			return e.contextError(ctx, fmt.Errorf("SMTP RCPT TO %s failed: %w", recipient.Address, err))
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return e.contextError(ctx, fmt.Errorf("SMTP DATA failed: %w", err))
+	}
+	if _, err := writer.Write(message); err != nil {
+		return e.contextError(ctx, fmt.Errorf("failed to send email: %w", err))
+	}
+	if err := writer.Close(); err != nil {
+		return e.contextError(ctx, fmt.Errorf("SMTP server rejected email: %w", err))
+	}
+	return client.Quit()
+}
+
+// contextError reports a cancelled or timed out context instead of the
+// connection error caused by closing the connection.
+func (e *Email) contextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("%w: %w", ctxErr, err)
+	}
+	return err
+}
+
+func (e *Email) auth() smtp.Auth {
+	if e.Auth == authLogin {
+		return &loginAuth{username: e.Username, password: e.Password, host: e.Host}
+	}
+	return smtp.PlainAuth("", e.Username, e.Password, e.Host)
+}
+
+func (e *Email) message(notification notifier.Notification, now time.Time) []byte {
+	var to, cc []string
+	for _, recipient := range e.recipients[:len(e.To)] {
+		to = append(to, recipient.String())
+	}
+	for _, recipient := range e.recipients[len(e.To):] {
+		cc = append(cc, recipient.String())
+	}
+	contentType := "text/plain"
+	if e.HTML {
+		contentType = "text/html"
+	}
+
+	var message bytes.Buffer
+	header := func(name, value string) {
+		fmt.Fprintf(&message, "%s: %s\r\n", name, value)
+	}
+	header("From", e.from.String())
+	header("To", strings.Join(to, ", "))
+	if len(cc) > 0 {
+		header("Cc", strings.Join(cc, ", "))
+	}
+	header("Subject", mime.QEncoding.Encode("utf-8", subject(notification)))
+	header("Date", now.Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	header("Content-Type", contentType+"; charset=utf-8")
+	header("Content-Transfer-Encoding", "quoted-printable")
+	message.WriteString("\r\n")
+
+	body := quotedprintable.NewWriter(&message)
+	_, _ = body.Write([]byte(strings.ReplaceAll(notification.Message, "\n", "\r\n")))
+	_ = body.Close()
+	return message.Bytes()
+}
+
+// subject returns the notification title or, without one, a subject built
+// from the reason and job.
+func subject(notification notifier.Notification) string {
+	if notification.Title != "" {
+		return notification.Title
+	}
+	var summary string
+	switch notification.Reason {
+	case notifier.ReasonIPChange:
+		summary = "IP address changed"
+	case notifier.ReasonUpdateSuccess:
+		summary = "DNS records updated"
+	case notifier.ReasonUpdateFailure:
+		summary = "DNS update failed"
+	default:
+		summary = "Notification"
+	}
+	if notification.Job == "" {
+		return "[uddns] " + summary
+	}
+	return "[uddns] " + notification.Job + ": " + summary
+}
+
+// loginAuth implements the LOGIN mechanism, which some servers offer instead
+// of PLAIN. Like smtp.PlainAuth, it only sends credentials over TLS or to
+// localhost.
+type loginAuth struct {
+	username string
+	password string
+	host     string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch strings.ToLower(strings.TrimSuffix(strings.TrimSpace(string(fromServer)), ":")) {
+	case "username":
+		return []byte(a.username), nil
+	case "password":
+		return []byte(a.password), nil
+	default:
+		return nil, fmt.Errorf("unexpected LOGIN challenge %q", fromServer)
+	}
+}
+
+func isLocalhost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
@@ -0,0 +1,380 @@
+package email
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/we11adam/uddns/internal/testutil"
+	"github.com/we11adam/uddns/notifier"
+)
+
+// smtpServer is a minimal in-process SMTP server that records what it
+// receives.
+type smtpServer struct {
+	tlsConfig *tls.Config
+	// startTLS advertises STARTTLS on plain connections.
+	startTLS bool
+	// authReply answers AUTH; empty accepts the credentials.
+	authReply string
+
+	mu       sync.Mutex
+	received []received
+}
+
+type received struct {
+	tls         bool
+	credentials string
+	from        string
+	to          []string
+	data        string
+}
+
+// newTLSConfig returns a server certificate for 127.0.0.1 and a pool that
+// trusts it.
+func newTLSConfig(t *testing.T) (*tls.Config, *x509.CertPool) {
+	t.Helper()
+	server := httptest.NewUnstartedServer(nil)
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	return server.TLS, pool
+}
+
+func startSMTPServer(t *testing.T, server *smtpServer, implicitTLS bool) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if implicitTLS {
+		listener = tls.NewListener(listener, server.tlsConfig)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn, implicitTLS)
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *smtpServer) serve(conn net.Conn, secure bool) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	current := received{tls: secure}
+	reply := func(format string, args ...any) {
+		_ = text.PrintfLine(format, args...)
+	}
+	reply("220 localhost ESMTP")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		command, argument, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(command) {
+		case "EHLO", "HELO":
+			reply("250-localhost")
+			if s.startTLS && !current.tls {
+				reply("250-STARTTLS")
+			}
+			reply("250 AUTH PLAIN LOGIN")
+		case "STARTTLS":
+			reply("220 ready")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+			text = textproto.NewConn(conn)
+			current.tls = true
+		case "AUTH":
+			mechanism, initial, _ := strings.Cut(argument, " ")
+			if strings.EqualFold(mechanism, "LOGIN") {
+				reply("334 %s", base64.StdEncoding.EncodeToString([]byte("Username:")))
+				username, _ := text.ReadLine()
+				reply("334 %s", base64.StdEncoding.EncodeToString([]byte("Password:")))
+				password, _ := text.ReadLine()
+				initial = username + " " + password
+			}
+			current.credentials = decodeCredentials(initial)
+			if s.authReply != "" {
+				reply("%s", s.authReply)
+				continue
+			}
+			reply("235 2.7.0 authenticated")
+		case "MAIL":
+			current.from = strings.Trim(strings.TrimPrefix(argument, "FROM:"), "<>")
+			reply("250 ok")
+		case "RCPT":
+			current.to = append(current.to, strings.Trim(strings.TrimPrefix(argument, "TO:"), "<>"))
+			reply("250 ok")
+		case "DATA":
+			reply("354 send data")
+			data, err := io.ReadAll(text.DotReader())
+			if err != nil {
+				return
+			}
+			// DotReader turns CRLF line endings into LF.
+			current.data = string(data)
+			s.mu.Lock()
+			s.received = append(s.received, current)
+			s.mu.Unlock()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func decodeCredentials(encoded string) string {
+	var parts []string
+	for field := range strings.FieldsSeq(encoded) {
+		decoded, _ := base64.StdEncoding.DecodeString(field)
+		parts = append(parts, strings.Trim(string(decoded), "\x00"))
+	}
+	return strings.ReplaceAll(strings.Join(parts, " "), "\x00", " ")
+}
+
+func (s *smtpServer) messages() []received {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]received(nil), s.received...)
+}
+
+func testNotification() notifier.Notification {
+	return notifier.Notification{
+		Message: "home: DNS update failed for IPv4 192.0.2.10: boom",
+		Reason:  notifier.ReasonUpdateFailure,
+		Job:     "home",
+	}
+}
+
+func TestNewValidatesConfig(t *testing.T) {
+	valid := func() *Email {
+		return &Email{Host: "smtp.example.com", From: "uddns@example.com", To: []string{"ops@example.com"}}
+	}
+	email, err := New(valid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if email.Security != securityStartTLS || email.Port != 587 || email.Auth != authPlain {
+		t.Fatalf("unexpected defaults: security=%q port=%d auth=%q", email.Security, email.Port, email.Auth)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*Email)
+		want   string
+	}{
+		{"missing host", func(e *Email) { e.Host = "" }, "host is required"},
+		{"security", func(e *Email) { e.Security = "ssl" }, "unsupported email security"},
+		{"auth", func(e *Email) { e.Auth = "cram-md5" }, "unsupported email auth"},
+		{"port", func(e *Email) { e.Port = 70000 }, "port must be"},
+		{"password without username", func(e *Email) { e.Password = "secret" }, "requires username"},
+		{"from", func(e *Email) { e.From = "not an address" }, "invalid email from address"},
+		{"no recipients", func(e *Email) { e.To = nil }, "at least one address"},
+		{"cc", func(e *Email) { e.CC = []string{"ops@"} }, "invalid email recipient"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := valid()
+			tt.modify(config)
+			_, err := New(config)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestNotifySendsPlainTextEmailWithPlainAuth(t *testing.T) {
+	server := &smtpServer{}
+	port := startSMTPServer(t, server, false)
+	email, err := New(&Email{
+		Host:     "127.0.0.1",
+		Port:     port,
+		Security: "none",
+		Username: "uddns",
+		Password: "smtp-password",
+		From:     "UDDNS <uddns@example.com>",
+		To:       []string{"ops@example.com"},
+		CC:       []string{"Oncall <oncall@example.com>"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := email.Notify(context.Background(), testNotification()); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := server.messages()
+	if len(messages) != 1 {
+		t.Fatalf("expected one email, got %d", len(messages))
+	}
+	got := messages[0]
+	if got.credentials != "uddns smtp-password" || got.from != "uddns@example.com" {
+		t.Fatalf("unexpected envelope: %+v", got)
+	}
+	if strings.Join(got.to, ",") != "ops@example.com,oncall@example.com" {
+		t.Fatalf("expected To and Cc recipients, got %v", got.to)
+	}
+	for _, want := range []string{
+		"From: \"UDDNS\" <uddns@example.com>\n",
+		"To: <ops@example.com>\n",
+		"Cc: \"Oncall\" <oncall@example.com>\n",
+		"Subject: [uddns] home: DNS update failed\n",
+		"Content-Type: text/plain; charset=utf-8\n",
+		"\n\nhome: DNS update failed for IPv4 192.0.2.10: boom",
+	} {
+		if !strings.Contains(got.data, want) {
+			t.Fatalf("expected message to contain %q, got:\n%s", want, got.data)
+		}
+	}
+}
+
+func TestNotifyUsesSTARTTLSWithLoginAuth(t *testing.T) {
+	tlsConfig, pool := newTLSConfig(t)
+	server := &smtpServer{tlsConfig: tlsConfig, startTLS: true}
+	port := startSMTPServer(t, server, false)
+	email, err := New(&Email{
+		Host:     "127.0.0.1",
+		Port:     port,
+		Username: "uddns",
+		Password: "smtp-password",
+		Auth:     "LOGIN",
+		From:     "uddns@example.com",
+		To:       []string{"ops@example.com"},
+		HTML:     true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	email.tlsConfig.RootCAs = pool
+	notification := testNotification()
+	notification.Title = "Überwachung"
+	if err := email.Notify(context.Background(), notification); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := server.messages()
+	if len(messages) != 1 || !messages[0].tls || messages[0].credentials != "uddns smtp-password" {
+		t.Fatalf("expected one authenticated email over TLS, got %+v", messages)
+	}
+	if !strings.Contains(messages[0].data, "Content-Type: text/html; charset=utf-8\n") ||
+		!strings.Contains(messages[0].data, "Subject: =?utf-8?q?=C3=9Cberwachung?=\n") {
+		t.Fatalf("unexpected message:\n%s", messages[0].data)
+	}
+}
+
+func TestNotifyUsesImplicitTLS(t *testing.T) {
+	tlsConfig, pool := newTLSConfig(t)
+	server := &smtpServer{tlsConfig: tlsConfig}
+	port := startSMTPServer(t, server, true)
+	email, err := New(&Email{Host: "127.0.0.1", Port: port, Security: "tls", From: "uddns@example.com", To: []string{"ops@example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	email.tlsConfig.RootCAs = pool
+	if err := email.Notify(context.Background(), testNotification()); err != nil {
+		t.Fatal(err)
+	}
+	if messages := server.messages(); len(messages) != 1 || !messages[0].tls {
+		t.Fatalf("expected one email over TLS, got %+v", messages)
+	}
+}
+
+func TestNotifyRequiresSTARTTLSSupport(t *testing.T) {
+	server := &smtpServer{}
+	port := startSMTPServer(t, server, false)
+	email, err := New(&Email{Host: "127.0.0.1", Port: port, From: "uddns@example.com", To: []string{"ops@example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = email.Notify(context.Background(), testNotification())
+	if err == nil || !strings.Contains(err.Error(), "does not support STARTTLS") {
+		t.Fatalf("expected STARTTLS error, got %v", err)
+	}
+}
+
+func TestNotifyRedactsPasswordFromErrors(t *testing.T) {
+	password := "smtp-password"
+	server := &smtpServer{authReply: "535 5.7.8 credentials uddns/" + password + " rejected"}
+	port := startSMTPServer(t, server, false)
+	email, err := New(&Email{
+		Host:     "127.0.0.1",
+		Port:     port,
+		Security: "none",
+		Username: "uddns",
+		Password: password,
+		From:     "uddns@example.com",
+		To:       []string{"ops@example.com"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = email.Notify(context.Background(), testNotification())
+	if err == nil || !strings.Contains(err.Error(), "SMTP authentication failed") {
+		t.Fatalf("expected authentication error, got %v", err)
+	}
+	testutil.AssertTokenRedacted(t, err.Error(), password)
+}
+
+func TestNotifyCancelsStalledServer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		// Never send the greeting.
+		_, _ = io.Copy(io.Discard, bufio.NewReader(conn))
+	}()
+
+	email, err := New(&Email{
+		Host:     "127.0.0.1",
+		Port:     listener.Addr().(*net.TCPAddr).Port,
+		Security: "none",
+		From:     "uddns@example.com",
+		To:       []string{"ops@example.com"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- email.Notify(ctx, testNotification()) }()
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), context.DeadlineExceeded.Error()) {
+			t.Fatalf("expected deadline error, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Notify did not return after the context expired")
+	}
+}
@@ -0,0 +1,147 @@
+package feishu
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+
+	"github.com/we11adam/uddns/internal/proxyurl"
+	"github.com/we11adam/uddns/internal/redact"
+	"github.com/we11adam/uddns/notifier"
+)
+
+const (
+	requestTimeout    = 10 * time.Second
+	responseBodyLimit = 256 << 10
+)
+
+type Feishu struct {
+	// URL is the custom bot webhook URL, on open.feishu.cn for Feishu or
+	// open.larksuite.com for Lark.
+	URL string `mapstructure:"url"`
+	// Secret is the bot's signing secret, required when the bot uses the
+	// signature verification security setting.
+	Secret string `mapstructure:"secret"`
+	Proxy  string `mapstructure:"proxy"`
+	hc     *resty.Client
+}
+
+type textMessage struct {
+	Timestamp string `json:"timestamp,omitempty"`
+	Sign      string `json:"sign,omitempty"`
+	MsgType   string `json:"msg_type"`
+	Content   struct {
+		Text string `json:"text"`
+	} `json:"content"`
+}
+
+type apiResponse struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+func init() {
+	notifier.Register("Feishu", "notifiers.feishu", func(v notifier.ConfigReader) (notifier.Notifier, error) {
+		if !v.IsSet("notifiers.feishu") {
+			return nil, notifier.ErrNotConfigured
+		}
+
+		feishu := Feishu{}
+		err := v.UnmarshalKey("notifiers.feishu", &feishu)
+		if err != nil {
+			return nil, err
+		}
+
+		return New(&feishu)
+	})
+}
+
+func New(config *Feishu) (*Feishu, error) {
+	if config == nil {
+		return nil, fmt.Errorf("Feishu config is nil")
+	}
+	if config.URL == "" {
+		return nil, fmt.Errorf("Feishu url is required")
+	}
+	target, err := url.Parse(config.URL)
+	if err != nil || target.Scheme != "https" || target.Host == "" {
+		return nil, fmt.Errorf("Feishu url must be an absolute https URL")
+	}
+
+	var proxy *url.URL
+	if config.Proxy != "" {
+		if proxy, err = proxyurl.Parse(config.Proxy); err != nil {
+			return nil, fmt.Errorf("invalid Feishu proxy configuration: %w", err)
+		}
+	}
+
+	feishu := *config
+	feishu.hc = newHTTPClient(feishu.URL, proxy)
+	return &feishu, nil
+}
+
+func newHTTPClient(webhookURL string, proxy *url.URL) *resty.Client {
+	client := resty.New().
+		SetTimeout(requestTimeout).
+		SetResponseBodyLimit(responseBodyLimit).
+		SetHeader("Content-Type", "application/json").
+		SetBaseURL(webhookURL)
+	if proxy != nil {
+		client.SetProxy(proxy.String())
+	}
+	return client
+}
+
+func (f *Feishu) Notify(ctx context.Context, notification notifier.Notification) error {
+	message := textMessage{MsgType: "text"}
+	message.Content.Text = notification.Text()
+	if f.Secret != "" {
+		message.Timestamp = strconv.FormatInt(time.Now().Unix(), 10)
+		message.Sign = sign(f.Secret, message.Timestamp)
+	}
+
+	resp, err := f.hc.R().SetContext(ctx).SetBody(&message).Post("")
+	if err != nil {
+		return f.redactError(err)
+	}
+
+	apiResp := apiResponse{}
+	decodeErr := json.Unmarshal(resp.Body(), &apiResp)
+	if !resp.IsSuccess() {
+		return f.redactError(f.apiError(resp.StatusCode(), apiResp))
+	}
+	if decodeErr != nil {
+		return f.redactError(fmt.Errorf("failed to decode Feishu API response: %w", decodeErr))
+	}
+	if apiResp.Code != 0 {
+		return f.redactError(f.apiError(resp.StatusCode(), apiResp))
+	}
+	return nil
+}
+
+// sign implements Feishu's bot signature: the Base64 HMAC-SHA256 of an empty
+// message, keyed by the second timestamp and the secret joined by a newline.
+// Feishu rejects timestamps more than an hour away from its clock.
+func sign(secret, timestamp string) string {
+	mac := hmac.New(sha256.New, []byte(timestamp+"\n"+secret))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (f *Feishu) apiError(statusCode int, response apiResponse) error {
+	if response.Msg == "" {
+		return fmt.Errorf("Feishu API request failed: HTTP status %d, code %d", statusCode, response.Code)
+	}
+	return fmt.Errorf("Feishu API request failed: HTTP status %d, code %d, msg %q", statusCode, response.Code, response.Msg)
+}
+
+func (f *Feishu) redactError(err error) error {
+	return redact.Error(err, append(redact.URLSecrets(f.URL), f.Secret)...)
+}
@@ -0,0 +1,91 @@
+package feishu
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/we11adam/uddns/internal/testutil"
+	"github.com/we11adam/uddns/notifier"
+)
+
+const hookURL = "https://open.feishu.cn/open-apis/bot/v2/hook/hook-token"
+
+type failingTransport struct {
+	message string
+}
+
+func (f failingTransport) RoundTrip(_ *http.Request) (*http.Response, error) {
+	return nil, errors.New(f.message)
+}
+
+func TestSign(t *testing.T) {
+	// Expected value computed independently with Python's hmac module.
+	if got := sign("feishu-secret", "1700000000"); got != "OrBzY1Y01Gq+HgJsl+7OfWcMVwc7YocohQm5iiZwjhU=" {
+		t.Fatalf("sign() = %q", got)
+	}
+}
+
+func TestNewValidatesConfig(t *testing.T) {
+	for _, config := range []*Feishu{
+		{},
+		{URL: "http://open.feishu.cn/open-apis/bot/v2/hook/token"},
+		{URL: hookURL, Proxy: "socks5://proxy.example"},
+	} {
+		if _, err := New(config); err == nil {
+			t.Fatalf("expected %+v to be rejected", config)
+		}
+	}
+}
+
+func TestNotifySendsSignedTextMessage(t *testing.T) {
+	var body textMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		_, _ = w.Write([]byte(`{"code":0,"msg":"success","data":{}}`))
+	}))
+	defer server.Close()
+
+	feishu, err := New(&Feishu{URL: hookURL, Secret: "feishu-secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	feishu.hc.SetBaseURL(server.URL)
+	if err := feishu.Notify(context.Background(), notifier.Notification{Title: "home", Message: "DNS records updated"}); err != nil {
+		t.Fatal(err)
+	}
+	if body.MsgType != "text" || body.Content.Text != "home\nDNS records updated" {
+		t.Fatalf("unexpected message: %+v", body)
+	}
+	if body.Timestamp == "" || body.Sign != sign("feishu-secret", body.Timestamp) {
+		t.Fatalf("unexpected signature: timestamp=%q sign=%q", body.Timestamp, body.Sign)
+	}
+}
+
+func TestNotifyChecksFeishuAPIResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"code":19021,"msg":"sign match fail or timestamp is not within one hour from current time","data":{}}`))
+	}))
+	defer server.Close()
+
+	feishu, err := New(&Feishu{URL: hookURL, Secret: "feishu-secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	feishu.hc.SetBaseURL(server.URL)
+	err = feishu.Notify(context.Background(), notifier.Notification{Message: "test"})
+	if err == nil || !strings.Contains(err.Error(), "code 19021") {
+		t.Fatalf("expected Feishu API error, got %v", err)
+	}
+
+	feishu.hc.SetTransport(failingTransport{message: "Post " + hookURL + ": connection reset"})
+	err = feishu.Notify(context.Background(), notifier.Notification{Message: "test"})
+	if err == nil {
+		t.Fatal("expected transport error")
+	}
+	testutil.AssertTokenRedacted(t, err.Error(), hookURL)
+}
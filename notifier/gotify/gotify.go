@@ -0,0 +1,128 @@
+package gotify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+
+	"github.com/we11adam/uddns/internal/proxyurl"
+	"github.com/we11adam/uddns/internal/redact"
+	"github.com/we11adam/uddns/notifier"
+)
+
+const (
+	requestTimeout    = 10 * time.Second
+	responseBodyLimit = 256 << 10
+)
+
+type Gotify struct {
+	// Server is the base URL of the Gotify server.
+	Server string `mapstructure:"server"`
+	// Token is a Gotify application token.
+	Token string `mapstructure:"token"`
+	Proxy string `mapstructure:"proxy"`
+	hc    *resty.Client
+}
+
+type message struct {
+	Title    string `json:"title,omitempty"`
+	Message  string `json:"message"`
+	Priority int    `json:"priority"`
+}
+
+type apiResponse struct {
+	ErrorDescription string `json:"errorDescription"`
+}
+
+func init() {
+	notifier.Register("Gotify", "notifiers.gotify", func(v notifier.ConfigReader) (notifier.Notifier, error) {
+		if !v.IsSet("notifiers.gotify") {
+			return nil, notifier.ErrNotConfigured
+		}
+
+		gotify := Gotify{}
+		err := v.UnmarshalKey("notifiers.gotify", &gotify)
+		if err != nil {
+			return nil, err
+		}
+
+		return New(&gotify)
+	})
+}
+
+func New(config *Gotify) (*Gotify, error) {
+	if config == nil {
+		return nil, fmt.Errorf("Gotify config is nil")
+	}
+	if config.Server == "" || config.Token == "" {
+		return nil, fmt.Errorf("Gotify server and token are required")
+	}
+	server, err := url.Parse(config.Server)
+	if err != nil || (server.Scheme != "http" && server.Scheme != "https") || server.Host == "" {
+		return nil, fmt.Errorf("Gotify server must be an absolute http or https URL")
+	}
+
+	var proxy *url.URL
+	if config.Proxy != "" {
+		if proxy, err = proxyurl.Parse(config.Proxy); err != nil {
+			return nil, fmt.Errorf("invalid Gotify proxy configuration: %w", err)
+		}
+	}
+
+	gotify := *config
+	gotify.hc = gotify.newHTTPClient(proxy)
+	return &gotify, nil
+}
+
+func (g *Gotify) newHTTPClient(proxy *url.URL) *resty.Client {
+	client := resty.New().
+		SetTimeout(requestTimeout).
+		SetResponseBodyLimit(responseBodyLimit).
+		SetHeader("Content-Type", "application/json").
+		SetHeader("X-Gotify-Key", g.Token).
+		SetBaseURL(strings.TrimRight(g.Server, "/"))
+	if proxy != nil {
+		client.SetProxy(proxy.String())
+	}
+	return client
+}
+
+// Notify creates a Gotify message. Priorities use Gotify's 0 to 10 scale,
+// where clients typically alert loudly from 8.
+func (g *Gotify) Notify(ctx context.Context, notification notifier.Notification) error {
+	priority := 5
+	switch notification.Reason {
+	case notifier.ReasonUpdateFailure:
+		priority = 8
+	case notifier.ReasonUpdateSuccess:
+		priority = 2
+	}
+
+	resp, err := g.hc.R().SetContext(ctx).SetBody(&message{
+		Title:    notification.Title,
+		Message:  notification.Message,
+		Priority: priority,
+	}).Post("/message")
+	if err != nil {
+		return g.redactError(err)
+	}
+	if resp.IsSuccess() {
+		return nil
+	}
+
+	apiResp := apiResponse{}
+	_ = json.Unmarshal(resp.Body(), &apiResp)
+	if apiResp.ErrorDescription == "" {
+		return g.redactError(fmt.Errorf("Gotify API request failed: HTTP status %d", resp.StatusCode()))
+	}
+	return g.redactError(fmt.Errorf("Gotify API request failed: HTTP status %d, error %q", resp.StatusCode(), apiResp.ErrorDescription))
+}
+
+func (g *Gotify) redactError(err error) error {
+	return redact.Error(err, g.Token)
+}
@@ -0,0 +1,82 @@
+package gotify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/we11adam/uddns/internal/testutil"
+	"github.com/we11adam/uddns/notifier"
+)
+
+func TestNewValidatesConfig(t *testing.T) {
+	for _, config := range []*Gotify{
+		{},
+		{Server: "https://gotify.example.com"},
+		{Server: "gotify.example.com", Token: "token"},
+		{Server: "https://gotify.example.com", Token: "token", Proxy: "socks5://proxy.example"},
+	} {
+		if _, err := New(config); err == nil {
+			t.Fatalf("expected %+v to be rejected", config)
+		}
+	}
+}
+
+func TestNotifySendsMessageWithPriorityByReason(t *testing.T) {
+	tests := []struct {
+		reason   notifier.Reason
+		priority int
+	}{
+		{notifier.ReasonIPChange, 5},
+		{notifier.ReasonUpdateSuccess, 2},
+		{notifier.ReasonUpdateFailure, 8},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.reason), func(t *testing.T) {
+			var body message
+			var path, key string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path = r.URL.Path
+				key = r.Header.Get("X-Gotify-Key")
+				_ = json.NewDecoder(r.Body).Decode(&body)
+				_, _ = w.Write([]byte(`{"id":1}`))
+			}))
+			defer server.Close()
+
+			gotify, err := New(&Gotify{Server: server.URL + "/gotify/", Token: "app-token"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := gotify.Notify(context.Background(), notifier.Notification{Title: "home", Message: "test", Reason: tt.reason}); err != nil {
+				t.Fatal(err)
+			}
+			if path != "/gotify/message" || key != "app-token" {
+				t.Fatalf("unexpected request: path=%q key=%q", path, key)
+			}
+			if body.Title != "home" || body.Message != "test" || body.Priority != tt.priority {
+				t.Fatalf("unexpected message: %+v", body)
+			}
+		})
+	}
+}
+
+func TestNotifyReportsErrorsWithoutToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error":"Unauthorized","errorCode":401,"errorDescription":"you need to provide a valid access token, got app-token"}`))
+	}))
+	defer server.Close()
+
+	gotify, err := New(&Gotify{Server: server.URL, Token: "app-token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = gotify.Notify(context.Background(), notifier.Notification{Message: "test"})
+	if err == nil || !strings.Contains(err.Error(), "HTTP status 401") || !strings.Contains(err.Error(), "valid access token") {
+		t.Fatalf("expected Gotify API error, got %v", err)
+	}
+	testutil.AssertTokenRedacted(t, err.Error(), "app-token")
+}
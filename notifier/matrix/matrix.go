@@ -0,0 +1,222 @@
+package matrix
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+
+	"github.com/we11adam/uddns/internal/proxyurl"
+	"github.com/we11adam/uddns/internal/redact"
+	"github.com/we11adam/uddns/internal/restyretry"
+	"github.com/we11adam/uddns/notifier"
+)
+
+const (
+	requestTimeout    = 10 * time.Second
+	responseBodyLimit = 256 << 10
+	htmlFormat        = "org.matrix.custom.html"
+)
+
+var (
+	// htmlBreaks matches the tags that end a line or block, which become
+	// newlines in the plain-text body.
+	htmlBreaks = regexp.MustCompile(`(?i)<br\s*/?>|</p\s*>|</li\s*>`)
+	htmlTags   = regexp.MustCompile(`<[^>]*>`)
+)
+
+type Matrix struct {
+	// Homeserver is the client-server API base URL, such as
+	// https://matrix.example.org.
+	Homeserver  string `mapstructure:"homeserver"`
+	AccessToken string `mapstructure:"access_token"`
+	// Room is a room ID (!id:server) or alias (#alias:server). Aliases are
+	// resolved on the first notification.
+	Room string `mapstructure:"room"`
+	// HTML sends the message as formatted_body, for templates that produce
+	// HTML.
+	HTML  bool   `mapstructure:"html"`
+	Proxy string `mapstructure:"proxy"`
+	hc    *resty.Client
+
+	roomMu sync.Mutex
+	roomID string
+}
+
+type message struct {
+	MsgType       string `json:"msgtype"`
+	Body          string `json:"body"`
+	Format        string `json:"format,omitempty"`
+	FormattedBody string `json:"formatted_body,omitempty"`
+}
+
+type apiResponse struct {
+	ErrCode string `json:"errcode"`
+	Error   string `json:"error"`
+}
+
+type roomAlias struct {
+	RoomID string `json:"room_id"`
+}
+
+func init() {
+	notifier.Register("Matrix", "notifiers.matrix", func(v notifier.ConfigReader) (notifier.Notifier, error) {
+		if !v.IsSet("notifiers.matrix") {
+			return nil, notifier.ErrNotConfigured
+		}
+
+		matrix := Matrix{}
+		err := v.UnmarshalKey("notifiers.matrix", &matrix)
+		if err != nil {
+			return nil, err
+		}
+
+		return New(&matrix)
+	})
+}
+
+func New(config *Matrix) (*Matrix, error) {
+	if config == nil {
+		return nil, fmt.Errorf("Matrix config is nil")
+	}
+	if config.Homeserver == "" || config.AccessToken == "" || config.Room == "" {
+		return nil, fmt.Errorf("Matrix homeserver, access_token, and room are required")
+	}
+	homeserver, err := url.Parse(config.Homeserver)
+	if err != nil || (homeserver.Scheme != "http" && homeserver.Scheme != "https") || homeserver.Host == "" {
+		return nil, fmt.Errorf("Matrix homeserver must be an absolute http or https URL")
+	}
+	if !strings.HasPrefix(config.Room, "!") && !strings.HasPrefix(config.Room, "#") {
+		return nil, fmt.Errorf("Matrix room must be a room ID starting with ! or an alias starting with #")
+	}
+
+	var proxy *url.URL
+	if config.Proxy != "" {
+		if proxy, err = proxyurl.Parse(config.Proxy); err != nil {
+			return nil, fmt.Errorf("invalid Matrix proxy configuration: %w", err)
+		}
+	}
+
+	matrix := &Matrix{
+		Homeserver:  config.Homeserver,
+		AccessToken: config.AccessToken,
+		Room:        config.Room,
+		HTML:        config.HTML,
+		Proxy:       config.Proxy,
+	}
+	if strings.HasPrefix(matrix.Room, "!") {
+		matrix.roomID = matrix.Room
+	}
+	matrix.hc = newHTTPClient(matrix.Homeserver, matrix.AccessToken, proxy)
+	return matrix, nil
+}
+
+func newHTTPClient(homeserver, token string, proxy *url.URL) *resty.Client {
+	client := resty.New().
+		SetTimeout(requestTimeout).
+		SetResponseBodyLimit(responseBodyLimit).
+		SetHeader("Content-Type", "application/json").
+		SetAuthToken(token).
+		SetBaseURL(strings.TrimRight(homeserver, "/"))
+	restyretry.ConfigureTransient(client)
+	if proxy != nil {
+		client.SetProxy(proxy.String())
+	}
+	return client
+}
+
+func (m *Matrix) Notify(ctx context.Context, notification notifier.Notification) error {
+	roomID, err := m.resolveRoom(ctx)
+	if err != nil {
+		return m.redactError(err)
+	}
+
+	resp, err := m.hc.R().
+		SetContext(ctx).
+		SetPathParams(map[string]string{"room": roomID, "txn": transactionID(notification)}).
+		SetBody(m.message(notification)).
+		Put("/_matrix/client/v3/rooms/{room}/send/m.room.message/{txn}")
+	if err != nil {
+		return m.redactError(err)
+	}
+	if !resp.IsSuccess() {
+		return m.redactError(m.apiError(resp.StatusCode(), resp.Body()))
+	}
+	return nil
+}
+
+// transactionID derives the transaction ID from the notification's ID, so
+// that every attempt of a notification uses the same ID, which the homeserver
+// deduplicates.
+func transactionID(notification notifier.Notification) string {
+	return "uddns." + notification.ID()
+}
+
+func (m *Matrix) message(notification notifier.Notification) *message {
+	if !m.HTML {
+		return &message{MsgType: "m.notice", Body: notification.Text()}
+	}
+	formatted := notification.Message
+	if notification.Title != "" {
+		formatted = "<strong>" + html.EscapeString(notification.Title) + "</strong><br>" + formatted
+	}
+	return &message{
+		MsgType:       "m.notice",
+		Body:          plainText(formatted),
+		Format:        htmlFormat,
+		FormattedBody: formatted,
+	}
+}
+
+// plainText turns an HTML message into the plain-text body for clients that
+// do not render formatted_body.
+func plainText(formatted string) string {
+	text := htmlTags.ReplaceAllString(htmlBreaks.ReplaceAllString(formatted, "\n"), "")
+	return strings.TrimSpace(html.UnescapeString(text))
+}
+
+// resolveRoom returns the room ID, looking up an alias once.
+func (m *Matrix) resolveRoom(ctx context.Context) (string, error) {
+	m.roomMu.Lock()
+	defer m.roomMu.Unlock()
+	if m.roomID != "" {
+		return m.roomID, nil
+	}
+
+	resp, err := m.hc.R().
+		SetContext(ctx).
+		SetPathParam("alias", m.Room).
+		Get("/_matrix/client/v3/directory/room/{alias}")
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve Matrix room alias: %w", err)
+	}
+	if !resp.IsSuccess() {
+		return "", fmt.Errorf("failed to resolve Matrix room alias: %w", m.apiError(resp.StatusCode(), resp.Body()))
+	}
+	alias := roomAlias{}
+	if err := json.Unmarshal(resp.Body(), &alias); err != nil || alias.RoomID == "" {
+		return "", fmt.Errorf("failed to resolve Matrix room alias: response has no room_id")
+	}
+	m.roomID = alias.RoomID
+	return m.roomID, nil
+}
+
+func (m *Matrix) apiError(statusCode int, body []byte) error {
+	response := apiResponse{}
+	_ = json.Unmarshal(body, &response)
+	if response.ErrCode == "" {
+		return fmt.Errorf("Matrix API request failed: HTTP status %d", statusCode)
+	}
+	return fmt.Errorf("Matrix API request failed: HTTP status %d, errcode %s, error %q", statusCode, response.ErrCode, response.Error)
+}
+
+func (m *Matrix) redactError(err error) error {
+	return redact.Error(err, m.AccessToken)
+}
@@ -0,0 +1,198 @@
+package matrix
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/we11adam/uddns/internal/testutil"
+	"github.com/we11adam/uddns/notifier"
+)
+
+// homeserver records the requests of a fake Matrix homeserver.
+type homeserver struct {
+	mu       sync.Mutex
+	aliases  int
+	sends    []string
+	messages []message
+	// failures makes the first send attempts fail with HTTP 502.
+	failures int
+}
+
+func (h *homeserver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if r.Header.Get("Authorization") != "Bearer syt_secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"errcode":"M_UNKNOWN_TOKEN","error":"Invalid access token passed"}`))
+		return
+	}
+	switch {
+	case r.Method == http.MethodGet && r.URL.EscapedPath() == "/_matrix/client/v3/directory/room/%23ops:example.org":
+		h.aliases++
+		_, _ = w.Write([]byte(`{"room_id":"!room:example.org","servers":["example.org"]}`))
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/_matrix/client/v3/rooms/!room:example.org/send/m.room.message/"):
+		h.sends = append(h.sends, r.URL.Path)
+		if h.failures > 0 {
+			h.failures--
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		var body message
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		h.messages = append(h.messages, body)
+		_, _ = w.Write([]byte(`{"event_id":"$event"}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"errcode":"M_NOT_FOUND","error":"Room alias not found"}`))
+	}
+}
+
+func TestNewValidatesConfig(t *testing.T) {
+	for _, config := range []*Matrix{
+		{},
+		{Homeserver: "matrix.example.org", AccessToken: "token", Room: "!room:example.org"},
+		{Homeserver: "https://matrix.example.org", AccessToken: "token", Room: "ops"},
+		{Homeserver: "https://matrix.example.org", AccessToken: "token", Room: "!room:example.org", Proxy: "socks5://proxy.example"},
+	} {
+		if _, err := New(config); err == nil {
+			t.Fatalf("expected %+v to be rejected", config)
+		}
+	}
+}
+
+func TestNotifyResolvesAliasAndSendsNotices(t *testing.T) {
+	server := &homeserver{}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	matrix, err := New(&Matrix{Homeserver: httpServer.URL, AccessToken: "syt_secret", Room: "#ops:example.org"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent := time.Unix(1_700_000_000, 0)
+	for i := range 3 {
+		notification := notifier.Notification{Title: "home", Message: "DNS records updated", Job: "home", Event: notifier.Event{Time: sent}}
+		if i == 2 {
+			notification.Event.Time = sent.Add(time.Minute)
+		}
+		if err := matrix.Notify(context.Background(), notification); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if server.aliases != 1 {
+		t.Fatalf("expected the alias to be resolved once, got %d lookups", server.aliases)
+	}
+	if len(server.sends) != 3 || server.sends[0] != server.sends[1] {
+		t.Fatalf("expected a resent notification to reuse its transaction ID, got %v", server.sends)
+	}
+	if server.sends[1] == server.sends[2] {
+		t.Fatalf("expected a new transaction ID per notification, got %v", server.sends)
+	}
+	want := message{MsgType: "m.notice", Body: "home\nDNS records updated"}
+	if server.messages[0] != want {
+		t.Fatalf("message = %+v, want %+v", server.messages[0], want)
+	}
+}
+
+func TestNotifyRetriesWithTheSameTransactionID(t *testing.T) {
+	server := &homeserver{failures: 1}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	matrix, err := New(&Matrix{Homeserver: httpServer.URL, AccessToken: "syt_secret", Room: "!room:example.org"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := matrix.Notify(context.Background(), notifier.Notification{Message: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(server.sends) != 2 || server.sends[0] != server.sends[1] {
+		t.Fatalf("expected the retry to reuse the transaction ID, got %v", server.sends)
+	}
+}
+
+func TestNotifySendsHTML(t *testing.T) {
+	server := &homeserver{}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	matrix, err := New(&Matrix{Homeserver: httpServer.URL, AccessToken: "syt_secret", Room: "!room:example.org", HTML: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	notification := notifier.Notification{Title: "home & office", Message: "IPv4 is now <code>192.0.2.10</code>"}
+	if err := matrix.Notify(context.Background(), notification); err != nil {
+		t.Fatal(err)
+	}
+	want := message{
+		MsgType:       "m.notice",
+		Body:          "home & office\nIPv4 is now 192.0.2.10",
+		Format:        htmlFormat,
+		FormattedBody: "<strong>home &amp; office</strong><br>IPv4 is now <code>192.0.2.10</code>",
+	}
+	if server.messages[0] != want {
+		t.Fatalf("message = %+v, want %+v", server.messages[0], want)
+	}
+}
+
+func TestNotifySeparatesLinesOfTemplatedHTML(t *testing.T) {
+	server := &homeserver{}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	matrix, err := New(&Matrix{Homeserver: httpServer.URL, AccessToken: "syt_secret", Room: "!room:example.org", HTML: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	templates, err := notifier.ParseTemplates(map[string]string{
+		"default": "<p>{{.Job}} updated</p><ul><li>IPv4 {{.NewIPv4}}</li><LI>IPv6 {{.NewIPv6}}</LI></ul>Host<br/>{{.Hostname}}<BR />done",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notification, err := templates.Render(notifier.Notification{
+		Reason: notifier.ReasonUpdateSuccess,
+		Job:    "home",
+		Event:  notifier.Event{NewIPv4: "192.0.2.10", NewIPv6: "2001:db8::10", Hostname: "router"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := matrix.Notify(context.Background(), notification); err != nil {
+		t.Fatal(err)
+	}
+	if want := "home updated\nIPv4 192.0.2.10\nIPv6 2001:db8::10\nHost\nrouter\ndone"; server.messages[0].Body != want {
+		t.Fatalf("body = %q, want %q", server.messages[0].Body, want)
+	}
+}
+
+func TestNotifyReportsErrcodeWithoutToken(t *testing.T) {
+	httpServer := httptest.NewServer(&homeserver{})
+	defer httpServer.Close()
+
+	matrix, err := New(&Matrix{Homeserver: httpServer.URL, AccessToken: "syt_other", Room: "!room:example.org"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = matrix.Notify(context.Background(), notifier.Notification{Message: "test"})
+	if err == nil || !strings.Contains(err.Error(), "M_UNKNOWN_TOKEN") {
+		t.Fatalf("expected Matrix errcode, got %v", err)
+	}
+	testutil.AssertTokenRedacted(t, err.Error(), "syt_other")
+
+	matrix, err = New(&Matrix{Homeserver: httpServer.URL, AccessToken: "syt_secret", Room: "#missing:example.org"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = matrix.Notify(context.Background(), notifier.Notification{Message: "test"})
+	if err == nil || !strings.Contains(err.Error(), "M_NOT_FOUND") {
+		t.Fatalf("expected alias resolution error, got %v", err)
+	}
+}
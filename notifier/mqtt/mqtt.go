@@ -0,0 +1,440 @@
+package mqtt
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/we11adam/uddns/internal/redact"
+	"github.com/we11adam/uddns/internal/status"
+	"github.com/we11adam/uddns/notifier"
+)
+
+const (
+	publishTimeout         = 10 * time.Second
+	connectTimeout         = 10 * time.Second
+	connectRetryInterval   = 10 * time.Second
+	maxReconnectInterval   = 2 * time.Minute
+	disconnectQuiesce      = 250 // milliseconds
+	qos                    = 1
+	defaultTopicPrefix     = "uddns"
+	defaultDiscoveryPrefix = "homeassistant"
+	online                 = "online"
+	offline                = "offline"
+)
+
+var (
+	brokerSchemes = []string{"tcp", "mqtt", "ssl", "tls", "mqtts", "ws", "wss"}
+	secureSchemes = []string{"ssl", "tls", "mqtts", "wss"}
+	// unsafeTopicChars matches characters that are not used verbatim in topic
+	// levels and Home Assistant object IDs.
+	unsafeTopicChars = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+)
+
+type MQTT struct {
+	// Broker is the broker URL: tcp:// or mqtt:// for plain MQTT, ssl://,
+	// tls://, or mqtts:// for MQTT over TLS, and ws:// or wss:// for
+	// WebSockets.
+	Broker   string `mapstructure:"broker"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	// ClientID defaults to uddns- and a random suffix.
+	ClientID string `mapstructure:"client_id"`
+	// TopicPrefix is the first topic level, uddns by default.
+	TopicPrefix   string        `mapstructure:"topic_prefix"`
+	TLS           TLS           `mapstructure:"tls"`
+	HomeAssistant HomeAssistant `mapstructure:"home_assistant"`
+
+	client    paho.Client
+	tlsConfig *tls.Config
+	connect   sync.Once
+
+	mu   sync.Mutex
+	jobs map[string]jobState
+}
+
+type TLS struct {
+	// CAFile is a PEM bundle that replaces the system roots.
+	CAFile string `mapstructure:"ca_file"`
+	// CertFile and KeyFile are a client certificate for brokers that require
+	// one.
+	CertFile           string `mapstructure:"cert_file"`
+	KeyFile            string `mapstructure:"key_file"`
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"`
+}
+
+type HomeAssistant struct {
+	// Enabled publishes MQTT discovery configs so that each job appears in
+	// Home Assistant as a device with sensors.
+	Enabled bool `mapstructure:"enabled"`
+	// DiscoveryPrefix is Home Assistant's discovery prefix, homeassistant by
+	// default.
+	DiscoveryPrefix string `mapstructure:"discovery_prefix"`
+}
+
+// jobState is the latest state payload of a job, kept so it can be published
+// again after a reconnect.
+type jobState struct {
+	name    string
+	payload []byte
+}
+
+// sensor is one Home Assistant entity of a job.
+type sensor struct {
+	id          string
+	name        string
+	field       string
+	icon        string
+	deviceClass string
+}
+
+var sensors = []sensor{
+	{id: "ipv4", name: "IPv4", field: "last_applied_ipv4", icon: "mdi:ip-network"},
+	{id: "ipv6", name: "IPv6", field: "last_applied_ipv6", icon: "mdi:ip-network"},
+	{id: "status", name: "Status", field: "last_status", icon: "mdi:dns"},
+	{id: "last_update", name: "Last update", field: "last_updated_at", deviceClass: "timestamp"},
+}
+
+type discoveryConfig struct {
+	Name                string          `json:"name"`
+	UniqueID            string          `json:"unique_id"`
+	ObjectID            string          `json:"object_id"`
+	StateTopic          string          `json:"state_topic"`
+	ValueTemplate       string          `json:"value_template"`
+	JSONAttributesTopic string          `json:"json_attributes_topic,omitempty"`
+	AvailabilityTopic   string          `json:"availability_topic"`
+	Icon                string          `json:"icon,omitempty"`
+	DeviceClass         string          `json:"device_class,omitempty"`
+	Device              discoveryDevice `json:"device"`
+}
+
+type discoveryDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model"`
+}
+
+func init() {
+	notifier.Register("MQTT", "notifiers.mqtt", func(v notifier.ConfigReader) (notifier.Notifier, error) {
+		if !v.IsSet("notifiers.mqtt") {
+			return nil, notifier.ErrNotConfigured
+		}
+
+		mqtt := MQTT{}
+		err := v.UnmarshalKey("notifiers.mqtt", &mqtt)
+		if err != nil {
+			return nil, err
+		}
+
+		return New(&mqtt)
+	})
+}
+
+// ValidateJobs rejects job names that map to the same topic level, which
+// would mix the jobs' state and events.
+func (m *MQTT) ValidateJobs(names []string) error {
+	levels := make(map[string]string, len(names))
+	for _, name := range names {
+		level := topicLevel(name)
+		if other, ok := levels[level]; ok && other != name {
+			return fmt.Errorf("MQTT topics of jobs %q and %q are both %q; rename one of the jobs", other, name, level)
+		}
+		levels[level] = name
+	}
+	return nil
+}
+
+// New validates the configuration and prepares the client. The broker
+// connection is opened on first use, so building the notifier, for example to
+// validate a reloaded configuration, does not connect.
+func New(config *MQTT) (*MQTT, error) {
+	if config == nil {
+		return nil, fmt.Errorf("MQTT config is nil")
+	}
+	if config.Broker == "" {
+		return nil, fmt.Errorf("MQTT broker is required")
+	}
+	broker, err := url.Parse(config.Broker)
+	if err != nil || !slices.Contains(brokerSchemes, broker.Scheme) || broker.Host == "" {
+		return nil, fmt.Errorf("MQTT broker must be an absolute URL with one of the schemes %s", strings.Join(brokerSchemes, ", "))
+	}
+	topicPrefix := strings.Trim(config.TopicPrefix, "/")
+	if topicPrefix == "" {
+		topicPrefix = defaultTopicPrefix
+	}
+	if strings.ContainsAny(topicPrefix, "+#") {
+		return nil, fmt.Errorf("MQTT topic_prefix must not contain wildcards")
+	}
+	discoveryPrefix := strings.Trim(config.HomeAssistant.DiscoveryPrefix, "/")
+	if discoveryPrefix == "" {
+		discoveryPrefix = defaultDiscoveryPrefix
+	}
+	if strings.ContainsAny(discoveryPrefix, "+#") {
+		return nil, fmt.Errorf("MQTT home_assistant.discovery_prefix must not contain wildcards")
+	}
+	if (config.TLS.CertFile == "") != (config.TLS.KeyFile == "") {
+		return nil, fmt.Errorf("MQTT tls.cert_file and tls.key_file must be set together")
+	}
+	clientID := config.ClientID
+	if clientID == "" {
+		clientID = "uddns-" + strings.ToLower(rand.Text()[:8])
+	}
+
+	mqtt := &MQTT{
+		Broker:      config.Broker,
+		Username:    config.Username,
+		Password:    config.Password,
+		ClientID:    clientID,
+		TopicPrefix: topicPrefix,
+		TLS:         config.TLS,
+		HomeAssistant: HomeAssistant{
+			Enabled:         config.HomeAssistant.Enabled,
+			DiscoveryPrefix: discoveryPrefix,
+		},
+		jobs: map[string]jobState{},
+	}
+	if slices.Contains(secureSchemes, broker.Scheme) || config.TLS != (TLS{}) {
+		if mqtt.tlsConfig, err = newTLSConfig(config.TLS); err != nil {
+			return nil, fmt.Errorf("invalid MQTT tls configuration: %w", err)
+		}
+	}
+	return mqtt, nil
+}
+
+func newTLSConfig(config TLS) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+	if config.CAFile != "" {
+		pem, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s contains no PEM certificates", config.CAFile)
+		}
+	}
+	if config.CertFile != "" {
+		certificate, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	return tlsConfig, nil
+}
+
+// start creates the client on first use and begins connecting in the
+// background. The client keeps retrying until the broker is reachable and
+// reconnects after outages; messages published in the meantime are queued.
+// It returns nil once the notifier is closed.
+func (m *MQTT) start() paho.Client {
+	m.connect.Do(func() {
+		options := paho.NewClientOptions().
+			AddBroker(m.Broker).
+			SetClientID(m.ClientID).
+			SetUsername(m.Username).
+			SetPassword(m.Password).
+			// A persistent session makes the client resend events queued
+			// while it was connecting or reconnecting.
+			SetCleanSession(false).
+			SetConnectTimeout(connectTimeout).
+			SetWriteTimeout(publishTimeout).
+			SetConnectRetry(true).
+			SetConnectRetryInterval(connectRetryInterval).
+			SetAutoReconnect(true).
+			SetMaxReconnectInterval(maxReconnectInterval).
+			SetOrderMatters(false).
+			SetWill(m.availabilityTopic(), offline, qos, true).
+			SetOnConnectHandler(m.onConnect).
+			SetConnectionLostHandler(func(_ paho.Client, err error) {
+				slog.Warn("lost connection to MQTT broker; reconnecting", "error", m.redactError(err))
+			})
+		if m.tlsConfig != nil {
+			options.SetTLSConfig(m.tlsConfig)
+		}
+		client := paho.NewClient(options)
+		m.mu.Lock()
+		m.client = client
+		m.mu.Unlock()
+		client.Connect()
+	})
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.client
+}
+
+// onConnect announces availability and publishes the discovery configs and
+// latest state of every known job, which also restores retained messages
+// that a broker without persistence lost while it was down.
+func (m *MQTT) onConnect(client paho.Client) {
+	slog.Info("connected to MQTT broker", "client_id", m.ClientID)
+	m.publishAsync(client, m.availabilityTopic(), []byte(online), true)
+
+	m.mu.Lock()
+	jobs := make([]jobState, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		jobs = append(jobs, job)
+	}
+	m.mu.Unlock()
+	slices.SortFunc(jobs, func(a, b jobState) int { return strings.Compare(a.name, b.name) })
+	for _, job := range jobs {
+		m.publishDiscovery(client, job.name)
+		m.publishAsync(client, m.jobTopic(job.name, "state"), job.payload, true)
+	}
+}
+
+// Notify publishes the notification's event to <prefix>/<job>/event and waits
+// for the broker to acknowledge it.
+func (m *MQTT) Notify(ctx context.Context, notification notifier.Notification) error {
+	payload, err := notification.JSON()
+	if err != nil {
+		return err
+	}
+	client := m.start()
+	if client == nil {
+		return fmt.Errorf("MQTT notifier is closed")
+	}
+	token := client.Publish(m.jobTopic(notification.Job, "event"), qos, false, payload)
+
+	timer := time.NewTimer(publishTimeout)
+	defer timer.Stop()
+	select {
+	case <-token.Done():
+		if err := token.Error(); err != nil {
+			return m.redactError(fmt.Errorf("failed to publish MQTT event: %w", err))
+		}
+		return nil
+	case <-timer.C:
+		return fmt.Errorf("failed to publish MQTT event: no acknowledgement from broker after %s", publishTimeout)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ObserveStatus publishes the job's status, as reported by the status
+// endpoint, to the retained <prefix>/<job>/state topic. While the broker is
+// unreachable only the latest status is kept and published on reconnect.
+func (m *MQTT) ObserveStatus(job status.Job) {
+	payload, err := json.Marshal(job)
+	if err != nil {
+		slog.Warn("failed to encode MQTT state", "job", job.Name, "error", err)
+		return
+	}
+	m.mu.Lock()
+	_, known := m.jobs[job.Name]
+	m.jobs[job.Name] = jobState{name: job.Name, payload: payload}
+	m.mu.Unlock()
+
+	client := m.start()
+	if client == nil || !client.IsConnectionOpen() {
+		return
+	}
+	if !known {
+		m.publishDiscovery(client, job.Name)
+	}
+	m.publishAsync(client, m.jobTopic(job.Name, "state"), payload, true)
+}
+
+// Close marks uddns offline and disconnects from the broker. A notifier that
+// was never used has no connection to close.
+func (m *MQTT) Close() error {
+	m.connect.Do(func() {})
+	m.mu.Lock()
+	client := m.client
+	m.client = nil
+	m.mu.Unlock()
+	if client == nil {
+		return nil
+	}
+	if client.IsConnectionOpen() {
+		client.Publish(m.availabilityTopic(), qos, true, offline).WaitTimeout(time.Second)
+	}
+	client.Disconnect(disconnectQuiesce)
+	return nil
+}
+
+func (m *MQTT) publishDiscovery(client paho.Client, job string) {
+	if !m.HomeAssistant.Enabled {
+		return
+	}
+	node := "uddns_" + topicLevel(job)
+	device := discoveryDevice{
+		Identifiers:  []string{node},
+		Name:         "uddns " + job,
+		Manufacturer: "uddns",
+		Model:        "Dynamic DNS job",
+	}
+	for _, sensor := range sensors {
+		config := discoveryConfig{
+			Name:              sensor.name,
+			UniqueID:          node + "_" + sensor.id,
+			ObjectID:          node + "_" + sensor.id,
+			StateTopic:        m.jobTopic(job, "state"),
+			ValueTemplate:     "{{ value_json." + sensor.field + " | default(None) }}",
+			AvailabilityTopic: m.availabilityTopic(),
+			Icon:              sensor.icon,
+			DeviceClass:       sensor.deviceClass,
+			Device:            device,
+		}
+		if sensor.id == "status" {
+			config.JSONAttributesTopic = config.StateTopic
+		}
+		payload, err := json.Marshal(config)
+		if err != nil {
+			slog.Warn("failed to encode Home Assistant discovery config", "job", job, "error", err)
+			continue
+		}
+		m.publishAsync(client, m.HomeAssistant.DiscoveryPrefix+"/sensor/"+node+"/"+sensor.id+"/config", payload, true)
+	}
+}
+
+// publishAsync publishes without blocking the caller and logs a failure.
+func (m *MQTT) publishAsync(client paho.Client, topic string, payload []byte, retained bool) {
+	token := client.Publish(topic, qos, retained, payload)
+	go func() {
+		if token.WaitTimeout(publishTimeout) && token.Error() != nil {
+			slog.Warn("failed to publish MQTT message", "topic", topic, "error", m.redactError(token.Error()))
+		}
+	}()
+}
+
+func (m *MQTT) jobTopic(job, name string) string {
+	return m.TopicPrefix + "/" + topicLevel(job) + "/" + name
+}
+
+func (m *MQTT) availabilityTopic() string {
+	return m.TopicPrefix + "/status"
+}
+
+// topicLevel turns a job name into a single topic level by replacing every
+// character other than letters, digits, - and _ with _.
+func topicLevel(name string) string {
+	return unsafeTopicChars.ReplaceAllString(name, "_")
+}
+
+func (m *MQTT) redactError(err error) error {
+	secrets := []string{m.Password}
+	if broker, err := url.Parse(m.Broker); err == nil {
+		if password, ok := broker.User.Password(); ok {
+			secrets = append(secrets, password)
+		}
+	}
+	return redact.Error(err, secrets...)
+}
@@ -2,6 +2,13 @@ package notifier
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/we11adam/uddns/internal/registry"
 )
@@ -14,16 +21,54 @@ const (
 	ReasonUpdateFailure Reason = "update_failure"
 )
 
+// Reasons lists every notification reason in a stable order.
+var Reasons = []Reason{ReasonIPChange, ReasonUpdateSuccess, ReasonUpdateFailure}
+
 type Notification struct {
 	Title   string
 	Message string
 	Reason  Reason
+	// Job is the name of the job the notification is about.
+	Job string
+	// Event carries the details that message templates can use.
+	Event Event
+}
+
+// Text returns the notification for services without a title field, with
+// the title, if any, on its own line above the message.
+func (n Notification) Text() string {
+	if n.Title == "" {
+		return n.Message
+	}
+	return n.Title + "\n" + n.Message
+}
+
+// ID identifies the notification by its job, reason, family, time, and
+// message. Every attempt to deliver a notification, whether an HTTP retry or
+// the app's retry on a later run, has the same ID, so receivers can drop
+// duplicates of a notification that was delivered but not acknowledged.
+func (n Notification) ID() string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		n.Job,
+		string(n.Reason),
+		n.Event.Family,
+		n.Event.Time.UTC().Format(time.RFC3339Nano),
+		n.Message,
+	}, "\x00")))
+	return hex.EncodeToString(sum[:16])
 }
 
 type Notifier interface {
 	Notify(context.Context, Notification) error
 }
 
+// JobValidator is implemented by notifiers that place constraints on job
+// names, for example because they derive topics from them. It is called with
+// the names of every configured job.
+type JobValidator interface {
+	ValidateJobs(names []string) error
+}
+
 type ConfigReader = registry.ConfigReader
 
 type constructor = registry.Constructor[Notifier]
@@ -45,3 +90,150 @@ func Register(name, configKey string, constructor constructor) {
 func GetNotifier(config ConfigReader) (string, Notifier, error) {
 	return notifiers.GetOptional(config, "No-op", &Noop{})
 }
+
+// Route is a configured notifier and the notifications it receives. Empty
+// Reasons or Jobs accept every reason or job.
+type Route struct {
+	Name      string
+	Notifier  Notifier
+	Reasons   []Reason
+	Jobs      []string
+	Templates *Templates
+}
+
+// Accepts reports whether the route's filters let the notification through.
+func (r Route) Accepts(notification Notification) bool {
+	if len(r.Reasons) > 0 && !slices.Contains(r.Reasons, notification.Reason) {
+		return false
+	}
+	return len(r.Jobs) == 0 || slices.Contains(r.Jobs, notification.Job)
+}
+
+// Render applies the route's message and title templates.
+func (r Route) Render(notification Notification) (Notification, error) {
+	return r.Templates.Render(notification)
+}
+
+// GetNotifiers returns every notifier selected by notifiers.use, which may be
+// one name, a list, or a comma-separated string of implementation or instance
+// names. Without notifiers.use it falls back to GetNotifier. Each notifier's
+// section may restrict it with reasons and jobs lists.
+func GetNotifiers(config ConfigReader) ([]Route, error) {
+	names, err := selectedNames(config)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		name, n, err := GetNotifier(config)
+		if err != nil {
+			return nil, err
+		}
+		if _, noop := n.(*Noop); noop {
+			return []Route{{Name: name, Notifier: n}}, nil
+		}
+		return routes(config, []string{name}, []Notifier{n})
+	}
+
+	resolved := make([]string, 0, len(names))
+	built := make([]Notifier, 0, len(names))
+	for _, name := range names {
+		resolvedName, n, err := notifiers.GetSelected(config, name)
+		if err != nil {
+			closeAll(built)
+			return nil, err
+		}
+		built = append(built, n)
+		if slices.Contains(resolved, resolvedName) {
+			closeAll(built)
+			return nil, fmt.Errorf("notifier %q is selected more than once", name)
+		}
+		resolved = append(resolved, resolvedName)
+	}
+	return routes(config, resolved, built)
+}
+
+// closeAll closes the notifiers that hold connections when the configuration
+// they were built from turns out to be invalid.
+func closeAll(built []Notifier) {
+	for _, n := range built {
+		if closer, ok := n.(io.Closer); ok {
+			_ = closer.Close()
+		}
+	}
+}
+
+func selectedNames(config ConfigReader) ([]string, error) {
+	if !config.IsSet("notifiers.use") {
+		return nil, nil
+	}
+	var values []string
+	if err := config.UnmarshalKey("notifiers.use", &values); err != nil {
+		return nil, fmt.Errorf("notifiers.use must be a name or a list of names: %w", err)
+	}
+
+	var names []string
+	for _, value := range values {
+		for name := range strings.SplitSeq(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names, nil
+}
+
+// routes wraps the built notifiers in routes with their filters and
+// templates, closing every notifier if any route is invalid.
+func routes(config ConfigReader, names []string, built []Notifier) (result []Route, err error) {
+	defer func() {
+		if err != nil {
+			closeAll(built)
+		}
+	}()
+	result = make([]Route, 0, len(names))
+	for i, name := range names {
+		configKey, err := notifiers.ConfigKey(config, name)
+		if err != nil {
+			return nil, err
+		}
+		route := Route{Name: name, Notifier: built[i]}
+		var reasons []string
+		if err := config.UnmarshalKey(configKey+".reasons", &reasons); err != nil {
+			return nil, fmt.Errorf("notifier %q reasons must be a list: %w", name, err)
+		}
+		for _, value := range reasons {
+			reason := Reason(strings.ToLower(strings.TrimSpace(value)))
+			if !isReason(reason) {
+				return nil, fmt.Errorf("notifier %q has unsupported reason %q; supported values: %s", name, value, reasonList())
+			}
+			route.Reasons = append(route.Reasons, reason)
+		}
+		if err := config.UnmarshalKey(configKey+".jobs", &route.Jobs); err != nil {
+			return nil, fmt.Errorf("notifier %q jobs must be a list: %w", name, err)
+		}
+		var messages, titles map[string]string
+		if err := config.UnmarshalKey(configKey+".templates", &messages); err != nil {
+			return nil, fmt.Errorf("notifier %q templates must map reasons to templates: %w", name, err)
+		}
+		if err := config.UnmarshalKey(configKey+".title_templates", &titles); err != nil {
+			return nil, fmt.Errorf("notifier %q title_templates must map reasons to templates: %w", name, err)
+		}
+		if route.Templates, err = ParseTemplates(messages, titles); err != nil {
+			return nil, fmt.Errorf("notifier %q %w", name, err)
+		}
+		result = append(result, route)
+	}
+	return result, nil
+}
+
+func isReason(reason Reason) bool {
+	return slices.Contains(Reasons, reason)
+}
+
+func reasonList() string {
+	values := make([]string, len(Reasons))
+	for i, reason := range Reasons {
+		values[i] = string(reason)
+	}
+	return strings.Join(values, ", ")
+}
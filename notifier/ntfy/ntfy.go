@@ -0,0 +1,142 @@
+package ntfy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+
+	"github.com/we11adam/uddns/internal/proxyurl"
+	"github.com/we11adam/uddns/internal/redact"
+	"github.com/we11adam/uddns/notifier"
+)
+
+const (
+	requestTimeout    = 10 * time.Second
+	responseBodyLimit = 256 << 10
+	defaultServer     = "https://ntfy.sh"
+)
+
+type Ntfy struct {
+	// Server is the ntfy base URL. Empty means https://ntfy.sh.
+	Server string `mapstructure:"server"`
+	Topic  string `mapstructure:"topic"`
+	// Token is an optional access token for protected topics.
+	Token string `mapstructure:"token"`
+	Proxy string `mapstructure:"proxy"`
+	hc    *resty.Client
+}
+
+type message struct {
+	Topic    string   `json:"topic"`
+	Title    string   `json:"title,omitempty"`
+	Message  string   `json:"message"`
+	Priority int      `json:"priority"`
+	Tags     []string `json:"tags,omitempty"`
+}
+
+type apiResponse struct {
+	Code  int    `json:"code"`
+	Error string `json:"error"`
+}
+
+func init() {
+	notifier.Register("ntfy", "notifiers.ntfy", func(v notifier.ConfigReader) (notifier.Notifier, error) {
+		if !v.IsSet("notifiers.ntfy") {
+			return nil, notifier.ErrNotConfigured
+		}
+
+		ntfy := Ntfy{}
+		err := v.UnmarshalKey("notifiers.ntfy", &ntfy)
+		if err != nil {
+			return nil, err
+		}
+
+		return New(&ntfy)
+	})
+}
+
+func New(config *Ntfy) (*Ntfy, error) {
+	if config == nil {
+		return nil, fmt.Errorf("ntfy config is nil")
+	}
+	if config.Topic == "" {
+		return nil, fmt.Errorf("ntfy topic is required")
+	}
+
+	ntfy := *config
+	if ntfy.Server == "" {
+		ntfy.Server = defaultServer
+	}
+	server, err := url.Parse(ntfy.Server)
+	if err != nil || (server.Scheme != "http" && server.Scheme != "https") || server.Host == "" {
+		return nil, fmt.Errorf("ntfy server must be an absolute http or https URL")
+	}
+
+	var proxy *url.URL
+	if ntfy.Proxy != "" {
+		if proxy, err = proxyurl.Parse(ntfy.Proxy); err != nil {
+			return nil, fmt.Errorf("invalid ntfy proxy configuration: %w", err)
+		}
+	}
+	ntfy.hc = ntfy.newHTTPClient(proxy)
+	return &ntfy, nil
+}
+
+func (n *Ntfy) newHTTPClient(proxy *url.URL) *resty.Client {
+	client := resty.New().
+		SetTimeout(requestTimeout).
+		SetResponseBodyLimit(responseBodyLimit).
+		SetHeader("Content-Type", "application/json").
+		SetBaseURL(strings.TrimRight(n.Server, "/"))
+	if n.Token != "" {
+		client.SetAuthToken(n.Token)
+	}
+	if proxy != nil {
+		client.SetProxy(proxy.String())
+	}
+	return client
+}
+
+// Notify publishes the notification as JSON. Priorities use ntfy's 1 (min) to
+// 5 (max) scale.
+func (n *Ntfy) Notify(ctx context.Context, notification notifier.Notification) error {
+	priority, tag := 3, "information_source"
+	switch notification.Reason {
+	case notifier.ReasonIPChange:
+		priority, tag = 3, "globe_with_meridians"
+	case notifier.ReasonUpdateFailure:
+		priority, tag = 4, "x"
+	case notifier.ReasonUpdateSuccess:
+		priority, tag = 2, "white_check_mark"
+	}
+
+	resp, err := n.hc.R().SetContext(ctx).SetBody(&message{
+		Topic:    n.Topic,
+		Title:    notification.Title,
+		Message:  notification.Message,
+		Priority: priority,
+		Tags:     []string{tag},
+	}).Post("/")
+	if err != nil {
+		return n.redactError(err)
+	}
+	if resp.IsSuccess() {
+		return nil
+	}
+
+	apiResp := apiResponse{}
+	_ = json.Unmarshal(resp.Body(), &apiResp)
+	if apiResp.Error == "" {
+		return n.redactError(fmt.Errorf("ntfy request failed: HTTP status %d", resp.StatusCode()))
+	}
+	return n.redactError(fmt.Errorf("ntfy request failed: HTTP status %d, code %d, error %q", resp.StatusCode(), apiResp.Code, apiResp.Error))
+}
+
+func (n *Ntfy) redactError(err error) error {
+	return redact.Error(err, n.Token)
+}
@@ -0,0 +1,89 @@
+package ntfy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/we11adam/uddns/internal/testutil"
+	"github.com/we11adam/uddns/notifier"
+)
+
+func TestNewValidatesConfig(t *testing.T) {
+	ntfy, err := New(&Ntfy{Topic: "uddns"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ntfy.hc.BaseURL != defaultServer {
+		t.Fatalf("expected default server, got %q", ntfy.hc.BaseURL)
+	}
+	for _, config := range []*Ntfy{
+		{},
+		{Topic: "uddns", Server: "ntfy.example.com"},
+		{Topic: "uddns", Proxy: "socks5://proxy.example"},
+	} {
+		if _, err := New(config); err == nil {
+			t.Fatalf("expected %+v to be rejected", config)
+		}
+	}
+}
+
+func TestNotifyPublishesWithPriorityByReason(t *testing.T) {
+	tests := []struct {
+		reason   notifier.Reason
+		priority int
+		tag      string
+	}{
+		{notifier.ReasonIPChange, 3, "globe_with_meridians"},
+		{notifier.ReasonUpdateSuccess, 2, "white_check_mark"},
+		{notifier.ReasonUpdateFailure, 4, "x"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.reason), func(t *testing.T) {
+			var body message
+			var path, authorization string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path = r.URL.Path
+				authorization = r.Header.Get("Authorization")
+				_ = json.NewDecoder(r.Body).Decode(&body)
+				_, _ = w.Write([]byte(`{"id":"abc"}`))
+			}))
+			defer server.Close()
+
+			ntfy, err := New(&Ntfy{Server: server.URL + "/", Topic: "uddns", Token: "tk_secret"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := ntfy.Notify(context.Background(), notifier.Notification{Title: "home", Message: "test", Reason: tt.reason}); err != nil {
+				t.Fatal(err)
+			}
+			if path != "/" || authorization != "Bearer tk_secret" {
+				t.Fatalf("unexpected request: path=%q authorization=%q", path, authorization)
+			}
+			if body.Topic != "uddns" || body.Title != "home" || body.Message != "test" || body.Priority != tt.priority || len(body.Tags) != 1 || body.Tags[0] != tt.tag {
+				t.Fatalf("unexpected message: %+v", body)
+			}
+		})
+	}
+}
+
+func TestNotifyReportsErrorsWithoutToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"code":40301,"http":403,"error":"forbidden for tk_secret"}`))
+	}))
+	defer server.Close()
+
+	ntfy, err := New(&Ntfy{Server: server.URL, Topic: "uddns", Token: "tk_secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ntfy.Notify(context.Background(), notifier.Notification{Message: "test"})
+	if err == nil || !strings.Contains(err.Error(), "HTTP status 403") || !strings.Contains(err.Error(), "40301") {
+		t.Fatalf("expected ntfy API error, got %v", err)
+	}
+	testutil.AssertTokenRedacted(t, err.Error(), "tk_secret")
+}
@@ -0,0 +1,150 @@
+package pushover
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+
+	"github.com/we11adam/uddns/internal/proxyurl"
+	"github.com/we11adam/uddns/internal/redact"
+	"github.com/we11adam/uddns/notifier"
+)
+
+const (
+	requestTimeout    = 10 * time.Second
+	responseBodyLimit = 256 << 10
+	defaultServer     = "https://api.pushover.net"
+)
+
+type Pushover struct {
+	// Token is the Pushover application API token.
+	Token string `mapstructure:"token"`
+	// User is the user or group key to notify.
+	User string `mapstructure:"user"`
+	// Device optionally limits delivery to one of the user's devices.
+	Device string `mapstructure:"device"`
+	// Server is the API base URL. Empty means https://api.pushover.net; it can
+	// point to a compatible relay.
+	Server string `mapstructure:"server"`
+	Proxy  string `mapstructure:"proxy"`
+	hc     *resty.Client
+}
+
+type message struct {
+	Token    string `json:"token"`
+	User     string `json:"user"`
+	Device   string `json:"device,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Message  string `json:"message"`
+	Priority int    `json:"priority"`
+}
+
+type apiResponse struct {
+	Status int      `json:"status"`
+	Errors []string `json:"errors"`
+}
+
+func init() {
+	notifier.Register("Pushover", "notifiers.pushover", func(v notifier.ConfigReader) (notifier.Notifier, error) {
+		if !v.IsSet("notifiers.pushover") {
+			return nil, notifier.ErrNotConfigured
+		}
+
+		pushover := Pushover{}
+		err := v.UnmarshalKey("notifiers.pushover", &pushover)
+		if err != nil {
+			return nil, err
+		}
+
+		return New(&pushover)
+	})
+}
+
+func New(config *Pushover) (*Pushover, error) {
+	if config == nil {
+		return nil, fmt.Errorf("Pushover config is nil")
+	}
+	if config.Token == "" || config.User == "" {
+		return nil, fmt.Errorf("Pushover token and user are required")
+	}
+
+	pushover := *config
+	if pushover.Server == "" {
+		pushover.Server = defaultServer
+	}
+	server, err := url.Parse(pushover.Server)
+	if err != nil || (server.Scheme != "http" && server.Scheme != "https") || server.Host == "" {
+		return nil, fmt.Errorf("Pushover server must be an absolute http or https URL")
+	}
+
+	var proxy *url.URL
+	if pushover.Proxy != "" {
+		if proxy, err = proxyurl.Parse(pushover.Proxy); err != nil {
+			return nil, fmt.Errorf("invalid Pushover proxy configuration: %w", err)
+		}
+	}
+	pushover.hc = pushover.newHTTPClient(proxy)
+	return &pushover, nil
+}
+
+func (p *Pushover) newHTTPClient(proxy *url.URL) *resty.Client {
+	client := resty.New().
+		SetTimeout(requestTimeout).
+		SetResponseBodyLimit(responseBodyLimit).
+		SetHeader("Content-Type", "application/json").
+		SetBaseURL(strings.TrimRight(p.Server, "/"))
+	if proxy != nil {
+		client.SetProxy(proxy.String())
+	}
+	return client
+}
+
+// Notify sends a Pushover message. Priorities use Pushover's -2 to 2 scale;
+// failures are sent as high priority, which bypasses quiet hours.
+func (p *Pushover) Notify(ctx context.Context, notification notifier.Notification) error {
+	priority := 0
+	switch notification.Reason {
+	case notifier.ReasonUpdateFailure:
+		priority = 1
+	case notifier.ReasonUpdateSuccess:
+		priority = -1
+	}
+
+	resp, err := p.hc.R().SetContext(ctx).SetBody(&message{
+		Token:    p.Token,
+		User:     p.User,
+		Device:   p.Device,
+		Title:    notification.Title,
+		Message:  notification.Message,
+		Priority: priority,
+	}).Post("/1/messages.json")
+	if err != nil {
+		return p.redactError(err)
+	}
+
+	apiResp := apiResponse{}
+	decodeErr := json.Unmarshal(resp.Body(), &apiResp)
+	if !resp.IsSuccess() || (decodeErr == nil && apiResp.Status != 1) {
+		return p.redactError(p.apiError(resp.StatusCode(), apiResp))
+	}
+	if decodeErr != nil {
+		return p.redactError(fmt.Errorf("failed to decode Pushover API response: %w", decodeErr))
+	}
+	return nil
+}
+
+func (p *Pushover) apiError(statusCode int, response apiResponse) error {
+	if len(response.Errors) == 0 {
+		return fmt.Errorf("Pushover API request failed: HTTP status %d", statusCode)
+	}
+	return fmt.Errorf("Pushover API request failed: HTTP status %d, errors %q", statusCode, strings.Join(response.Errors, "; "))
+}
+
+func (p *Pushover) redactError(err error) error {
+	return redact.Error(err, p.Token, p.User)
+}
@@ -0,0 +1,103 @@
+package pushover
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/we11adam/uddns/internal/testutil"
+	"github.com/we11adam/uddns/notifier"
+)
+
+func TestNewValidatesConfig(t *testing.T) {
+	pushover, err := New(&Pushover{Token: "token", User: "user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pushover.hc.BaseURL != defaultServer {
+		t.Fatalf("expected default server, got %q", pushover.hc.BaseURL)
+	}
+	for _, config := range []*Pushover{
+		{},
+		{Token: "token"},
+		{Token: "token", User: "user", Server: "api.pushover.net"},
+		{Token: "token", User: "user", Proxy: "socks5://proxy.example"},
+	} {
+		if _, err := New(config); err == nil {
+			t.Fatalf("expected %+v to be rejected", config)
+		}
+	}
+}
+
+func TestNotifySendsMessageWithPriorityByReason(t *testing.T) {
+	tests := []struct {
+		reason   notifier.Reason
+		priority int
+	}{
+		{notifier.ReasonIPChange, 0},
+		{notifier.ReasonUpdateSuccess, -1},
+		{notifier.ReasonUpdateFailure, 1},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.reason), func(t *testing.T) {
+			var body message
+			var path string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path = r.URL.Path
+				_ = json.NewDecoder(r.Body).Decode(&body)
+				_, _ = w.Write([]byte(`{"status":1,"request":"abc"}`))
+			}))
+			defer server.Close()
+
+			pushover, err := New(&Pushover{Token: "app-token", User: "user-key", Device: "phone", Server: server.URL})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := pushover.Notify(context.Background(), notifier.Notification{Title: "home", Message: "test", Reason: tt.reason}); err != nil {
+				t.Fatal(err)
+			}
+			if path != "/1/messages.json" {
+				t.Fatalf("unexpected path %q", path)
+			}
+			want := message{Token: "app-token", User: "user-key", Device: "phone", Title: "home", Message: "test", Priority: tt.priority}
+			if body != want {
+				t.Fatalf("message = %+v, want %+v", body, want)
+			}
+		})
+	}
+}
+
+func TestNotifyChecksPushoverAPIResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		body       string
+		want       string
+	}{
+		{"http 400", http.StatusBadRequest, `{"status":0,"errors":["application token is invalid: app-token"]}`, "application token is invalid"},
+		{"status 0", http.StatusOK, `{"status":0,"errors":["user key is invalid"]}`, "user key is invalid"},
+		{"undecodable", http.StatusOK, `not json`, "failed to decode"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tt.statusCode)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			pushover, err := New(&Pushover{Token: "app-token", User: "user-key", Server: server.URL})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = pushover.Notify(context.Background(), notifier.Notification{Message: "test"})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+			testutil.AssertTokenRedacted(t, err.Error(), "app-token")
+		})
+	}
+}
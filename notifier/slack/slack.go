@@ -0,0 +1,174 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+
+	"github.com/we11adam/uddns/internal/httpbody"
+	"github.com/we11adam/uddns/internal/proxyurl"
+	"github.com/we11adam/uddns/internal/redact"
+	"github.com/we11adam/uddns/notifier"
+)
+
+const (
+	requestTimeout     = 10 * time.Second
+	responseBodyLimit  = 256 << 10
+	errorBodyLimit     = 200
+	chatPostMessageURL = "https://slack.com/api/chat.postMessage"
+)
+
+type Slack struct {
+	// WebhookURL is an incoming webhook URL. Alternatively, Token and Channel
+	// post through chat.postMessage.
+	WebhookURL string `mapstructure:"webhook_url"`
+	Token      string `mapstructure:"token"`
+	Channel    string `mapstructure:"channel"`
+	Proxy      string `mapstructure:"proxy"`
+	hc         *resty.Client
+}
+
+type attachment struct {
+	Color    string `json:"color"`
+	Fallback string `json:"fallback"`
+	Title    string `json:"title,omitempty"`
+	Text     string `json:"text"`
+}
+
+type message struct {
+	Channel     string       `json:"channel,omitempty"`
+	Attachments []attachment `json:"attachments"`
+}
+
+type apiResponse struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error"`
+}
+
+func init() {
+	notifier.Register("Slack", "notifiers.slack", func(v notifier.ConfigReader) (notifier.Notifier, error) {
+		if !v.IsSet("notifiers.slack") {
+			return nil, notifier.ErrNotConfigured
+		}
+
+		slack := Slack{}
+		err := v.UnmarshalKey("notifiers.slack", &slack)
+		if err != nil {
+			return nil, err
+		}
+
+		return New(&slack)
+	})
+}
+
+func New(config *Slack) (*Slack, error) {
+	if config == nil {
+		return nil, fmt.Errorf("Slack config is nil")
+	}
+	if config.WebhookURL != "" && (config.Token != "" || config.Channel != "") {
+		return nil, fmt.Errorf("Slack config must either provide webhook_url or token and channel, not a combination")
+	}
+	if config.WebhookURL == "" && (config.Token == "" || config.Channel == "") {
+		return nil, fmt.Errorf("Slack config must provide webhook_url or token and channel")
+	}
+	if config.WebhookURL != "" {
+		target, err := url.Parse(config.WebhookURL)
+		if err != nil || target.Scheme != "https" || target.Host == "" {
+			return nil, fmt.Errorf("Slack webhook_url must be an absolute https URL")
+		}
+	}
+
+	var proxy *url.URL
+	if config.Proxy != "" {
+		parsed, err := proxyurl.Parse(config.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Slack proxy configuration: %w", err)
+		}
+		proxy = parsed
+	}
+
+	slack := *config
+	slack.hc = slack.newHTTPClient(proxy)
+	return &slack, nil
+}
+
+func (s *Slack) newHTTPClient(proxy *url.URL) *resty.Client {
+	client := resty.New().
+		SetTimeout(requestTimeout).
+		SetResponseBodyLimit(responseBodyLimit).
+		SetHeader("Content-Type", "application/json; charset=utf-8")
+	if s.WebhookURL != "" {
+		client.SetBaseURL(s.WebhookURL)
+	} else {
+		client.SetBaseURL(chatPostMessageURL).SetAuthToken(s.Token)
+	}
+	if proxy != nil {
+		client.SetProxy(proxy.String())
+	}
+	return client
+}
+
+func (s *Slack) Notify(ctx context.Context, notification notifier.Notification) error {
+	color, emoji := "#000000", ":information_source:"
+	switch notification.Reason {
+	case notifier.ReasonIPChange:
+		color, emoji = "#3498DB", ":globe_with_meridians:"
+	case notifier.ReasonUpdateFailure:
+		color, emoji = "#FF0000", ":x:"
+	case notifier.ReasonUpdateSuccess:
+		color, emoji = "#00FF00", ":white_check_mark:"
+	}
+
+	resp, err := s.hc.R().SetContext(ctx).SetBody(&message{
+		Channel: s.Channel,
+		Attachments: []attachment{
+			{
+				Color:    color,
+				Fallback: notification.Message,
+				Title:    notification.Title,
+				Text:     emoji + " " + notification.Message,
+			},
+		},
+	}).Post("")
+	if err != nil {
+		return s.redactError(err)
+	}
+
+	if resp.StatusCode() == http.StatusTooManyRequests {
+		return s.redactError(fmt.Errorf("Slack API request failed, rate limited, retry after %s", resp.Header().Get("Retry-After")))
+	}
+	if !resp.IsSuccess() {
+		return s.redactError(s.apiError(resp.StatusCode(), strings.TrimSpace(string(resp.Body()))))
+	}
+	if s.WebhookURL != "" {
+		return nil
+	}
+
+	// chat.postMessage reports errors with HTTP 200 and ok set to false.
+	apiResp := apiResponse{}
+	if err := json.Unmarshal(resp.Body(), &apiResp); err != nil {
+		return s.redactError(fmt.Errorf("failed to decode Slack API response: %w", err))
+	}
+	if !apiResp.OK {
+		return s.redactError(s.apiError(resp.StatusCode(), apiResp.Error))
+	}
+	return nil
+}
+
+func (s *Slack) apiError(statusCode int, message string) error {
+	message = httpbody.Truncate(message, errorBodyLimit)
+	if message == "" {
+		return fmt.Errorf("Slack API request failed: HTTP status %d", statusCode)
+	}
+	return fmt.Errorf("Slack API request failed: HTTP status %d, error %q", statusCode, message)
+}
+
+func (s *Slack) redactError(err error) error {
+	return redact.Error(err, append(redact.URLSecrets(s.WebhookURL), s.Token)...)
+}
@@ -0,0 +1,167 @@
+package teams
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+
+	"github.com/we11adam/uddns/internal/httpbody"
+	"github.com/we11adam/uddns/internal/proxyurl"
+	"github.com/we11adam/uddns/internal/redact"
+	"github.com/we11adam/uddns/notifier"
+)
+
+const (
+	requestTimeout    = 10 * time.Second
+	responseBodyLimit = 256 << 10
+	errorBodyLimit    = 200
+	cardContentType   = "application/vnd.microsoft.card.adaptive"
+	cardSchema        = "http://adaptivecards.io/schemas/adaptive-card.json"
+	cardVersion       = "1.4"
+)
+
+type Teams struct {
+	// URL is a Teams workflow or incoming webhook URL.
+	URL   string `mapstructure:"url"`
+	Proxy string `mapstructure:"proxy"`
+	hc    *resty.Client
+}
+
+type textBlock struct {
+	Type   string `json:"type"`
+	Text   string `json:"text"`
+	Wrap   bool   `json:"wrap"`
+	Weight string `json:"weight,omitempty"`
+	Size   string `json:"size,omitempty"`
+	Color  string `json:"color,omitempty"`
+}
+
+type adaptiveCard struct {
+	Schema  string      `json:"$schema"`
+	Type    string      `json:"type"`
+	Version string      `json:"version"`
+	Body    []textBlock `json:"body"`
+}
+
+type attachment struct {
+	ContentType string       `json:"contentType"`
+	Content     adaptiveCard `json:"content"`
+}
+
+type message struct {
+	Type        string       `json:"type"`
+	Attachments []attachment `json:"attachments"`
+}
+
+func init() {
+	notifier.Register("Teams", "notifiers.teams", func(v notifier.ConfigReader) (notifier.Notifier, error) {
+		if !v.IsSet("notifiers.teams") {
+			return nil, notifier.ErrNotConfigured
+		}
+
+		teams := Teams{}
+		err := v.UnmarshalKey("notifiers.teams", &teams)
+		if err != nil {
+			return nil, err
+		}
+
+		return New(&teams)
+	})
+}
+
+func New(config *Teams) (*Teams, error) {
+	if config == nil {
+		return nil, fmt.Errorf("Teams config is nil")
+	}
+	if config.URL == "" {
+		return nil, fmt.Errorf("Teams config must provide url")
+	}
+	target, err := url.Parse(config.URL)
+	if err != nil || target.Scheme != "https" || target.Host == "" {
+		return nil, fmt.Errorf("Teams url must be an absolute https URL")
+	}
+
+	var proxy *url.URL
+	if config.Proxy != "" {
+		if proxy, err = proxyurl.Parse(config.Proxy); err != nil {
+			return nil, fmt.Errorf("invalid Teams proxy configuration: %w", err)
+		}
+	}
+
+	teams := *config
+	teams.hc = newHTTPClient(teams.URL, proxy)
+	return &teams, nil
+}
+
+func newHTTPClient(webhookURL string, proxy *url.URL) *resty.Client {
+	client := resty.New().
+		SetTimeout(requestTimeout).
+		SetResponseBodyLimit(responseBodyLimit).
+		SetHeader("Content-Type", "application/json").
+		SetBaseURL(webhookURL)
+	if proxy != nil {
+		client.SetProxy(proxy.String())
+	}
+	return client
+}
+
+func (t *Teams) Notify(ctx context.Context, notification notifier.Notification) error {
+	color, emoji := "Default", "ℹ️"
+	switch notification.Reason {
+	case notifier.ReasonIPChange:
+		color, emoji = "Accent", "🌐"
+	case notifier.ReasonUpdateFailure:
+		color, emoji = "Attention", "❌"
+	case notifier.ReasonUpdateSuccess:
+		color, emoji = "Good", "✅"
+	}
+
+	var body []textBlock
+	if notification.Title != "" {
+		body = append(body, textBlock{Type: "TextBlock", Text: notification.Title, Wrap: true, Weight: "Bolder", Size: "Medium"})
+	}
+	body = append(body, textBlock{Type: "TextBlock", Text: emoji + " " + notification.Message, Wrap: true, Color: color})
+
+	resp, err := t.hc.R().SetContext(ctx).SetBody(&message{
+		Type: "message",
+		Attachments: []attachment{
+			{
+				ContentType: cardContentType,
+				Content: adaptiveCard{
+					Schema:  cardSchema,
+					Type:    "AdaptiveCard",
+					Version: cardVersion,
+					Body:    body,
+				},
+			},
+		},
+	}).Post("")
+	if err != nil {
+		return t.redactError(err)
+	}
+
+	if resp.StatusCode() == http.StatusTooManyRequests {
+		return t.redactError(fmt.Errorf("Teams webhook request failed, rate limited, retry after %s", resp.Header().Get("Retry-After")))
+	}
+	if !resp.IsSuccess() {
+		return t.redactError(t.apiError(resp.StatusCode(), strings.TrimSpace(string(resp.Body()))))
+	}
+	return nil
+}
+
+func (t *Teams) apiError(statusCode int, message string) error {
+	message = httpbody.Truncate(message, errorBodyLimit)
+	if message == "" {
+		return fmt.Errorf("Teams webhook request failed: HTTP status %d", statusCode)
+	}
+	return fmt.Errorf("Teams webhook request failed: HTTP status %d, response %q", statusCode, message)
+}
+
+func (t *Teams) redactError(err error) error {
+	return redact.Error(err, redact.URLSecrets(t.URL)...)
+}
@@ -0,0 +1,96 @@
+package teams
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/we11adam/uddns/internal/testutil"
+	"github.com/we11adam/uddns/notifier"
+)
+
+const workflowURL = "https://prod.westus.logic.azure.com/workflows/abc/triggers/manual/paths/invoke?api-version=2016-06-01&sig=workflow-signature"
+
+type failingTransport struct {
+	message string
+}
+
+func (f failingTransport) RoundTrip(_ *http.Request) (*http.Response, error) {
+	return nil, errors.New(f.message)
+}
+
+func TestNewValidatesConfig(t *testing.T) {
+	for _, config := range []*Teams{
+		{},
+		{URL: "http://example.com/webhook"},
+		{URL: workflowURL, Proxy: "socks5://proxy.example"},
+	} {
+		if _, err := New(config); err == nil {
+			t.Fatalf("expected %+v to be rejected", config)
+		}
+	}
+}
+
+func TestNotifyPostsAdaptiveCard(t *testing.T) {
+	var body message
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	teams, err := New(&Teams{URL: workflowURL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	teams.hc.SetBaseURL(server.URL)
+	err = teams.Notify(context.Background(), notifier.Notification{Title: "home", Message: "DNS records updated", Reason: notifier.ReasonUpdateSuccess})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if body.Type != "message" || len(body.Attachments) != 1 || body.Attachments[0].ContentType != cardContentType {
+		t.Fatalf("unexpected message: %+v", body)
+	}
+	card := body.Attachments[0].Content
+	if card.Type != "AdaptiveCard" || card.Version != cardVersion || len(card.Body) != 2 {
+		t.Fatalf("unexpected card: %+v", card)
+	}
+	if card.Body[0].Text != "home" || card.Body[0].Weight != "Bolder" {
+		t.Fatalf("unexpected title block: %+v", card.Body[0])
+	}
+	if card.Body[1].Text != "✅ DNS records updated" || card.Body[1].Color != "Good" || !card.Body[1].Wrap {
+		t.Fatalf("unexpected message block: %+v", card.Body[1])
+	}
+}
+
+func TestNotifyRedactsWebhookURL(t *testing.T) {
+	teams, err := New(&Teams{URL: workflowURL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	teams.hc.SetTransport(failingTransport{message: "request failed, sig=workflow-signature"})
+	err = teams.Notify(context.Background(), notifier.Notification{Message: "test"})
+	if err == nil {
+		t.Fatal("expected transport error")
+	}
+	testutil.AssertTokenRedacted(t, err.Error(), "workflow-signature")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte("signature workflow-signature is invalid"))
+	}))
+	defer server.Close()
+	teams.hc.SetTransport(http.DefaultTransport).SetBaseURL(server.URL)
+	err = teams.Notify(context.Background(), notifier.Notification{Message: "test"})
+	if err == nil || !strings.Contains(err.Error(), "HTTP status 401") {
+		t.Fatalf("expected HTTP status error, got %v", err)
+	}
+	testutil.AssertTokenRedacted(t, err.Error(), "workflow-signature")
+}
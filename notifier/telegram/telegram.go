@@ -4,7 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"html"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/go-resty/resty/v2"
@@ -22,7 +24,10 @@ type Telegram struct {
 	Token  string `mapstructure:"token"`
 	ChatID string `mapstructure:"chat_id"`
 	Proxy  string `mapstructure:"proxy"`
-	hc     *resty.Client
+	// ParseMode is sent as Telegram's parse_mode: HTML, Markdown, or
+	// MarkdownV2. Empty sends plain text.
+	ParseMode string `mapstructure:"parse_mode"`
+	hc        *resty.Client
 }
 
 type apiResponse struct {
@@ -54,6 +59,10 @@ func New(config *Telegram) (*Telegram, error) {
 	if config.Token == "" || config.ChatID == "" {
 		return nil, fmt.Errorf("missing required fields")
 	}
+	parseMode, err := normalizeParseMode(config.ParseMode)
+	if err != nil {
+		return nil, err
+	}
 
 	var proxy *url.URL
 	if config.Proxy != "" {
@@ -65,10 +74,26 @@ func New(config *Telegram) (*Telegram, error) {
 	}
 
 	telegram := *config
+	telegram.ParseMode = parseMode
 	telegram.hc = newHTTPClient(telegram.Token, proxy)
 	return &telegram, nil
 }
 
+func normalizeParseMode(value string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "":
+		return "", nil
+	case "html":
+		return "HTML", nil
+	case "markdown":
+		return "Markdown", nil
+	case "markdownv2":
+		return "MarkdownV2", nil
+	default:
+		return "", fmt.Errorf("unsupported Telegram parse_mode %q; supported values: HTML, Markdown, MarkdownV2", value)
+	}
+}
+
 func newHTTPClient(token string, proxy *url.URL) *resty.Client {
 	client := resty.New().
 		SetTimeout(requestTimeout).
@@ -82,10 +107,20 @@ func newHTTPClient(token string, proxy *url.URL) *resty.Client {
 }
 
 func (t *Telegram) Notify(ctx context.Context, notification notifier.Notification) error {
-	resp, err := t.hc.R().SetContext(ctx).SetBody(map[string]any{
+	// parse_mode is meant for templates. The default message is plain text
+	// that may contain an error with <, _, or similar, so it is escaped to
+	// keep Telegram from rejecting it.
+	if notification.Event.Message != "" && notification.Message == notification.Event.Message {
+		notification.Message = escape(t.ParseMode, notification.Message)
+	}
+	body := map[string]any{
 		"chat_id": t.ChatID,
-		"text":    notification.Message,
-	}).Post("")
+		"text":    notification.Text(),
+	}
+	if t.ParseMode != "" {
+		body["parse_mode"] = t.ParseMode
+	}
+	resp, err := t.hc.R().SetContext(ctx).SetBody(body).Post("")
 	if err != nil {
 		return redact.Error(err, t.Token)
 	}
@@ -105,6 +140,30 @@ func (t *Telegram) Notify(ctx context.Context, notification notifier.Notificatio
 	return nil
 }
 
+// markdownV2Escaper escapes the characters MarkdownV2 reserves.
+var markdownV2Escaper = strings.NewReplacer(
+	`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`,
+	"~", `\~`, "`", "\\`", ">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`,
+	"|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`,
+)
+
+// markdownEscaper escapes the entity characters of legacy Markdown.
+var markdownEscaper = strings.NewReplacer("_", `\_`, "*", `\*`, "`", "\\`", "[", `\[`)
+
+// escape makes plain text safe to send with parseMode.
+func escape(parseMode, text string) string {
+	switch parseMode {
+	case "HTML":
+		return html.EscapeString(text)
+	case "MarkdownV2":
+		return markdownV2Escaper.Replace(text)
+	case "Markdown":
+		return markdownEscaper.Replace(text)
+	default:
+		return text
+	}
+}
+
 func (t *Telegram) apiError(statusCode int, response apiResponse) error {
 	description := redact.String(response.Description, t.Token)
 	if description == "" {
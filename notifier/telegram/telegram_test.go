@@ -2,6 +2,7 @@ package telegram
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
@@ -127,6 +128,78 @@ func TestNotifyChecksTelegramAPIResponse(t *testing.T) {
 	}
 }
 
+func TestNotifySendsParseMode(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		_, _ = w.Write([]byte(`{"ok":true,"result":{}}`))
+	}))
+	defer server.Close()
+
+	telegram, err := New(&Telegram{Token: "token", ChatID: "123456", ParseMode: "html"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	telegram.hc.SetBaseURL(server.URL)
+	if err := telegram.Notify(context.Background(), notifier.Notification{Message: "<b>home</b>"}); err != nil {
+		t.Fatal(err)
+	}
+	if body["parse_mode"] != "HTML" || body["text"] != "<b>home</b>" {
+		t.Fatalf("expected HTML parse mode, got %v", body)
+	}
+
+	if _, err := New(&Telegram{Token: "token", ChatID: "123456", ParseMode: "rich"}); err == nil {
+		t.Fatal("expected unsupported parse mode to fail")
+	}
+}
+
+func TestNotifyEscapesDefaultMessageAndSendsTitle(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		_, _ = w.Write([]byte(`{"ok":true,"result":{}}`))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		parseMode string
+		want      string
+	}{
+		{parseMode: "", want: "DNS update failed: <nil> for home_lan."},
+		{parseMode: "HTML", want: "DNS update failed: &lt;nil&gt; for home_lan."},
+		{parseMode: "Markdown", want: `DNS update failed: <nil> for home\_lan.`},
+		{parseMode: "MarkdownV2", want: `DNS update failed: <nil\> for home\_lan\.`},
+	}
+	for _, tt := range tests {
+		telegram, err := New(&Telegram{Token: "token", ChatID: "123456", ParseMode: tt.parseMode})
+		if err != nil {
+			t.Fatal(err)
+		}
+		telegram.hc.SetBaseURL(server.URL)
+		message := "DNS update failed: <nil> for home_lan."
+		notification := notifier.Notification{Message: message, Event: notifier.Event{Message: message}}
+		if err := telegram.Notify(context.Background(), notification); err != nil {
+			t.Fatal(err)
+		}
+		if body["text"] != tt.want {
+			t.Fatalf("expected %s text %q, got %v", tt.parseMode, tt.want, body["text"])
+		}
+	}
+
+	telegram, err := New(&Telegram{Token: "token", ChatID: "123456", ParseMode: "HTML"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	telegram.hc.SetBaseURL(server.URL)
+	notification := notifier.Notification{Title: "<b>home</b>", Message: "DNS updated", Event: notifier.Event{Message: "DNS updated"}}
+	if err := telegram.Notify(context.Background(), notification); err != nil {
+		t.Fatal(err)
+	}
+	if body["text"] != "<b>home</b>\nDNS updated" {
+		t.Fatalf("expected the title above the message, got %v", body["text"])
+	}
+}
+
 func TestNotifyCancelsInFlightRequest(t *testing.T) {
 	requestStarted := make(chan struct{})
 	releaseRequest := make(chan struct{})
@@ -0,0 +1,192 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Event describes what a notification is about. Templates receive it as their
+// data, so its field names are part of the configuration format.
+type Event struct {
+	Reason   Reason
+	Job      string
+	Record   string
+	Zone     string
+	Provider string
+	Updater  string
+	// Family is "ipv4" or "ipv6" for an ip_change notification, which covers
+	// one address family; it is empty otherwise.
+	Family   string
+	OldIPv4  string
+	NewIPv4  string
+	OldIPv6  string
+	NewIPv6  string
+	Error    string
+	Time     time.Time
+	Hostname string
+	// Message and Title are the notification's default text.
+	Message string
+	Title   string
+}
+
+// defaultTemplate is the templates key used for reasons without their own
+// template.
+const defaultTemplate = "default"
+
+// Templates renders notification messages and titles with text/template.
+// Templates are keyed by reason, with "default" covering the other reasons.
+type Templates struct {
+	messages map[string]*template.Template
+	titles   map[string]*template.Template
+}
+
+// ParseTemplates parses message and title templates keyed by reason or
+// "default". It executes each template against a sample event so that unknown
+// fields are reported with the configuration instead of at delivery time.
+func ParseTemplates(messages, titles map[string]string) (*Templates, error) {
+	if len(messages) == 0 && len(titles) == 0 {
+		return nil, nil
+	}
+	parsedMessages, err := parseTemplateSet("templates", messages)
+	if err != nil {
+		return nil, err
+	}
+	parsedTitles, err := parseTemplateSet("title_templates", titles)
+	if err != nil {
+		return nil, err
+	}
+	return &Templates{messages: parsedMessages, titles: parsedTitles}, nil
+}
+
+func parseTemplateSet(name string, sources map[string]string) (map[string]*template.Template, error) {
+	parsed := make(map[string]*template.Template, len(sources))
+	for key, source := range sources {
+		key = strings.ToLower(strings.TrimSpace(key))
+		if key != defaultTemplate && !isReason(Reason(key)) {
+			return nil, fmt.Errorf("%s has unsupported key %q; supported values: %s, %s", name, key, reasonList(), defaultTemplate)
+		}
+		tmpl, err := ParseTemplate(name+"."+key, source)
+		if err != nil {
+			return nil, err
+		}
+		parsed[key] = tmpl
+	}
+	return parsed, nil
+}
+
+// templateFuncs are available to every notification template. json encodes a
+// value, so templates can build JSON documents from event fields.
+var templateFuncs = template.FuncMap{
+	"json": func(value any) (string, error) {
+		encoded, err := json.Marshal(value)
+		return string(encoded), err
+	},
+}
+
+// ParseTemplate parses a template that is executed with an Event. Like
+// ParseTemplates, it executes the template against a sample event to report
+// unknown fields early.
+func ParseTemplate(name, source string) (*template.Template, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Funcs(templateFuncs).Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	sample := Event{Reason: ReasonUpdateSuccess, Job: "job", Time: time.Unix(0, 0)}
+	if err := tmpl.Execute(&bytes.Buffer{}, sample); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// TemplateData returns the event with the notification's reason, job, and
+// current text filled in.
+func (n Notification) TemplateData() Event {
+	data := n.Event
+	data.Reason = n.Reason
+	data.Job = n.Job
+	data.Message = n.Message
+	data.Title = n.Title
+	return data
+}
+
+// eventJSON is the JSON form of a notification's event.
+type eventJSON struct {
+	Reason   Reason    `json:"reason"`
+	Job      string    `json:"job"`
+	Title    string    `json:"title,omitempty"`
+	Message  string    `json:"message"`
+	Record   string    `json:"record,omitempty"`
+	Zone     string    `json:"zone,omitempty"`
+	Provider string    `json:"provider,omitempty"`
+	Updater  string    `json:"updater,omitempty"`
+	Family   string    `json:"family,omitempty"`
+	OldIPv4  string    `json:"old_ipv4,omitempty"`
+	NewIPv4  string    `json:"new_ipv4,omitempty"`
+	OldIPv6  string    `json:"old_ipv6,omitempty"`
+	NewIPv6  string    `json:"new_ipv6,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Time     time.Time `json:"time"`
+	Hostname string    `json:"hostname,omitempty"`
+}
+
+// JSON encodes the notification's event as a JSON object with snake_case
+// keys. It is the default webhook body and the MQTT event payload.
+func (n Notification) JSON() ([]byte, error) {
+	data := n.TemplateData()
+	return json.Marshal(eventJSON{
+		Reason:   data.Reason,
+		Job:      data.Job,
+		Title:    data.Title,
+		Message:  data.Message,
+		Record:   data.Record,
+		Zone:     data.Zone,
+		Provider: data.Provider,
+		Updater:  data.Updater,
+		Family:   data.Family,
+		OldIPv4:  data.OldIPv4,
+		NewIPv4:  data.NewIPv4,
+		OldIPv6:  data.OldIPv6,
+		NewIPv6:  data.NewIPv6,
+		Error:    data.Error,
+		Time:     data.Time,
+		Hostname: data.Hostname,
+	})
+}
+
+// Render returns the notification with its message and title replaced by the
+// templates for its reason. Without a matching template the original text is
+// kept.
+func (t *Templates) Render(notification Notification) (Notification, error) {
+	if t == nil {
+		return notification, nil
+	}
+	data := notification.TemplateData()
+	rendered := notification
+	var err error
+	if rendered.Message, err = execute(t.messages, notification.Reason, data, notification.Message); err != nil {
+		return notification, err
+	}
+	if rendered.Title, err = execute(t.titles, notification.Reason, data, notification.Title); err != nil {
+		return notification, err
+	}
+	return rendered, nil
+}
+
+func execute(templates map[string]*template.Template, reason Reason, data Event, fallback string) (string, error) {
+	tmpl, ok := templates[string(reason)]
+	if !ok {
+		tmpl, ok = templates[defaultTemplate]
+	}
+	if !ok {
+		return fallback, nil
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out.String()), nil
+}
@@ -0,0 +1,64 @@
+package notifier
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTemplatesRenderByReasonWithDefault(t *testing.T) {
+	templates, err := ParseTemplates(
+		map[string]string{
+			"ip_change": "{{.Job}}：{{.Family}} 从 {{.OldIPv4}} 变为 {{.NewIPv4}}\n",
+			"default":   "{{.Hostname}} {{.Message}}",
+		},
+		map[string]string{"update_failure": "{{.Record}} failed"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ipChange, err := templates.Render(Notification{
+		Message: "home: IPv4 address changed to 192.0.2.20",
+		Reason:  ReasonIPChange,
+		Job:     "home",
+		Event:   Event{Family: "ipv4", OldIPv4: "192.0.2.10", NewIPv4: "192.0.2.20"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ipChange.Message != "home：ipv4 从 192.0.2.10 变为 192.0.2.20" || ipChange.Title != "" {
+		t.Fatalf("unexpected ip_change rendering: %#v", ipChange)
+	}
+
+	failure, err := templates.Render(Notification{
+		Message: "DNS update failed",
+		Reason:  ReasonUpdateFailure,
+		Event:   Event{Record: "home.example.com", Hostname: "router"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failure.Message != "router DNS update failed" || failure.Title != "home.example.com failed" {
+		t.Fatalf("unexpected update_failure rendering: %#v", failure)
+	}
+}
+
+func TestParseTemplatesRejectsInvalidTemplates(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages map[string]string
+		message  string
+	}{
+		{"unknown reason", map[string]string{"outage": "x"}, `unsupported key "outage"`},
+		{"syntax", map[string]string{"ip_change": "{{.Job"}, "invalid templates.ip_change"},
+		{"unknown field", map[string]string{"default": "{{.Address}}"}, "can't evaluate field Address"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseTemplates(tt.messages, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.message) {
+				t.Fatalf("expected error containing %q, got %v", tt.message, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+
+	"github.com/we11adam/uddns/internal/proxyurl"
+	"github.com/we11adam/uddns/internal/redact"
+	"github.com/we11adam/uddns/internal/restyretry"
+	"github.com/we11adam/uddns/notifier"
+)
+
+const (
+	requestTimeout         = 10 * time.Second
+	responseBodyLimit      = 256 << 10
+	defaultContentType     = "application/json"
+	defaultSignatureHeader = "X-UDDNS-Signature"
+	idempotencyKeyHeader   = "Idempotency-Key"
+	userAgent              = "uddns (https://github.com/we11adam/uddns)"
+)
+
+type Webhook struct {
+	URL string `mapstructure:"url"`
+	// Method is POST, PUT, or PATCH. Empty means POST.
+	Method  string            `mapstructure:"method"`
+	Headers map[string]string `mapstructure:"headers"`
+	// Body is a text/template executed with the notification event. Empty
+	// sends the event as JSON.
+	Body string `mapstructure:"body"`
+	// Secret, when set, signs the body with HMAC-SHA256. The hex digest is
+	// sent as "sha256=<digest>" in SignatureHeader.
+	Secret          string `mapstructure:"secret"`
+	SignatureHeader string `mapstructure:"signature_header"`
+	Proxy           string `mapstructure:"proxy"`
+	body            *template.Template
+	hc              *resty.Client
+}
+
+func init() {
+	notifier.Register("Webhook", "notifiers.webhook", func(v notifier.ConfigReader) (notifier.Notifier, error) {
+		if !v.IsSet("notifiers.webhook") {
+			return nil, notifier.ErrNotConfigured
+		}
+
+		webhook := Webhook{}
+		err := v.UnmarshalKey("notifiers.webhook", &webhook)
+		if err != nil {
+			return nil, err
+		}
+
+		return New(&webhook)
+	})
+}
+
+func New(config *Webhook) (*Webhook, error) {
+	if config == nil {
+		return nil, fmt.Errorf("webhook config is nil")
+	}
+	if config.URL == "" {
+		return nil, fmt.Errorf("webhook url is required")
+	}
+	target, err := url.Parse(config.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, fmt.Errorf("webhook url must be an absolute http or https URL")
+	}
+
+	webhook := *config
+	webhook.Method = strings.ToUpper(strings.TrimSpace(webhook.Method))
+	switch webhook.Method {
+	case "":
+		webhook.Method = http.MethodPost
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+	default:
+		return nil, fmt.Errorf("unsupported webhook method %q; supported values: POST, PUT, PATCH", config.Method)
+	}
+	if webhook.SignatureHeader == "" {
+		webhook.SignatureHeader = defaultSignatureHeader
+	}
+	if webhook.Body != "" {
+		if webhook.body, err = notifier.ParseTemplate("webhook body", webhook.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	var proxy *url.URL
+	if webhook.Proxy != "" {
+		if proxy, err = proxyurl.Parse(webhook.Proxy); err != nil {
+			return nil, fmt.Errorf("invalid webhook proxy configuration: %w", err)
+		}
+	}
+	webhook.hc = webhook.newHTTPClient(proxy)
+	return &webhook, nil
+}
+
+func (w *Webhook) newHTTPClient(proxy *url.URL) *resty.Client {
+	client := resty.New().
+		SetTimeout(requestTimeout).
+		SetResponseBodyLimit(responseBodyLimit).
+		SetHeader("Content-Type", defaultContentType).
+		SetHeader("User-Agent", userAgent).
+		SetHeaders(w.Headers)
+	restyretry.ConfigureTransient(client)
+	if proxy != nil {
+		client.SetProxy(proxy.String())
+	}
+	return client
+}
+
+func (w *Webhook) Notify(ctx context.Context, notification notifier.Notification) error {
+	body, err := w.render(notification)
+	if err != nil {
+		return w.redactError(fmt.Errorf("failed to render webhook body: %w", err))
+	}
+
+	// Retries, including the app's retry of a notification on a later run,
+	// send the same key, so receivers can drop a delivery they already
+	// processed.
+	request := w.hc.R().
+		SetContext(ctx).
+		SetHeader(idempotencyKeyHeader, notification.ID()).
+		SetBody(body)
+	if w.Secret != "" {
+		request.SetHeader(w.SignatureHeader, sign(w.Secret, body))
+	}
+	resp, err := request.Execute(w.Method, w.URL)
+	if err != nil {
+		return w.redactError(err)
+	}
+	if !resp.IsSuccess() {
+		return w.redactError(fmt.Errorf("webhook request failed: HTTP status %d", resp.StatusCode()))
+	}
+	return nil
+}
+
+func (w *Webhook) render(notification notifier.Notification) ([]byte, error) {
+	if w.body == nil {
+		return notification.JSON()
+	}
+	var out bytes.Buffer
+	if err := w.body.Execute(&out, notification.TemplateData()); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// sign returns the signature header value for body.
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// redactError removes the URL, its credentials and query values, the signing
+// secret, and header values, which commonly carry tokens.
+func (w *Webhook) redactError(err error) error {
+	secrets := append(redact.URLSecrets(w.URL), w.Secret)
+	for _, value := range w.Headers {
+		secrets = append(secrets, value)
+	}
+	return redact.Error(err, secrets...)
+}
@@ -0,0 +1,128 @@
+package wecom
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+
+	"github.com/we11adam/uddns/internal/proxyurl"
+	"github.com/we11adam/uddns/internal/redact"
+	"github.com/we11adam/uddns/notifier"
+)
+
+const (
+	requestTimeout    = 10 * time.Second
+	responseBodyLimit = 256 << 10
+	webhookURL        = "https://qyapi.weixin.qq.com/cgi-bin/webhook/send"
+)
+
+type WeCom struct {
+	// Key is the group robot webhook key.
+	Key   string `mapstructure:"key"`
+	Proxy string `mapstructure:"proxy"`
+	hc    *resty.Client
+}
+
+type textMessage struct {
+	MsgType string `json:"msgtype"`
+	Text    struct {
+		Content string `json:"content"`
+	} `json:"text"`
+}
+
+type apiResponse struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+func init() {
+	notifier.Register("WeCom", "notifiers.wecom", func(v notifier.ConfigReader) (notifier.Notifier, error) {
+		if !v.IsSet("notifiers.wecom") {
+			return nil, notifier.ErrNotConfigured
+		}
+
+		wecom := WeCom{}
+		err := v.UnmarshalKey("notifiers.wecom", &wecom)
+		if err != nil {
+			return nil, err
+		}
+
+		return New(&wecom)
+	})
+}
+
+func New(config *WeCom) (*WeCom, error) {
+	if config == nil {
+		return nil, fmt.Errorf("WeCom config is nil")
+	}
+	if config.Key == "" {
+		return nil, fmt.Errorf("WeCom key is required")
+	}
+
+	var proxy *url.URL
+	if config.Proxy != "" {
+		parsed, err := proxyurl.Parse(config.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid WeCom proxy configuration: %w", err)
+		}
+		proxy = parsed
+	}
+
+	wecom := *config
+	wecom.hc = newHTTPClient(proxy)
+	return &wecom, nil
+}
+
+func newHTTPClient(proxy *url.URL) *resty.Client {
+	client := resty.New().
+		SetTimeout(requestTimeout).
+		SetResponseBodyLimit(responseBodyLimit).
+		SetHeader("Content-Type", "application/json").
+		SetBaseURL(webhookURL)
+	if proxy != nil {
+		client.SetProxy(proxy.String())
+	}
+	return client
+}
+
+func (w *WeCom) Notify(ctx context.Context, notification notifier.Notification) error {
+	message := textMessage{MsgType: "text"}
+	message.Text.Content = notification.Text()
+
+	resp, err := w.hc.R().
+		SetContext(ctx).
+		SetQueryParam("key", w.Key).
+		SetBody(&message).
+		Post("")
+	if err != nil {
+		return w.redactError(err)
+	}
+
+	apiResp := apiResponse{}
+	decodeErr := json.Unmarshal(resp.Body(), &apiResp)
+	if !resp.IsSuccess() {
+		return w.redactError(w.apiError(resp.StatusCode(), apiResp))
+	}
+	if decodeErr != nil {
+		return w.redactError(fmt.Errorf("failed to decode WeCom API response: %w", decodeErr))
+	}
+	if apiResp.ErrCode != 0 {
+		return w.redactError(w.apiError(resp.StatusCode(), apiResp))
+	}
+	return nil
+}
+
+func (w *WeCom) apiError(statusCode int, response apiResponse) error {
+	if response.ErrMsg == "" {
+		return fmt.Errorf("WeCom API request failed: HTTP status %d, errcode %d", statusCode, response.ErrCode)
+	}
+	return fmt.Errorf("WeCom API request failed: HTTP status %d, errcode %d, errmsg %q", statusCode, response.ErrCode, response.ErrMsg)
+}
+
+func (w *WeCom) redactError(err error) error {
+	return redact.Error(err, w.Key)
+}
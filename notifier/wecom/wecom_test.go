@@ -0,0 +1,81 @@
+package wecom
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/we11adam/uddns/internal/testutil"
+	"github.com/we11adam/uddns/notifier"
+)
+
+func TestNewValidatesConfig(t *testing.T) {
+	for _, config := range []*WeCom{
+		{},
+		{Key: "key", Proxy: "socks5://proxy.example"},
+	} {
+		if _, err := New(config); err == nil {
+			t.Fatalf("expected %+v to be rejected", config)
+		}
+	}
+}
+
+func TestNotifySendsTextMessage(t *testing.T) {
+	var body textMessage
+	var key string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key = r.URL.Query().Get("key")
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		_, _ = w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+	}))
+	defer server.Close()
+
+	wecom, err := New(&WeCom{Key: "robot-key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wecom.hc.SetBaseURL(server.URL)
+	if err := wecom.Notify(context.Background(), notifier.Notification{Title: "home", Message: "DNS update failed"}); err != nil {
+		t.Fatal(err)
+	}
+	if key != "robot-key" || body.MsgType != "text" || body.Text.Content != "home\nDNS update failed" {
+		t.Fatalf("unexpected request: key=%q body=%+v", key, body)
+	}
+}
+
+func TestNotifyChecksWeComAPIResponse(t *testing.T) {
+	key := "robot+/key =secret"
+	tests := []struct {
+		name       string
+		statusCode int
+		body       string
+		want       string
+	}{
+		{"errcode", http.StatusOK, `{"errcode":93000,"errmsg":"invalid webhook url, key ` + key + `"}`, "errcode 93000"},
+		{"http error", http.StatusBadGateway, `{}`, "HTTP status 502"},
+		{"undecodable", http.StatusOK, `<html>`, "failed to decode"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tt.statusCode)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			wecom, err := New(&WeCom{Key: key})
+			if err != nil {
+				t.Fatal(err)
+			}
+			wecom.hc.SetBaseURL(server.URL)
+			err = wecom.Notify(context.Background(), notifier.Notification{Message: "test"})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+			testutil.AssertTokenRedacted(t, err.Error(), key)
+		})
+	}
+}
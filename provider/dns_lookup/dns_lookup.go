@@ -0,0 +1,337 @@
+package dns_lookup
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/we11adam/uddns/internal/udpexchange"
+	"github.com/we11adam/uddns/provider"
+)
+
+const (
+	defaultPreset  = "opendns"
+	defaultPort    = "53"
+	requestTimeout = 5 * time.Second
+	dialTimeout    = 1 * time.Second
+)
+
+// retransmitTimeouts are how long each query waits for an answer before it is
+// sent again.
+var retransmitTimeouts = []time.Duration{1 * time.Second, 2 * time.Second}
+
+// Preset is a DNS service that answers with the address the query came from.
+type Preset struct {
+	// Name is the query name.
+	Name string
+	// TXT means the address is returned in a TXT record. Otherwise the query
+	// asks for A or AAAA records.
+	TXT   bool
+	Class dnsmessage.Class
+	// ResolverIPv4 and ResolverIPv6 are the servers queried over udp4 and
+	// udp6, so each family sees its own source address.
+	ResolverIPv4 string
+	ResolverIPv6 string
+}
+
+var PRESETS = map[string]Preset{
+	"opendns": {
+		Name:         "myip.opendns.com.",
+		Class:        dnsmessage.ClassINET,
+		ResolverIPv4: "208.67.222.222:53",
+		ResolverIPv6: "[2620:119:35::35]:53",
+	},
+	"cloudflare": {
+		Name:         "whoami.cloudflare.",
+		TXT:          true,
+		Class:        dnsmessage.ClassCHAOS,
+		ResolverIPv4: "1.1.1.1:53",
+		ResolverIPv6: "[2606:4700:4700::1111]:53",
+	},
+	"google": {
+		Name:         "o-o.myaddr.l.google.com.",
+		TXT:          true,
+		Class:        dnsmessage.ClassINET,
+		ResolverIPv4: "216.239.32.10:53",
+		ResolverIPv6: "[2001:4860:4802:32::a]:53",
+	},
+}
+
+type Config struct {
+	// Preset is opendns, cloudflare, or google. Defaults to opendns.
+	Preset string `mapstructure:"preset"`
+	// ResolverIPv4 and ResolverIPv6 replace the preset's servers, as host or
+	// host:port. Port defaults to 53.
+	ResolverIPv4 string `mapstructure:"resolver_ipv4"`
+	ResolverIPv6 string `mapstructure:"resolver_ipv6"`
+}
+
+type DNSLookup struct {
+	preset  string
+	name    dnsmessage.Name
+	txt     bool
+	class   dnsmessage.Class
+	servers map[string]string
+	dialer  *net.Dialer
+}
+
+func init() {
+	provider.Register("DNSLookup", "providers.dns_lookup", func(v provider.ConfigReader) (provider.Provider, error) {
+		if !v.IsSet("providers.dns_lookup") {
+			return nil, provider.ErrNotConfigured
+		}
+
+		cfg := Config{}
+		err := v.UnmarshalKey("providers.dns_lookup", &cfg)
+		if err != nil {
+			return nil, err
+		}
+		return New(&cfg)
+	})
+}
+
+func New(cfg *Config) (*DNSLookup, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("DNS lookup config is nil")
+	}
+	presetName := strings.ToLower(strings.TrimSpace(cfg.Preset))
+	if presetName == "" {
+		presetName = defaultPreset
+	}
+	preset, ok := PRESETS[presetName]
+	if !ok {
+		return nil, fmt.Errorf("unsupported DNS lookup preset %q; supported presets: %s", cfg.Preset, strings.Join(supportedPresetNames(), ", "))
+	}
+	name, err := dnsmessage.NewName(preset.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	servers := map[string]string{"ipv4": preset.ResolverIPv4, "ipv6": preset.ResolverIPv6}
+	for family, resolver := range map[string]string{"ipv4": cfg.ResolverIPv4, "ipv6": cfg.ResolverIPv6} {
+		if strings.TrimSpace(resolver) == "" {
+			continue
+		}
+		if servers[family], err = serverAddress(resolver); err != nil {
+			return nil, err
+		}
+	}
+
+	return &DNSLookup{
+		preset:  presetName,
+		name:    name,
+		txt:     preset.TXT,
+		class:   preset.Class,
+		servers: servers,
+		dialer:  &net.Dialer{Timeout: dialTimeout},
+	}, nil
+}
+
+func serverAddress(server string) (string, error) {
+	address, err := udpexchange.HostPort(server, defaultPort)
+	if err != nil {
+		return "", fmt.Errorf("invalid DNS lookup resolver: %s", strings.TrimSpace(server))
+	}
+	return address, nil
+}
+
+func (d *DNSLookup) GetIPs(ctx context.Context, families provider.FamilyRequest) (*provider.IpResult, error) {
+	if !families.IPv4 && !families.IPv6 {
+		return nil, fmt.Errorf("no IP families requested")
+	}
+	result := &provider.IpResult{}
+	var failures []error
+
+	if families.IPv4 {
+		ipv4, err := d.getIP(ctx, "udp4", "ipv4")
+		if err == nil {
+			result.IPv4 = ipv4
+		} else if ctx.Err() != nil {
+			return nil, ctx.Err()
+		} else {
+			failures = append(failures, fmt.Errorf("IPv4 lookup failed: %w", err))
+		}
+	}
+
+	if families.IPv6 {
+		ipv6, err := d.getIP(ctx, "udp6", "ipv6")
+		if err == nil {
+			result.IPv6 = ipv6
+		} else if ctx.Err() != nil {
+			return nil, ctx.Err()
+		} else {
+			failures = append(failures, fmt.Errorf("IPv6 lookup failed: %w", err))
+		}
+	}
+
+	if result.IPv4 == "" && result.IPv6 == "" {
+		if len(failures) == 0 {
+			failures = append(failures, fmt.Errorf("failed to get requested IP addresses"))
+		}
+		return nil, errors.Join(failures...)
+	}
+
+	return result, nil
+}
+
+func (d *DNSLookup) getIP(ctx context.Context, network, family string) (string, error) {
+	server := d.servers[family]
+	question := dnsmessage.Question{Name: d.name, Type: dnsmessage.TypeA, Class: d.class}
+	switch {
+	case d.txt:
+		question.Type = dnsmessage.TypeTXT
+	case family == "ipv6":
+		question.Type = dnsmessage.TypeAAAA
+	}
+
+	slog.Debug("sending DNS lookup", "provider", "dns_lookup", "preset", d.preset, "server", server, "family", family)
+	requestCtx, cancel := context.WithTimeout(ctx, requestTimeout)
+	answers, err := d.query(requestCtx, network, server, question)
+	cancel()
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", fmt.Errorf("DNS lookup %q via %s failed: %w", d.preset, server, err)
+	}
+
+	nonPublic := false
+	for _, answer := range answers {
+		candidate := &provider.IpResult{}
+		if family == "ipv4" {
+			candidate.IPv4 = answer
+		} else {
+			candidate.IPv6 = answer
+		}
+		if candidate.Validate() != nil {
+			continue
+		}
+		ip := candidate.IPv4 + candidate.IPv6
+		if !provider.IsPublicRoutable(netip.MustParseAddr(ip)) {
+			slog.Debug("ignoring non-public DNS lookup answer", "provider", "dns_lookup", "preset", d.preset, "family", family, "ip", ip)
+			nonPublic = true
+			continue
+		}
+		slog.Debug("got IP address", "provider", "dns_lookup", "family", family, "ip", ip)
+		return ip, nil
+	}
+	if nonPublic {
+		return "", fmt.Errorf("DNS lookup %q via %s returned only non-public %s addresses", d.preset, server, family)
+	}
+	return "", fmt.Errorf("DNS lookup %q via %s returned no %s address", d.preset, server, family)
+}
+
+// query sends question to server and returns the answers that match it: the
+// addresses of A and AAAA records, or the text of TXT records.
+func (d *DNSLookup) query(ctx context.Context, network, server string, question dnsmessage.Question) ([]string, error) {
+	var idBytes [2]byte
+	if _, err := rand.Read(idBytes[:]); err != nil {
+		return nil, fmt.Errorf("failed to generate DNS message ID: %w", err)
+	}
+	id := binary.BigEndian.Uint16(idBytes[:])
+	builder := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id})
+	if err := builder.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := builder.Question(question); err != nil {
+		return nil, err
+	}
+	msg, err := builder.Finish()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build DNS query: %w", err)
+	}
+
+	response, err := d.exchange(ctx, network, server, msg, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var parser dnsmessage.Parser
+	header, err := parser.Start(response)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse DNS response: %w", err)
+	}
+	if !header.Response {
+		return nil, fmt.Errorf("unexpected DNS response")
+	}
+	if header.RCode != dnsmessage.RCodeSuccess {
+		return nil, fmt.Errorf("DNS query rejected: %s", strings.TrimPrefix(header.RCode.String(), "RCode"))
+	}
+	if err := parser.SkipAllQuestions(); err != nil {
+		return nil, fmt.Errorf("failed to parse DNS response: %w", err)
+	}
+
+	var answers []string
+	for {
+		answer, err := parser.AnswerHeader()
+		if errors.Is(err, dnsmessage.ErrSectionDone) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse DNS response: %w", err)
+		}
+		if answer.Type != question.Type || !strings.EqualFold(answer.Name.String(), question.Name.String()) {
+			if err := parser.SkipAnswer(); err != nil {
+				return nil, fmt.Errorf("failed to parse DNS response: %w", err)
+			}
+			continue
+		}
+		switch answer.Type {
+		case dnsmessage.TypeA:
+			resource, err := parser.AResource()
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse DNS response: %w", err)
+			}
+			answers = append(answers, netip.AddrFrom4(resource.A).String())
+		case dnsmessage.TypeAAAA:
+			resource, err := parser.AAAAResource()
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse DNS response: %w", err)
+			}
+			answers = append(answers, netip.AddrFrom16(resource.AAAA).String())
+		case dnsmessage.TypeTXT:
+			resource, err := parser.TXTResource()
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse DNS response: %w", err)
+			}
+			answers = append(answers, strings.Join(resource.TXT, ""))
+		}
+	}
+	return answers, nil
+}
+
+// exchange sends msg over a connected UDP socket, retransmitting while no
+// answer arrives, and returns the response carrying the message ID.
+func (d *DNSLookup) exchange(ctx context.Context, network, server string, msg []byte, id uint16) ([]byte, error) {
+	conn, err := d.dialer.DialContext(ctx, network, server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return udpexchange.Exchange(ctx, conn, msg, retransmitTimeouts, func(response []byte) error {
+		// Ignore stray datagrams that do not answer this query.
+		if len(response) < 12 || binary.BigEndian.Uint16(response) != id {
+			return udpexchange.ErrForeignMessage
+		}
+		return nil
+	})
+}
+
+func supportedPresetNames() []string {
+	names := make([]string, 0, len(PRESETS))
+	for name := range PRESETS {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
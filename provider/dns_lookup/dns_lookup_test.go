@@ -0,0 +1,245 @@
+package dns_lookup
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/we11adam/uddns/internal/udpexchange"
+	"github.com/we11adam/uddns/provider"
+)
+
+// resolver is a local DNS server that answers every query with fixed records.
+type resolver struct {
+	conn net.PacketConn
+	// answer builds the answer section for a question.
+	answer func(*dnsmessage.Builder, dnsmessage.Question) error
+	rcode  dnsmessage.RCode
+
+	mu        sync.Mutex
+	questions []dnsmessage.Question
+}
+
+func newResolver(t *testing.T, network, address string) *resolver {
+	t.Helper()
+	conn, err := net.ListenPacket(network, address)
+	if err != nil {
+		t.Skipf("cannot listen on %s %s: %v", network, address, err)
+	}
+	r := &resolver{conn: conn}
+	t.Cleanup(func() { _ = conn.Close() })
+	return r
+}
+
+func (r *resolver) start() string {
+	go r.serve()
+	return r.conn.LocalAddr().String()
+}
+
+func (r *resolver) serve() {
+	buf := make([]byte, udpexchange.MaxDatagramSize)
+	for {
+		n, client, err := r.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		var parser dnsmessage.Parser
+		header, err := parser.Start(buf[:n])
+		if err != nil {
+			continue
+		}
+		question, err := parser.Question()
+		if err != nil {
+			continue
+		}
+		r.mu.Lock()
+		r.questions = append(r.questions, question)
+		r.mu.Unlock()
+
+		// A stray response with another ID must be ignored.
+		stray := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: header.ID + 1, Response: true})
+		if msg, err := stray.Finish(); err == nil {
+			_, _ = r.conn.WriteTo(msg, client)
+		}
+
+		builder := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: header.ID, Response: true, RCode: r.rcode})
+		_ = builder.StartQuestions()
+		_ = builder.Question(question)
+		_ = builder.StartAnswers()
+		if r.answer != nil {
+			_ = r.answer(&builder, question)
+		}
+		msg, err := builder.Finish()
+		if err != nil {
+			continue
+		}
+		_, _ = r.conn.WriteTo(msg, client)
+	}
+}
+
+func (r *resolver) lastQuestion() dnsmessage.Question {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.questions[len(r.questions)-1]
+}
+
+func answerA(ip string) func(*dnsmessage.Builder, dnsmessage.Question) error {
+	return func(builder *dnsmessage.Builder, question dnsmessage.Question) error {
+		header := dnsmessage.ResourceHeader{Name: question.Name, Class: question.Class, TTL: 0}
+		addr := netip.MustParseAddr(ip)
+		if addr.Is4() {
+			return builder.AResource(header, dnsmessage.AResource{A: addr.As4()})
+		}
+		return builder.AAAAResource(header, dnsmessage.AAAAResource{AAAA: addr.As16()})
+	}
+}
+
+func answerTXT(values ...string) func(*dnsmessage.Builder, dnsmessage.Question) error {
+	return func(builder *dnsmessage.Builder, question dnsmessage.Question) error {
+		header := dnsmessage.ResourceHeader{Name: question.Name, Class: question.Class}
+		for _, value := range values {
+			if err := builder.TXTResource(header, dnsmessage.TXTResource{TXT: []string{value}}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func TestGetIPsQueriesOpenDNS(t *testing.T) {
+	server := newResolver(t, "udp4", "127.0.0.1:0")
+	server.answer = answerA("8.8.8.8")
+	lookup, err := New(&Config{ResolverIPv4: server.start()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := lookup.GetIPs(context.Background(), provider.FamilyRequest{IPv4: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IPv4 != "8.8.8.8" || result.IPv6 != "" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	question := server.lastQuestion()
+	if question.Name.String() != "myip.opendns.com." || question.Type != dnsmessage.TypeA || question.Class != dnsmessage.ClassINET {
+		t.Fatalf("unexpected question: %v", question)
+	}
+}
+
+func TestGetIPsQueriesCloudflareChaosTXT(t *testing.T) {
+	server := newResolver(t, "udp4", "127.0.0.1:0")
+	server.answer = answerTXT("8.8.4.4")
+	lookup, err := New(&Config{Preset: "cloudflare", ResolverIPv4: server.start()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := lookup.GetIPs(context.Background(), provider.FamilyRequest{IPv4: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IPv4 != "8.8.4.4" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	question := server.lastQuestion()
+	if question.Name.String() != "whoami.cloudflare." || question.Type != dnsmessage.TypeTXT || question.Class != dnsmessage.ClassCHAOS {
+		t.Fatalf("unexpected question: %v", question)
+	}
+}
+
+func TestGetIPsQueriesGoogleOverIPv6(t *testing.T) {
+	server := newResolver(t, "udp6", "[::1]:0")
+	server.answer = answerTXT("edns0-client-subnet 2606:4700::/48", "2606:4700:4700::1111")
+	lookup, err := New(&Config{Preset: "Google", ResolverIPv6: server.start()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := lookup.GetIPs(context.Background(), provider.FamilyRequest{IPv6: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IPv6 != "2606:4700:4700::1111" || result.IPv4 != "" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if question := server.lastQuestion(); question.Name.String() != "o-o.myaddr.l.google.com." || question.Type != dnsmessage.TypeTXT {
+		t.Fatalf("unexpected question: %v", question)
+	}
+}
+
+func TestGetIPsSkipsNonPublicAnswers(t *testing.T) {
+	server := newResolver(t, "udp4", "127.0.0.1:0")
+	server.answer = answerTXT("10.0.0.1", "8.8.4.4")
+	lookup, err := New(&Config{Preset: "google", ResolverIPv4: server.start()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := lookup.GetIPs(context.Background(), provider.FamilyRequest{IPv4: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IPv4 != "8.8.4.4" {
+		t.Fatalf("expected the public answer after a private one, got %+v", result)
+	}
+}
+
+func TestGetIPsRejectsInvalidAnswers(t *testing.T) {
+	tests := []struct {
+		name   string
+		preset string
+		answer func(*dnsmessage.Builder, dnsmessage.Question) error
+		rcode  dnsmessage.RCode
+		want   string
+	}{
+		{name: "private address", answer: answerA("192.168.1.10"), want: "only non-public ipv4 addresses"},
+		{name: "shared address space", preset: "cloudflare", answer: answerTXT("100.64.0.1"), want: "only non-public ipv4 addresses"},
+		{name: "wrong family", preset: "cloudflare", answer: answerTXT("2606:4700:4700::1111"), want: "no ipv4 address"},
+		{name: "garbage", preset: "google", answer: answerTXT("not an address"), want: "no ipv4 address"},
+		{name: "refused", rcode: dnsmessage.RCodeRefused, want: "rejected: Refused"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newResolver(t, "udp4", "127.0.0.1:0")
+			server.answer = tt.answer
+			server.rcode = tt.rcode
+			lookup, err := New(&Config{Preset: tt.preset, ResolverIPv4: server.start()})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = lookup.GetIPs(context.Background(), provider.FamilyRequest{IPv4: true})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestNewValidatesConfig(t *testing.T) {
+	lookup, err := New(&Config{ResolverIPv4: "192.0.2.53", ResolverIPv6: "2001:db8::53"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lookup.servers["ipv4"] != "192.0.2.53:53" || lookup.servers["ipv6"] != "[2001:db8::53]:53" {
+		t.Fatalf("unexpected servers: %v", lookup.servers)
+	}
+	if lookup, err = New(&Config{Preset: "google"}); err != nil || lookup.servers["ipv4"] != PRESETS["google"].ResolverIPv4 {
+		t.Fatalf("expected preset servers, got %v, %v", lookup, err)
+	}
+
+	for _, cfg := range []*Config{
+		nil,
+		{Preset: "quad9"},
+		{ResolverIPv4: "resolver.example/53"},
+	} {
+		if _, err := New(cfg); err == nil {
+			t.Fatalf("expected %+v to be rejected", cfg)
+		}
+	}
+}
@@ -2,17 +2,23 @@ package ip_service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
 	"net"
 	"net/http"
 	"net/netip"
+	"net/url"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-resty/resty/v2"
+	"github.com/go-viper/mapstructure/v2"
 	"github.com/we11adam/uddns/internal/redact"
 	"github.com/we11adam/uddns/internal/restyretry"
 	"github.com/we11adam/uddns/provider"
@@ -29,40 +35,65 @@ const (
 	maxServiceRedirects = 3
 	responseBodyLimit   = 4 << 10
 	requestTimeout      = 5 * time.Second
+	defaultQuorum       = 2
 )
 
-var (
-	publicIPv6Prefix  = netip.MustParsePrefix("2000::/3")
-	nonPublicPrefixes = []netip.Prefix{
-		// IPv4 special-purpose and reserved ranges not suitable for public DNS.
-		netip.MustParsePrefix("0.0.0.0/8"),
-		netip.MustParsePrefix("100.64.0.0/10"),
-		netip.MustParsePrefix("192.0.0.0/24"),
-		netip.MustParsePrefix("192.0.2.0/24"),
-		netip.MustParsePrefix("192.31.196.0/24"),
-		netip.MustParsePrefix("192.52.193.0/24"),
-		netip.MustParsePrefix("192.88.99.0/24"),
-		netip.MustParsePrefix("192.175.48.0/24"),
-		netip.MustParsePrefix("198.18.0.0/15"),
-		netip.MustParsePrefix("198.51.100.0/24"),
-		netip.MustParsePrefix("203.0.113.0/24"),
-		netip.MustParsePrefix("240.0.0.0/4"),
-
-		// IPv6 protocol assignments, documentation, transition, and reserved ranges.
-		netip.MustParsePrefix("2001::/23"),
-		netip.MustParsePrefix("2001:db8::/32"),
-		netip.MustParsePrefix("2002::/16"),
-		netip.MustParsePrefix("2620:4f:8000::/48"),
-		netip.MustParsePrefix("3fff::/20"),
-	}
+const (
+	// StrategyFirst asks services in order and takes the first valid answer.
+	StrategyFirst = "first"
+	// StrategyRace asks all services at once and takes the fastest valid
+	// answer.
+	StrategyRace = "race"
+	// StrategyQuorum asks all services at once and takes the address that
+	// Quorum of them agree on.
+	StrategyQuorum = "quorum"
 )
 
 type ServiceNames []string
 
+// Service is an IP echo endpoint. A built-in service is referred to by name
+// alone; any other service needs a URL.
+type Service struct {
+	// Name labels the service in logs and errors. Defaults to the URL host.
+	Name string `mapstructure:"name"`
+	URL  string `mapstructure:"url"`
+	// Family limits the service to ipv4 or ipv6. Empty means both.
+	Family string `mapstructure:"family"`
+	// JSONPath extracts the address from a JSON response, for example .ip or
+	// .data.addresses[0].
+	JSONPath string `mapstructure:"json_path"`
+	// Regex extracts the address from a text or HTML response. The first
+	// capture group is used if there is one, otherwise the whole match.
+	Regex        string            `mapstructure:"regex"`
+	Headers      map[string]string `mapstructure:"headers"`
+	AllowPrivate bool              `mapstructure:"allow_private"`
+}
+
+type Config struct {
+	Services []Service `mapstructure:"-"`
+	// Strategy is first, race, or quorum. Defaults to first.
+	Strategy string `mapstructure:"strategy"`
+	// Quorum is how many services must return the same address with the
+	// quorum strategy. Defaults to 2.
+	Quorum int `mapstructure:"quorum"`
+}
+
+type service struct {
+	name         string
+	url          string
+	family       string
+	jsonPath     []string
+	regex        *regexp.Regexp
+	headers      map[string]string
+	allowPrivate bool
+}
+
 type IpService struct {
-	client4 *resty.Client
-	client6 *resty.Client
-	names   *ServiceNames
+	client4  *resty.Client
+	client6  *resty.Client
+	services []service
+	strategy string
+	quorum   int
 }
 
 func init() {
@@ -71,37 +102,204 @@ func init() {
 			return nil, provider.ErrNotConfigured
 		}
 
-		cfg := ServiceNames{}
-		err := v.UnmarshalKey("providers.ip_service", &cfg)
+		var raw any
+		err := v.UnmarshalKey("providers.ip_service", &raw)
+		if err != nil {
+			return nil, err
+		}
+		cfg, err := decodeConfig(raw)
 		if err != nil {
 			return nil, err
 		}
-		if len(cfg) == 0 {
-			return nil, fmt.Errorf("no IP service names provided")
+		return NewWithConfig(cfg)
+	})
+}
+
+// decodeConfig reads the provider's configuration, which is either a list of
+// services or a map with the list under services and the strategy settings.
+func decodeConfig(raw any) (*Config, error) {
+	cfg := &Config{}
+	list, ok := raw.([]any)
+	if settings, isMap := raw.(map[string]any); isMap {
+		strategy := maps.Clone(settings)
+		delete(strategy, "services")
+		if err := decodeStrict(strategy, cfg); err != nil {
+			return nil, fmt.Errorf("invalid ip_service config: %w", err)
 		}
-		for _, name := range cfg {
-			if _, ok := SERVICES[name]; !ok {
-				return nil, fmt.Errorf("unsupported IP service %q; supported services: %s", name, strings.Join(supportedServiceNames(), ", "))
+		list, ok = settings["services"].([]any)
+	}
+	if !ok {
+		return nil, fmt.Errorf("ip_service must be a list of services or a map with a services list")
+	}
+	services, err := decodeServices(list)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Services = services
+	return cfg, nil
+}
+
+// decodeServices reads the provider's list, whose entries are built-in
+// service names or service maps.
+func decodeServices(raw []any) ([]Service, error) {
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("no IP service names provided")
+	}
+	services := make([]Service, 0, len(raw))
+	for i, entry := range raw {
+		switch entry := entry.(type) {
+		case string:
+			services = append(services, Service{Name: entry})
+		case map[string]any:
+			var svc Service
+			if err := decodeStrict(entry, &svc); err != nil {
+				return nil, fmt.Errorf("invalid IP service %d: %w", i+1, err)
 			}
+			services = append(services, svc)
+		default:
+			return nil, fmt.Errorf("invalid IP service %d: must be a service name or a map", i+1)
 		}
-		return New(&cfg)
-	})
+	}
+	return services, nil
+}
+
+// decodeStrict decodes input into output and rejects unknown keys, so that a
+// misspelled setting is reported instead of silently ignored.
+func decodeStrict(input, output any) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{ErrorUnused: true, Result: output})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(input)
 }
 
 func New(names *ServiceNames) (*IpService, error) {
 	if names == nil {
 		return nil, fmt.Errorf("IP service names are nil")
 	}
+	services := make([]Service, 0, len(*names))
+	for _, name := range *names {
+		services = append(services, Service{Name: name})
+	}
+	return NewServices(services)
+}
+
+func NewServices(services []Service) (*IpService, error) {
+	return NewWithConfig(&Config{Services: services})
+}
+
+func NewWithConfig(cfg *Config) (*IpService, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("IP service config is nil")
+	}
+	services := make([]service, 0, len(cfg.Services))
+	for _, configured := range cfg.Services {
+		svc, err := newService(configured)
+		if err != nil {
+			return nil, err
+		}
+		services = append(services, svc)
+	}
+
+	strategy := strings.ToLower(strings.TrimSpace(cfg.Strategy))
+	quorum := cfg.Quorum
+	switch strategy {
+	case "":
+		strategy = StrategyFirst
+		fallthrough
+	case StrategyFirst, StrategyRace:
+		if quorum != 0 {
+			return nil, fmt.Errorf("ip_service quorum requires the quorum strategy")
+		}
+	case StrategyQuorum:
+		if quorum == 0 {
+			quorum = defaultQuorum
+		}
+		if quorum < 1 || quorum > len(services) {
+			return nil, fmt.Errorf("invalid ip_service quorum %d: must be between 1 and the number of services (%d)", quorum, len(services))
+		}
+	default:
+		return nil, fmt.Errorf("unsupported ip_service strategy %q; supported strategies: first, race, quorum", cfg.Strategy)
+	}
+
 	client4 := createClient("tcp4")
 	client6 := createClient("tcp6")
 
 	return &IpService{
-		client4: client4,
-		client6: client6,
-		names:   names,
+		client4:  client4,
+		client6:  client6,
+		services: services,
+		strategy: strategy,
+		quorum:   quorum,
 	}, nil
 }
 
+func newService(cfg Service) (service, error) {
+	name := strings.TrimSpace(cfg.Name)
+	rawURL := strings.TrimSpace(cfg.URL)
+	if rawURL == "" {
+		serviceURL, ok := SERVICES[name]
+		if !ok {
+			return service{}, fmt.Errorf("unsupported IP service %q; supported services: %s", name, strings.Join(supportedServiceNames(), ", "))
+		}
+		rawURL = serviceURL
+	} else {
+		parsed, err := url.Parse(rawURL)
+		if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+			return service{}, fmt.Errorf("invalid IP service URL for %q: must be an http or https URL", name)
+		}
+		if name == "" {
+			name = parsed.Host
+		}
+	}
+
+	svc := service{
+		name:         name,
+		url:          rawURL,
+		family:       strings.ToLower(strings.TrimSpace(cfg.Family)),
+		headers:      cfg.Headers,
+		allowPrivate: cfg.AllowPrivate,
+	}
+	if svc.family != "" && svc.family != "ipv4" && svc.family != "ipv6" {
+		return service{}, fmt.Errorf("invalid family %q for IP service %q: must be ipv4 or ipv6", cfg.Family, name)
+	}
+	if cfg.JSONPath != "" && cfg.Regex != "" {
+		return service{}, fmt.Errorf("IP service %q cannot set both json_path and regex", name)
+	}
+	if cfg.JSONPath != "" {
+		path, err := parseJSONPath(cfg.JSONPath)
+		if err != nil {
+			return service{}, fmt.Errorf("invalid json_path for IP service %q: %w", name, err)
+		}
+		svc.jsonPath = path
+	}
+	if cfg.Regex != "" {
+		regex, err := regexp.Compile(cfg.Regex)
+		if err != nil {
+			return service{}, fmt.Errorf("invalid regex for IP service %q: %w", name, err)
+		}
+		svc.regex = regex
+	}
+	return svc, nil
+}
+
+// parseJSONPath splits a path such as .data.addresses[0] into its keys and
+// array indexes.
+func parseJSONPath(path string) ([]string, error) {
+	trimmed := strings.TrimPrefix(strings.TrimSpace(path), ".")
+	trimmed = strings.NewReplacer("[", ".", "]", "").Replace(trimmed)
+	if trimmed == "" {
+		return nil, fmt.Errorf("empty path")
+	}
+	segments := strings.Split(trimmed, ".")
+	for _, segment := range segments {
+		if segment == "" {
+			return nil, fmt.Errorf("malformed path %q", path)
+		}
+	}
+	return segments, nil
+}
+
 func createClient(network string) *resty.Client {
 	httpClient := resty.New()
 	transport := &http.Transport{
@@ -181,52 +379,239 @@ func (i *IpService) GetIPs(ctx context.Context, families provider.FamilyRequest)
 }
 
 func (i *IpService) getIP(ctx context.Context, client *resty.Client, family string) (string, error) {
+	var services []service
+	for _, svc := range i.services {
+		if svc.family == "" || svc.family == family {
+			services = append(services, svc)
+		}
+	}
+	if len(services) == 0 {
+		return "", fmt.Errorf("no IP services configured for %s", family)
+	}
+
+	switch i.strategy {
+	case StrategyRace:
+		return i.getIPRace(ctx, client, family, services)
+	case StrategyQuorum:
+		return i.getIPQuorum(ctx, client, family, services)
+	}
+
 	var failures []error
-	for _, name := range *i.names {
+	for _, svc := range services {
 		if err := ctx.Err(); err != nil {
 			return "", err
 		}
-		serviceURL, ok := SERVICES[name]
-		if !ok {
-			failures = append(failures, fmt.Errorf("IP service %q (%s) is not registered", name, family))
-			continue
-		}
-
-		slog.Debug("requesting IP address", "provider", "ip_service", "service", name, "family", family)
-		requestCtx, cancel := context.WithTimeout(ctx, requestTimeout)
-		resp, err := client.R().SetContext(requestCtx).Get(serviceURL)
-		cancel()
+		ip, err := queryService(ctx, client, svc, family)
 		if err != nil {
 			if ctx.Err() != nil {
 				return "", ctx.Err()
 			}
-			safeErr := redact.Error(err, serviceURL)
-			slog.Debug("failed to request IP address", "provider", "ip_service", "service", name, "family", family, "error", safeErr)
-			failures = append(failures, fmt.Errorf("IP service %q (%s) request failed: %w", name, family, safeErr))
+			failures = append(failures, err)
 			continue
 		}
-		if resp.StatusCode() != 200 {
-			slog.Debug("unexpected IP address response status", "provider", "ip_service", "service", name, "family", family, "status", resp.StatusCode())
-			failures = append(failures, fmt.Errorf("IP service %q (%s) returned unexpected HTTP status %d", name, family, resp.StatusCode()))
+		slog.Debug("got IP address", "provider", "ip_service", "family", family, "ip", ip)
+		return ip, nil
+	}
+	return "", errors.Join(failures...)
+}
+
+type answer struct {
+	index int
+	ip    string
+	err   error
+}
+
+// queryAll asks every service at once and delivers their answers as they
+// arrive. Canceling ctx stops the requests still in flight.
+func queryAll(ctx context.Context, client *resty.Client, family string, services []service) <-chan answer {
+	answers := make(chan answer, len(services))
+	for index, svc := range services {
+		go func() {
+			ip, err := queryService(ctx, client, svc, family)
+			answers <- answer{index: index, ip: ip, err: err}
+		}()
+	}
+	return answers
+}
+
+// getIPRace returns the first valid answer from services queried in
+// parallel.
+func (i *IpService) getIPRace(ctx context.Context, client *resty.Client, family string, services []service) (string, error) {
+	raceCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	answers := queryAll(raceCtx, client, family, services)
+
+	failures := make([]error, len(services))
+	for range services {
+		result := <-answers
+		if result.err == nil {
+			slog.Debug("got IP address", "provider", "ip_service", "service", services[result.index].name, "family", family, "ip", result.ip)
+			return result.ip, nil
+		}
+		failures[result.index] = result.err
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return "", errors.Join(failures...)
+}
+
+// getIPQuorum returns the address that at least quorum services agree on.
+// Services that answer with a different address are reported, so that one
+// wrong or hijacked service cannot silently move a record.
+func (i *IpService) getIPQuorum(ctx context.Context, client *resty.Client, family string, services []service) (string, error) {
+	if len(services) < i.quorum {
+		return "", fmt.Errorf("IP service quorum of %d cannot be reached: %d of the configured services support %s", i.quorum, len(services), family)
+	}
+	quorumCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	answers := queryAll(quorumCtx, client, family, services)
+
+	failures := make([]error, len(services))
+	votes := make(map[string][]string)
+	var order []string
+	for range services {
+		result := <-answers
+		if result.err != nil {
+			failures[result.index] = result.err
 			continue
 		}
-		ip := string(resp.Body())
-		ip = strings.TrimSpace(ip)
-		if !isValidIPFamily(ip, family) {
-			slog.Debug("ignoring invalid IP address response", "provider", "ip_service", "service", name, "family", family)
-			failures = append(failures, fmt.Errorf("IP service %q (%s) returned an invalid address", name, family))
+		if _, seen := votes[result.ip]; !seen {
+			order = append(order, result.ip)
+		}
+		votes[result.ip] = append(votes[result.ip], services[result.index].name)
+		if len(votes[result.ip]) < i.quorum {
 			continue
 		}
-		slog.Debug("got IP address", "provider", "ip_service", "family", family, "ip", ip)
-		return ip, nil
+		if len(votes) > 1 {
+			slog.Warn("IP services disagree", "provider", "ip_service", "family", family, "ip", result.ip, "answers", describeVotes(votes, order))
+		}
+		slog.Debug("got IP address", "provider", "ip_service", "family", family, "ip", result.ip, "services", votes[result.ip])
+		return result.ip, nil
 	}
-	if len(failures) == 0 {
-		failures = append(failures, fmt.Errorf("no registered IP services configured for %s", family))
+	if err := ctx.Err(); err != nil {
+		return "", err
 	}
-	return "", errors.Join(failures...)
+
+	var errs []error
+	if len(votes) > 0 {
+		if len(votes) > 1 {
+			slog.Warn("IP services disagree", "provider", "ip_service", "family", family, "answers", describeVotes(votes, order))
+		}
+		errs = append(errs, fmt.Errorf("IP service quorum of %d not reached for %s: %s", i.quorum, family, describeVotes(votes, order)))
+	} else {
+		errs = append(errs, fmt.Errorf("IP service quorum of %d not reached for %s", i.quorum, family))
+	}
+	for _, err := range failures {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return "", errors.Join(errs...)
+}
+
+// describeVotes lists each address with the services that returned it, in
+// the order the addresses were first seen.
+func describeVotes(votes map[string][]string, order []string) string {
+	parts := make([]string, 0, len(order))
+	for _, ip := range order {
+		names := make([]string, 0, len(votes[ip]))
+		for _, name := range votes[ip] {
+			names = append(names, strconv.Quote(name))
+		}
+		parts = append(parts, fmt.Sprintf("%s from %s", ip, strings.Join(names, ", ")))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// queryService asks one service for its view of the address of family.
+func queryService(ctx context.Context, client *resty.Client, svc service, family string) (string, error) {
+	name := svc.name
+	slog.Debug("requesting IP address", "provider", "ip_service", "service", name, "family", family)
+	requestCtx, cancel := context.WithTimeout(ctx, requestTimeout)
+	resp, err := client.R().SetContext(requestCtx).SetHeaders(svc.headers).Get(svc.url)
+	cancel()
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		safeErr := redact.Error(err, svc.url)
+		slog.Debug("failed to request IP address", "provider", "ip_service", "service", name, "family", family, "error", safeErr)
+		return "", fmt.Errorf("IP service %q (%s) request failed: %w", name, family, safeErr)
+	}
+	if resp.StatusCode() != 200 {
+		slog.Debug("unexpected IP address response status", "provider", "ip_service", "service", name, "family", family, "status", resp.StatusCode())
+		return "", fmt.Errorf("IP service %q (%s) returned unexpected HTTP status %d", name, family, resp.StatusCode())
+	}
+	ip, err := svc.extract(resp.Body())
+	if err != nil {
+		slog.Debug("failed to extract IP address", "provider", "ip_service", "service", name, "family", family, "error", err)
+		return "", fmt.Errorf("IP service %q (%s) %w", name, family, err)
+	}
+	if !isAllowedIP(ip, family, svc.allowPrivate) {
+		slog.Debug("ignoring invalid IP address response", "provider", "ip_service", "service", name, "family", family)
+		return "", fmt.Errorf("IP service %q (%s) returned an invalid address", name, family)
+	}
+	return normalizeIP(ip), nil
+}
+
+// extract returns the address in a response body. Errors never include the
+// body, which may echo credentials sent in headers.
+func (s *service) extract(body []byte) (string, error) {
+	switch {
+	case s.jsonPath != nil:
+		var value any
+		if err := json.Unmarshal(body, &value); err != nil {
+			return "", fmt.Errorf("returned invalid JSON")
+		}
+		for _, segment := range s.jsonPath {
+			switch node := value.(type) {
+			case map[string]any:
+				next, ok := node[segment]
+				if !ok {
+					return "", fmt.Errorf("response has no value at the JSON path")
+				}
+				value = next
+			case []any:
+				index, err := strconv.Atoi(segment)
+				if err != nil || index < 0 || index >= len(node) {
+					return "", fmt.Errorf("response has no value at the JSON path")
+				}
+				value = node[index]
+			default:
+				return "", fmt.Errorf("response has no value at the JSON path")
+			}
+		}
+		ip, ok := value.(string)
+		if !ok {
+			return "", fmt.Errorf("response has no string at the JSON path")
+		}
+		return strings.TrimSpace(ip), nil
+	case s.regex != nil:
+		match := s.regex.FindSubmatch(body)
+		if match == nil {
+			return "", fmt.Errorf("response does not match the regex")
+		}
+		if len(match) > 1 {
+			return strings.TrimSpace(string(match[1])), nil
+		}
+		return strings.TrimSpace(string(match[0])), nil
+	default:
+		return strings.TrimSpace(string(body)), nil
+	}
+}
+
+// normalizeIP returns the canonical form of a valid address, so that
+// differently written answers are counted as the same vote.
+func normalizeIP(ip string) string {
+	return netip.MustParseAddr(strings.TrimSpace(ip)).String()
 }
 
-func isValidIPFamily(ip, family string) bool {
+// isAllowedIP reports whether ip is an address of family. Only public,
+// globally routable addresses are accepted unless allowPrivate is set, which
+// also admits private and shared address space but never loopback,
+// link-local, multicast, or unspecified addresses.
+func isAllowedIP(ip, family string, allowPrivate bool) bool {
 	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
 	if err != nil || addr.Zone() != "" {
 		return false
@@ -244,24 +629,10 @@ func isValidIPFamily(ip, family string) bool {
 	default:
 		return false
 	}
-	return isPublicRoutable(addr)
-}
-
-func isPublicRoutable(addr netip.Addr) bool {
-	if !addr.IsValid() || addr.Is4In6() || addr.Zone() != "" ||
-		!addr.IsGlobalUnicast() || addr.IsPrivate() || addr.IsUnspecified() ||
-		addr.IsLoopback() || addr.IsLinkLocalUnicast() || addr.IsMulticast() {
-		return false
-	}
-	if addr.Is6() && !publicIPv6Prefix.Contains(addr) {
-		return false
-	}
-	for _, prefix := range nonPublicPrefixes {
-		if prefix.Contains(addr) {
-			return false
-		}
+	if allowPrivate {
+		return addr.IsGlobalUnicast()
 	}
-	return true
+	return provider.IsPublicRoutable(addr)
 }
 
 func supportedServiceNames() []string {
@@ -13,6 +13,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/spf13/viper"
 	"github.com/we11adam/uddns/internal/restyretry"
 	"github.com/we11adam/uddns/provider"
 )
@@ -199,8 +201,7 @@ func TestGetIPsJoinsRedactedErrorsWhenAllServicesFail(t *testing.T) {
 			return nil, fmt.Errorf("unexpected request host %q", request.URL.Host)
 		}
 	}))
-	names := ServiceNames{firstName, secondName}
-	service := &IpService{client4: client, client6: createClient("tcp6"), names: &names}
+	service := &IpService{client4: client, client6: createClient("tcp6"), services: testServices(t, firstName, secondName)}
 
 	_, err := service.GetIPs(context.Background(), provider.FamilyRequest{IPv4: true})
 	if err == nil {
@@ -247,8 +248,7 @@ func TestGetIPsReturnsFirstSuccessAfterEarlierFailure(t *testing.T) {
 			Request:    request,
 		}, nil
 	}))
-	names := ServiceNames{firstName, secondName}
-	service := &IpService{client4: client, client6: createClient("tcp6"), names: &names}
+	service := &IpService{client4: client, client6: createClient("tcp6"), services: testServices(t, firstName, secondName)}
 
 	result, err := service.GetIPs(context.Background(), provider.FamilyRequest{IPv4: true})
 	if err != nil {
@@ -326,6 +326,19 @@ func TestGetIPsStopsRetriesWhenContextIsCanceled(t *testing.T) {
 	}
 }
 
+func testServices(t *testing.T, names ...string) []service {
+	t.Helper()
+	services := make([]service, 0, len(names))
+	for _, name := range names {
+		svc, err := newService(Service{Name: name})
+		if err != nil {
+			t.Fatalf("create service %q: %v", name, err)
+		}
+		services = append(services, svc)
+	}
+	return services
+}
+
 func testIPService(t *testing.T, serviceURL string) *IpService {
 	t.Helper()
 	const name = "test.local"
@@ -354,8 +367,7 @@ func TestGetIPsCancelsInFlightRequest(t *testing.T) {
 		<-request.Context().Done()
 		return nil, request.Context().Err()
 	}))
-	names := ServiceNames{"ip.fm"}
-	service := &IpService{client4: client, client6: createClient("tcp6"), names: &names}
+	service := &IpService{client4: client, client6: createClient("tcp6"), services: testServices(t, "ip.fm")}
 	ctx, cancel := context.WithCancel(context.Background())
 	done := make(chan error, 1)
 	go func() {
@@ -409,8 +421,7 @@ func TestGetIPsRequestsOnlySelectedFamilies(t *testing.T) {
 			client4.SetTransport(ipResponseTransport(&calls[0], "8.8.8.8"))
 			client6 := createClient("tcp6")
 			client6.SetTransport(ipResponseTransport(&calls[1], "2606:4700:4700::1111"))
-			names := ServiceNames{"ip.fm"}
-			service := &IpService{client4: client4, client6: client6, names: &names}
+			service := &IpService{client4: client4, client6: client6, services: testServices(t, "ip.fm")}
 
 			result, err := service.GetIPs(context.Background(), tt.families)
 			if err != nil {
@@ -439,7 +450,7 @@ func ipResponseTransport(calls *int, body string) http.RoundTripper {
 }
 
 type testConfig struct {
-	services ServiceNames
+	services any
 }
 
 func (c testConfig) GetString(string) string {
@@ -451,13 +462,11 @@ func (c testConfig) IsSet(key string) bool {
 }
 
 func (c testConfig) UnmarshalKey(_ string, rawVal any) error {
-	target := rawVal.(*ServiceNames)
-	*target = c.services
-	return nil
+	return mapstructure.Decode(c.services, rawVal)
 }
 
 func TestGetProviderRejectsUnsupportedService(t *testing.T) {
-	_, _, err := provider.GetProvider(testConfig{services: ServiceNames{"missing"}})
+	_, _, err := provider.GetProvider(testConfig{services: []any{"missing"}})
 	if err == nil {
 		t.Fatal("expected unsupported service error")
 	}
@@ -466,7 +475,7 @@ func TestGetProviderRejectsUnsupportedService(t *testing.T) {
 	}
 }
 
-func TestIsValidIPFamily(t *testing.T) {
+func TestIsAllowedIPRejectsNonPublicAddresses(t *testing.T) {
 	tests := []struct {
 		name   string
 		ip     string
@@ -503,9 +512,395 @@ func TestIsValidIPFamily(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := isValidIPFamily(tt.ip, tt.family); got != tt.want {
-				t.Fatalf("isValidIPFamily(%q, %q) = %v, want %v", tt.ip, tt.family, got, tt.want)
+			if got := isAllowedIP(tt.ip, tt.family, false); got != tt.want {
+				t.Fatalf("isAllowedIP(%q, %q, false) = %v, want %v", tt.ip, tt.family, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDocumentedCustomServicesAreAccepted(t *testing.T) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	err := v.ReadConfig(strings.NewReader(`
+providers:
+  ip_service:
+    - ifconfig.me
+    - url: https://ip.internal/v4
+      family: ipv4
+      json_path: .ip
+      headers:
+        Authorization: Bearer secret
+      allow_private: true
+    - name: echo
+      url: https://echo.internal/
+      regex: 'Address: ([0-9a-f:.]+)'
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var raw []any
+	if err := v.UnmarshalKey("providers.ip_service", &raw); err != nil {
+		t.Fatalf("UnmarshalKey returned an error: %v", err)
+	}
+	configured, err := decodeServices(raw)
+	if err != nil {
+		t.Fatalf("decode services: %v", err)
+	}
+	ipService, err := NewServices(configured)
+	if err != nil {
+		t.Fatalf("NewServices returned an error: %v", err)
+	}
+	services := ipService.services
+	if len(services) != 3 {
+		t.Fatalf("services = %+v, want 3", services)
+	}
+	if services[0].name != "ifconfig.me" || services[0].url != SERVICES["ifconfig.me"] {
+		t.Fatalf("unexpected built-in service: %+v", services[0])
+	}
+	custom := services[1]
+	if custom.name != "ip.internal" || custom.family != "ipv4" || strings.Join(custom.jsonPath, ".") != "ip" ||
+		!custom.allowPrivate || custom.headers["authorization"] != "Bearer secret" {
+		t.Fatalf("unexpected custom service: %+v", custom)
+	}
+	if services[2].name != "echo" || services[2].regex == nil {
+		t.Fatalf("unexpected regex service: %+v", services[2])
+	}
+}
+
+func TestNewServicesValidatesConfig(t *testing.T) {
+	for _, cfg := range []Service{
+		{Name: "missing"},
+		{URL: "ftp://ip.internal/"},
+		{URL: "ip.internal/v4"},
+		{URL: "https://ip.internal/", Family: "both"},
+		{URL: "https://ip.internal/", JSONPath: ".ip", Regex: "(.*)"},
+		{URL: "https://ip.internal/", JSONPath: ".data..ip"},
+		{URL: "https://ip.internal/", Regex: "("},
+	} {
+		if _, err := NewServices([]Service{cfg}); err == nil {
+			t.Fatalf("expected %+v to be rejected", cfg)
+		}
+	}
+	if _, err := decodeServices([]any{"ip.fm", 42}); err == nil {
+		t.Fatal("expected a non-string, non-map entry to be rejected")
+	}
+	if _, err := decodeServices([]any{map[string]any{"url": "https://ip.internal/", "jsonpath": ".ip"}}); err == nil || !strings.Contains(err.Error(), "jsonpath") {
+		t.Fatalf("expected an unknown service key to be rejected, got %v", err)
+	}
+	if _, err := decodeConfig(map[string]any{"stratgy": "race", "services": []any{"ip.fm"}}); err == nil || !strings.Contains(err.Error(), "stratgy") {
+		t.Fatalf("expected an unknown ip_service key to be rejected, got %v", err)
+	}
+}
+
+func TestGetIPsUsesCustomServices(t *testing.T) {
+	responses := map[string]string{
+		"/json":  `{"data": {"addresses": ["8.8.8.8"]}}`,
+		"/html":  `<html><body>Your address: <b>2606:4700:4700::1111</b></body></html>`,
+		"/plain": "8.8.4.4\n",
+	}
+	var paths []string
+	transport := roundTripFunc(func(request *http.Request) (*http.Response, error) {
+		paths = append(paths, request.URL.Path)
+		status := http.StatusOK
+		if request.URL.Path == "/json" && request.Header.Get("Authorization") != "Bearer secret" {
+			status = http.StatusUnauthorized
+		}
+		return &http.Response{
+			StatusCode: status,
+			Header:     make(http.Header),
+			Body:       io.NopCloser(strings.NewReader(responses[request.URL.Path])),
+			Request:    request,
+		}, nil
+	})
+	service, err := NewServices([]Service{
+		{URL: "https://ip.internal/json", Family: "ipv4", JSONPath: ".data.addresses[0]", Headers: map[string]string{"Authorization": "Bearer secret"}},
+		{URL: "https://ip.internal/html", Family: "ipv6", Regex: `address: <b>([^<]+)</b>`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.client4.SetTransport(transport)
+	service.client6.SetTransport(transport)
+
+	result, err := service.GetIPs(context.Background(), provider.FamilyRequest{IPv4: true, IPv6: true})
+	if err != nil {
+		t.Fatalf("get IPs: %v", err)
+	}
+	if result.IPv4 != "8.8.8.8" || result.IPv6 != "2606:4700:4700::1111" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if got := strings.Join(paths, ","); got != "/json,/html" {
+		t.Fatalf("expected each service to be asked for its own family only, got %s", got)
+	}
+
+	service, err = NewServices([]Service{{URL: "https://ip.internal/plain", Family: "ipv4"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.client6.SetTransport(transport)
+	if _, err := service.GetIPs(context.Background(), provider.FamilyRequest{IPv6: true}); err == nil || !strings.Contains(err.Error(), "no IP services configured for ipv6") {
+		t.Fatalf("expected no IPv6 services, got %v", err)
+	}
+}
+
+func TestGetIPsReportsExtractionFailures(t *testing.T) {
+	const secret = "leaked-token"
+	tests := []struct {
+		name string
+		svc  Service
+		body string
+		want string
+	}{
+		{name: "invalid JSON", svc: Service{JSONPath: ".ip"}, body: "token=" + secret, want: "invalid JSON"},
+		{name: "missing JSON key", svc: Service{JSONPath: ".ip"}, body: `{"addr": "` + secret + `"}`, want: "no value at the JSON path"},
+		{name: "JSON number", svc: Service{JSONPath: ".ip"}, body: `{"ip": 8}`, want: "no string at the JSON path"},
+		{name: "JSON index out of range", svc: Service{JSONPath: ".ips[1]"}, body: `{"ips": ["8.8.8.8"]}`, want: "no value at the JSON path"},
+		{name: "no regex match", svc: Service{Regex: `ip=(\S+)`}, body: secret, want: "does not match the regex"},
+		{name: "wrong family", svc: Service{JSONPath: ".ip"}, body: `{"ip": "2606:4700:4700::1111"}`, want: "invalid address"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.svc.URL = "https://ip.internal/"
+			service, err := NewServices([]Service{tt.svc})
+			if err != nil {
+				t.Fatal(err)
+			}
+			calls := 0
+			service.client4.SetTransport(ipResponseTransport(&calls, tt.body))
+			_, err = service.GetIPs(context.Background(), provider.FamilyRequest{IPv4: true})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+			if strings.Contains(err.Error(), secret) {
+				t.Fatalf("error leaks the response body: %v", err)
 			}
 		})
 	}
 }
+
+func TestGetIPsAllowsPrivateAddressesWhenConfigured(t *testing.T) {
+	tests := []struct {
+		body         string
+		allowPrivate bool
+		want         bool
+	}{
+		{body: "10.0.0.1", allowPrivate: true, want: true},
+		{body: "100.64.0.1", allowPrivate: true, want: true},
+		{body: "10.0.0.1"},
+		{body: "127.0.0.1", allowPrivate: true},
+		{body: "169.254.0.1", allowPrivate: true},
+		{body: "0.0.0.0", allowPrivate: true},
+	}
+	for _, tt := range tests {
+		service, err := NewServices([]Service{{URL: "https://ip.internal/", AllowPrivate: tt.allowPrivate}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		calls := 0
+		service.client4.SetTransport(ipResponseTransport(&calls, tt.body))
+		result, err := service.GetIPs(context.Background(), provider.FamilyRequest{IPv4: true})
+		if got := err == nil; got != tt.want {
+			t.Fatalf("%s with allow_private=%v: accepted = %v, want %v (%v)", tt.body, tt.allowPrivate, got, tt.want, err)
+		}
+		if tt.want && result.IPv4 != tt.body {
+			t.Fatalf("IPv4 = %q, want %q", result.IPv4, tt.body)
+		}
+	}
+}
+
+// hostTransport answers each host with its body, or blocks until the request
+// is canceled when the body is empty.
+func hostTransport(bodies map[string]string, canceled *atomic.Int32) http.RoundTripper {
+	return roundTripFunc(func(request *http.Request) (*http.Response, error) {
+		body, ok := bodies[request.URL.Hostname()]
+		if !ok {
+			return nil, fmt.Errorf("unexpected request host %q", request.URL.Host)
+		}
+		if body == "" {
+			<-request.Context().Done()
+			canceled.Add(1)
+			return nil, request.Context().Err()
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     make(http.Header),
+			Body:       io.NopCloser(strings.NewReader(body)),
+			Request:    request,
+		}, nil
+	})
+}
+
+func strategyService(t *testing.T, strategy string, quorum int, bodies map[string]string, hosts ...string) (*IpService, *atomic.Int32) {
+	t.Helper()
+	services := make([]Service, 0, len(hosts))
+	for _, host := range hosts {
+		services = append(services, Service{Name: host, URL: "https://" + host + "/"})
+	}
+	service, err := NewWithConfig(&Config{Services: services, Strategy: strategy, Quorum: quorum})
+	if err != nil {
+		t.Fatal(err)
+	}
+	canceled := &atomic.Int32{}
+	service.client4.SetRetryCount(0).SetTransport(hostTransport(bodies, canceled))
+	return service, canceled
+}
+
+func TestDocumentedStrategyConfigIsAccepted(t *testing.T) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	err := v.ReadConfig(strings.NewReader(`
+providers:
+  ip_service:
+    strategy: quorum
+    quorum: 2
+    services:
+      - ifconfig.me
+      - ip.sb
+      - url: https://ip.internal/
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var raw any
+	if err := v.UnmarshalKey("providers.ip_service", &raw); err != nil {
+		t.Fatalf("UnmarshalKey returned an error: %v", err)
+	}
+	cfg, err := decodeConfig(raw)
+	if err != nil {
+		t.Fatalf("decode config: %v", err)
+	}
+	service, err := NewWithConfig(cfg)
+	if err != nil {
+		t.Fatalf("NewWithConfig returned an error: %v", err)
+	}
+	if service.strategy != StrategyQuorum || service.quorum != 2 || len(service.services) != 3 {
+		t.Fatalf("unexpected provider: strategy=%q quorum=%d services=%d", service.strategy, service.quorum, len(service.services))
+	}
+
+	if _, _, err := provider.GetProvider(testConfig{services: map[string]any{"strategy": "race", "services": []any{"ip.fm"}}}); err != nil {
+		t.Fatalf("expected the map form to be accepted: %v", err)
+	}
+	if _, _, err := provider.GetProvider(testConfig{services: map[string]any{"strategy": "race"}}); err == nil {
+		t.Fatal("expected a map without services to be rejected")
+	}
+}
+
+func TestNewWithConfigValidatesStrategy(t *testing.T) {
+	services := []Service{{Name: "ip.fm"}, {Name: "ip.sb"}, {Name: "ifconfig.me"}}
+	service, err := NewWithConfig(&Config{Services: services})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if service.strategy != StrategyFirst {
+		t.Fatalf("strategy = %q, want %q", service.strategy, StrategyFirst)
+	}
+	if service, err = NewWithConfig(&Config{Services: services, Strategy: "Quorum"}); err != nil || service.quorum != defaultQuorum {
+		t.Fatalf("expected the default quorum, got %v, %v", service, err)
+	}
+
+	for _, cfg := range []*Config{
+		nil,
+		{Services: services, Strategy: "majority"},
+		{Services: services, Strategy: "race", Quorum: 2},
+		{Services: services, Strategy: "quorum", Quorum: 4},
+		{Services: services, Strategy: "quorum", Quorum: -1},
+		{Services: services[:1], Strategy: "quorum"},
+	} {
+		if _, err := NewWithConfig(cfg); err == nil {
+			t.Fatalf("expected %+v to be rejected", cfg)
+		}
+	}
+}
+
+func TestGetIPsRaceTakesFastestAnswer(t *testing.T) {
+	service, canceled := strategyService(t, StrategyRace, 0, map[string]string{
+		"slow.test":    "",
+		"invalid.test": "10.0.0.1",
+		"fast.test":    "8.8.8.8",
+	}, "slow.test", "invalid.test", "fast.test")
+
+	result, err := service.GetIPs(context.Background(), provider.FamilyRequest{IPv4: true})
+	if err != nil {
+		t.Fatalf("get IPs: %v", err)
+	}
+	if result.IPv4 != "8.8.8.8" {
+		t.Fatalf("IPv4 = %q, want 8.8.8.8", result.IPv4)
+	}
+	deadline := time.Now().Add(time.Second)
+	for canceled.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if canceled.Load() != 1 {
+		t.Fatal("expected the slow request to be canceled")
+	}
+
+	service, _ = strategyService(t, StrategyRace, 0, map[string]string{
+		"first.test":  "10.0.0.1",
+		"second.test": "not an address",
+	}, "first.test", "second.test")
+	_, err = service.GetIPs(context.Background(), provider.FamilyRequest{IPv4: true})
+	if err == nil || !strings.Contains(err.Error(), `"first.test"`) || !strings.Contains(err.Error(), `"second.test"`) {
+		t.Fatalf("expected every failure to be reported, got %v", err)
+	}
+}
+
+func TestGetIPsQuorumRequiresAgreement(t *testing.T) {
+	service, _ := strategyService(t, StrategyQuorum, 2, map[string]string{
+		"a.test": "8.8.8.8",
+		"b.test": "1.2.3.4",
+		"c.test": "8.8.8.8",
+	}, "a.test", "b.test", "c.test")
+
+	result, err := service.GetIPs(context.Background(), provider.FamilyRequest{IPv4: true})
+	if err != nil {
+		t.Fatalf("get IPs: %v", err)
+	}
+	if result.IPv4 != "8.8.8.8" {
+		t.Fatalf("IPv4 = %q, want 8.8.8.8", result.IPv4)
+	}
+
+	service, _ = strategyService(t, StrategyQuorum, 2, map[string]string{
+		"a.test": "8.8.8.8",
+		"b.test": "1.2.3.4",
+		"c.test": "192.168.1.1",
+	}, "a.test", "b.test", "c.test")
+	_, err = service.GetIPs(context.Background(), provider.FamilyRequest{IPv4: true})
+	if err == nil {
+		t.Fatal("expected disagreeing services to fail the quorum")
+	}
+	for _, want := range []string{"quorum of 2 not reached for ipv4", `8.8.8.8 from "a.test"`, `1.2.3.4 from "b.test"`, `IP service "c.test" (ipv4) returned an invalid address`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected error to contain %q, got %v", want, err)
+		}
+	}
+}
+
+func TestGetIPsQuorumCountsEquivalentAddressesTogether(t *testing.T) {
+	services := []Service{
+		{Name: "a.test", URL: "https://a.test/", Family: "ipv6"},
+		{Name: "b.test", URL: "https://b.test/", Family: "ipv6"},
+		{Name: "v4.test", URL: "https://v4.test/", Family: "ipv4"},
+	}
+	service, err := NewWithConfig(&Config{Services: services, Strategy: StrategyQuorum})
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.client6.SetRetryCount(0).SetTransport(hostTransport(map[string]string{
+		"a.test": "2606:4700:4700::1111",
+		"b.test": "2606:4700:4700:0000:0000:0000:0000:1111",
+	}, &atomic.Int32{}))
+
+	result, err := service.GetIPs(context.Background(), provider.FamilyRequest{IPv6: true})
+	if err != nil {
+		t.Fatalf("get IPs: %v", err)
+	}
+	if result.IPv6 != "2606:4700:4700::1111" {
+		t.Fatalf("IPv6 = %q, want 2606:4700:4700::1111", result.IPv6)
+	}
+	if _, err := service.GetIPs(context.Background(), provider.FamilyRequest{IPv4: true}); err == nil || !strings.Contains(err.Error(), "cannot be reached: 1 of the configured services support ipv4") {
+		t.Fatalf("expected too few IPv4 services to be reported, got %v", err)
+	}
+}
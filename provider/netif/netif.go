@@ -15,7 +15,17 @@ type Netif struct {
 }
 
 type Config struct {
-	Name string `mapstructure:"name"`
+	Name  string `mapstructure:"name"`
+	Watch bool   `mapstructure:"watch"`
+}
+
+// watchingNetif is a Netif that also reports address change events.
+type watchingNetif struct {
+	*Netif
+}
+
+func (n watchingNetif) Watch(ctx context.Context, changed func()) error {
+	return watchAddresses(ctx, n.name, n.interfaceByName, changed)
 }
 
 func init() {
@@ -32,7 +42,14 @@ func init() {
 		if cfg.Name == "" {
 			return nil, fmt.Errorf("missing network interface name")
 		}
-		return New(&cfg)
+		n, err := New(&cfg)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.Watch {
+			return watchingNetif{n}, nil
+		}
+		return n, nil
 	})
 }
 
@@ -0,0 +1,108 @@
+//go:build linux
+
+package netif
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"slices"
+
+	"golang.org/x/sys/unix"
+)
+
+// watchAddresses subscribes to rtnetlink address notifications and calls
+// changed when an address is added to or removed from the named interface.
+// The interface is resolved on every event because PPP links are recreated
+// with a new index on reconnect.
+func watchAddresses(
+	ctx context.Context,
+	name string,
+	interfaceByName func(string) (*net.Interface, error),
+	changed func(),
+) error {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC|unix.SOCK_NONBLOCK, unix.NETLINK_ROUTE)
+	if err != nil {
+		return fmt.Errorf("open netlink socket: %w", err)
+	}
+	addr := &unix.SockaddrNetlink{
+		Family: unix.AF_NETLINK,
+		Groups: unix.RTMGRP_IPV4_IFADDR | unix.RTMGRP_IPV6_IFADDR,
+	}
+	if err := unix.Bind(fd, addr); err != nil {
+		unix.Close(fd)
+		return fmt.Errorf("bind netlink socket: %w", err)
+	}
+
+	// A non-blocking descriptor is registered with the runtime poller, so
+	// closing the file interrupts a pending Read when ctx is canceled.
+	socket := os.NewFile(uintptr(fd), "netlink")
+	defer socket.Close()
+	stop := context.AfterFunc(ctx, func() { socket.Close() })
+	defer stop()
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := socket.Read(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if errors.Is(err, unix.ENOBUFS) {
+				// The kernel dropped notifications; assume the interface changed.
+				changed()
+				continue
+			}
+			return fmt.Errorf("read netlink socket: %w", err)
+		}
+
+		indexes, err := addressEventIndexes(buf[:n])
+		if err != nil {
+			slog.Debug("ignoring malformed netlink message", "interface", name, "error", err)
+			continue
+		}
+		if len(indexes) == 0 {
+			continue
+		}
+		iface, err := interfaceByName(name)
+		if err != nil {
+			continue
+		}
+		if slices.Contains(indexes, iface.Index) {
+			changed()
+		}
+	}
+}
+
+// addressEventIndexes returns the interface indexes of the RTM_NEWADDR and
+// RTM_DELADDR messages in one netlink datagram.
+func addressEventIndexes(b []byte) ([]int, error) {
+	var indexes []int
+	for len(b) >= unix.SizeofNlMsghdr {
+		length := int(binary.NativeEndian.Uint32(b[0:4]))
+		msgType := binary.NativeEndian.Uint16(b[4:6])
+		if length < unix.SizeofNlMsghdr || length > len(b) {
+			return nil, fmt.Errorf("invalid netlink message length %d", length)
+		}
+
+		if msgType == unix.RTM_NEWADDR || msgType == unix.RTM_DELADDR {
+			body := b[unix.SizeofNlMsghdr:length]
+			if len(body) < unix.SizeofIfAddrmsg {
+				return nil, fmt.Errorf("truncated address message")
+			}
+			// struct ifaddrmsg: family, prefixlen, flags, scope, then the index.
+			indexes = append(indexes, int(binary.NativeEndian.Uint32(body[4:8])))
+		}
+
+		aligned := (length + unix.NLMSG_ALIGNTO - 1) &^ (unix.NLMSG_ALIGNTO - 1)
+		if aligned >= len(b) {
+			break
+		}
+		b = b[aligned:]
+	}
+	return indexes, nil
+}
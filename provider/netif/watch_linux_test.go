@@ -0,0 +1,71 @@
+//go:build linux
+
+package netif
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func netlinkMessage(msgType uint16, index uint32) []byte {
+	length := unix.SizeofNlMsghdr + unix.SizeofIfAddrmsg
+	msg := make([]byte, length)
+	binary.NativeEndian.PutUint32(msg[0:4], uint32(length))
+	binary.NativeEndian.PutUint16(msg[4:6], msgType)
+	binary.NativeEndian.PutUint32(msg[unix.SizeofNlMsghdr+4:], index)
+	return msg
+}
+
+func TestAddressEventIndexes(t *testing.T) {
+	var datagram []byte
+	datagram = append(datagram, netlinkMessage(unix.RTM_NEWADDR, 3)...)
+	datagram = append(datagram, netlinkMessage(unix.RTM_NEWLINK, 4)...)
+	datagram = append(datagram, netlinkMessage(unix.RTM_DELADDR, 5)...)
+
+	indexes, err := addressEventIndexes(datagram)
+	if err != nil {
+		t.Fatalf("addressEventIndexes returned error: %v", err)
+	}
+	if !slices.Equal(indexes, []int{3, 5}) {
+		t.Fatalf("expected address events for indexes [3 5], got %v", indexes)
+	}
+}
+
+func TestAddressEventIndexesRejectsMalformedMessages(t *testing.T) {
+	tooLong := netlinkMessage(unix.RTM_NEWADDR, 3)
+	binary.NativeEndian.PutUint32(tooLong[0:4], uint32(len(tooLong)+4))
+	truncated := netlinkMessage(unix.RTM_NEWADDR, 3)[:unix.SizeofNlMsghdr+2]
+	binary.NativeEndian.PutUint32(truncated[0:4], uint32(len(truncated)))
+
+	for name, datagram := range map[string][]byte{"length": tooLong, "body": truncated} {
+		if _, err := addressEventIndexes(datagram); err == nil {
+			t.Fatalf("expected malformed %s to return an error", name)
+		}
+	}
+}
+
+func TestWatchAddressesReturnsWhenContextIsCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() {
+		done <- watchAddresses(ctx, "lo", net.InterfaceByName, func() {})
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Skipf("netlink unavailable: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("watchAddresses did not return after cancellation")
+	}
+}
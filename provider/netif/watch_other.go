@@ -0,0 +1,13 @@
+//go:build !linux
+
+package netif
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+func watchAddresses(_ context.Context, _ string, _ func(string) (*net.Interface, error), _ func()) error {
+	return errors.New("network interface address events are only supported on Linux")
+}
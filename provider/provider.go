@@ -20,6 +20,14 @@ type Provider interface {
 	GetIPs(context.Context, FamilyRequest) (*IpResult, error)
 }
 
+// Watcher is implemented by providers that can push address change events in
+// addition to being polled. Watch blocks until ctx is canceled, calling changed
+// whenever the provider's addresses may have changed. changed must not block.
+// An error means events are unavailable and the provider is only polled.
+type Watcher interface {
+	Watch(ctx context.Context, changed func()) error
+}
+
 type ConfigReader = registry.ConfigReader
 
 type constructor = registry.Constructor[Provider]
@@ -0,0 +1,280 @@
+package stun
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/we11adam/uddns/internal/udpexchange"
+	"github.com/we11adam/uddns/provider"
+)
+
+const (
+	defaultPort = "3478"
+	dialTimeout = 1 * time.Second
+
+	headerLength         = 20
+	magicCookie          = 0x2112A442
+	bindingRequest       = 0x0001
+	bindingSuccess       = 0x0101
+	bindingError         = 0x0111
+	attrMappedAddress    = 0x0001
+	attrXORMappedAddress = 0x0020
+	familyIPv4           = 0x01
+	familyIPv6           = 0x02
+)
+
+// DefaultServers are queried when no servers are configured.
+var DefaultServers = []string{"stun.cloudflare.com:3478", "stun.l.google.com:19302"}
+
+// retransmitTimeouts are how long each Binding request waits for a response
+// before it is sent again, starting at RFC 5389's initial RTO of 500ms and
+// doubling.
+var retransmitTimeouts = []time.Duration{500 * time.Millisecond, 1 * time.Second, 2 * time.Second}
+
+type Config struct {
+	// Servers are STUN servers as host or host:port; the port defaults to
+	// 3478. They are tried in order until one returns a public address.
+	Servers []string `mapstructure:"servers"`
+}
+
+type Stun struct {
+	servers []string
+}
+
+func init() {
+	provider.Register("STUN", "providers.stun", func(v provider.ConfigReader) (provider.Provider, error) {
+		if !v.IsSet("providers.stun") {
+			return nil, provider.ErrNotConfigured
+		}
+
+		cfg := Config{}
+		err := v.UnmarshalKey("providers.stun", &cfg)
+		if err != nil {
+			return nil, err
+		}
+		return New(&cfg)
+	})
+}
+
+func New(cfg *Config) (*Stun, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("STUN config is nil")
+	}
+	configured := cfg.Servers
+	if len(configured) == 0 {
+		configured = DefaultServers
+	}
+	servers := make([]string, 0, len(configured))
+	for _, server := range configured {
+		address, err := serverAddress(server)
+		if err != nil {
+			return nil, err
+		}
+		servers = append(servers, address)
+	}
+	return &Stun{servers: servers}, nil
+}
+
+// serverAddress normalizes a configured server, which may also be written as
+// a stun: URI, to host:port.
+func serverAddress(server string) (string, error) {
+	address := strings.TrimPrefix(strings.TrimSpace(server), "stun:")
+	if address == "" {
+		return "", fmt.Errorf("empty STUN server")
+	}
+	address, err := udpexchange.HostPort(address, defaultPort)
+	if err != nil {
+		return "", fmt.Errorf("invalid STUN server %q", server)
+	}
+	return address, nil
+}
+
+func (s *Stun) GetIPs(ctx context.Context, families provider.FamilyRequest) (*provider.IpResult, error) {
+	if !families.IPv4 && !families.IPv6 {
+		return nil, fmt.Errorf("no IP families requested")
+	}
+	result := &provider.IpResult{}
+	var failures []error
+
+	if families.IPv4 {
+		ipv4, err := s.getIP(ctx, "udp4", "ipv4")
+		if err == nil {
+			result.IPv4 = ipv4
+		} else if ctx.Err() != nil {
+			return nil, ctx.Err()
+		} else {
+			failures = append(failures, fmt.Errorf("IPv4 lookup failed: %w", err))
+		}
+	}
+
+	if families.IPv6 {
+		ipv6, err := s.getIP(ctx, "udp6", "ipv6")
+		if err == nil {
+			result.IPv6 = ipv6
+		} else if ctx.Err() != nil {
+			return nil, ctx.Err()
+		} else {
+			failures = append(failures, fmt.Errorf("IPv6 lookup failed: %w", err))
+		}
+	}
+
+	if result.IPv4 == "" && result.IPv6 == "" {
+		if len(failures) == 0 {
+			failures = append(failures, fmt.Errorf("failed to get requested IP addresses"))
+		}
+		return nil, errors.Join(failures...)
+	}
+
+	return result, nil
+}
+
+// getIP asks each server in turn over a socket of the given network, udp4 or
+// udp6, so that the mapped address belongs to that family.
+func (s *Stun) getIP(ctx context.Context, network, family string) (string, error) {
+	var failures []error
+	for _, server := range s.servers {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+
+		slog.Debug("sending STUN binding request", "provider", "stun", "server", server, "family", family)
+		addr, err := query(ctx, network, server)
+		if err != nil {
+			if ctx.Err() != nil {
+				return "", ctx.Err()
+			}
+			slog.Debug("STUN binding request failed", "provider", "stun", "server", server, "family", family, "error", err)
+			failures = append(failures, fmt.Errorf("STUN server %q (%s) failed: %w", server, family, err))
+			continue
+		}
+		if (family == "ipv4") != addr.Is4() || !provider.IsPublicRoutable(addr) {
+			slog.Debug("ignoring non-public STUN mapped address", "provider", "stun", "server", server, "family", family, "ip", addr)
+			failures = append(failures, fmt.Errorf("STUN server %q (%s) returned a non-public address", server, family))
+			continue
+		}
+		slog.Debug("got IP address", "provider", "stun", "family", family, "ip", addr)
+		return addr.String(), nil
+	}
+	return "", errors.Join(failures...)
+}
+
+// query sends a Binding request to server and returns the mapped address from
+// the response, retransmitting while no response arrives.
+func query(ctx context.Context, network, server string) (netip.Addr, error) {
+	dialer := net.Dialer{Timeout: dialTimeout}
+	conn, err := dialer.DialContext(ctx, network, server)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	defer conn.Close()
+
+	request, txID := newBindingRequest()
+	var addr netip.Addr
+	_, err = udpexchange.Exchange(ctx, conn, request, retransmitTimeouts, func(message []byte) error {
+		var err error
+		addr, err = parseBindingResponse(message, txID)
+		return err
+	})
+	return addr, err
+}
+
+func newBindingRequest() ([]byte, [12]byte) {
+	var txID [12]byte
+	_, _ = rand.Read(txID[:])
+	request := make([]byte, headerLength)
+	binary.BigEndian.PutUint16(request[0:2], bindingRequest)
+	binary.BigEndian.PutUint32(request[4:8], magicCookie)
+	copy(request[8:], txID[:])
+	return request, txID
+}
+
+// parseBindingResponse returns the address in a Binding success response,
+// preferring XOR-MAPPED-ADDRESS over the legacy MAPPED-ADDRESS.
+func parseBindingResponse(message []byte, txID [12]byte) (netip.Addr, error) {
+	if len(message) < headerLength ||
+		binary.BigEndian.Uint32(message[4:8]) != magicCookie ||
+		[12]byte(message[8:20]) != txID {
+		return netip.Addr{}, udpexchange.ErrForeignMessage
+	}
+	switch binary.BigEndian.Uint16(message[0:2]) {
+	case bindingSuccess:
+	case bindingError:
+		return netip.Addr{}, fmt.Errorf("server returned a Binding error response")
+	default:
+		return netip.Addr{}, udpexchange.ErrForeignMessage
+	}
+	length := int(binary.BigEndian.Uint16(message[2:4]))
+	if headerLength+length > len(message) {
+		return netip.Addr{}, fmt.Errorf("truncated response")
+	}
+
+	var mapped netip.Addr
+	attributes := message[headerLength : headerLength+length]
+	for len(attributes) >= 4 {
+		attrType := binary.BigEndian.Uint16(attributes[0:2])
+		attrLength := int(binary.BigEndian.Uint16(attributes[2:4]))
+		if 4+attrLength > len(attributes) {
+			return netip.Addr{}, fmt.Errorf("truncated attribute")
+		}
+		value := attributes[4 : 4+attrLength]
+		switch attrType {
+		case attrXORMappedAddress:
+			return decodeAddress(value, txID, true)
+		case attrMappedAddress:
+			if addr, err := decodeAddress(value, txID, false); err == nil {
+				mapped = addr
+			}
+		}
+		// Attribute values are padded to a multiple of four bytes.
+		padded := min(4+(attrLength+3)&^3, len(attributes))
+		attributes = attributes[padded:]
+	}
+	if mapped.IsValid() {
+		return mapped, nil
+	}
+	return netip.Addr{}, fmt.Errorf("response has no mapped address")
+}
+
+// decodeAddress decodes a MAPPED-ADDRESS or XOR-MAPPED-ADDRESS value. The
+// port is not needed and is ignored.
+func decodeAddress(value []byte, txID [12]byte, xored bool) (netip.Addr, error) {
+	if len(value) < 4 {
+		return netip.Addr{}, fmt.Errorf("malformed mapped address")
+	}
+	var key [16]byte
+	if xored {
+		binary.BigEndian.PutUint32(key[0:4], magicCookie)
+		copy(key[4:], txID[:])
+	}
+	raw := value[4:]
+	switch value[1] {
+	case familyIPv4:
+		if len(raw) != 4 {
+			return netip.Addr{}, fmt.Errorf("malformed mapped IPv4 address")
+		}
+		var ip [4]byte
+		for i := range ip {
+			ip[i] = raw[i] ^ key[i]
+		}
+		return netip.AddrFrom4(ip), nil
+	case familyIPv6:
+		if len(raw) != 16 {
+			return netip.Addr{}, fmt.Errorf("malformed mapped IPv6 address")
+		}
+		var ip [16]byte
+		for i := range ip {
+			ip[i] = raw[i] ^ key[i]
+		}
+		return netip.AddrFrom16(ip), nil
+	default:
+		return netip.Addr{}, fmt.Errorf("unknown mapped address family %d", value[1])
+	}
+}
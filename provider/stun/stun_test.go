@@ -0,0 +1,273 @@
+package stun
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/we11adam/uddns/internal/udpexchange"
+	"github.com/we11adam/uddns/provider"
+)
+
+// responder is a local STUN server that maps every client to a fixed address.
+type responder struct {
+	conn     net.PacketConn
+	mapped   netip.Addr
+	legacy   atomic.Bool
+	drop     atomic.Int32
+	requests atomic.Int32
+}
+
+func newResponder(t *testing.T, network, address string, mapped string) *responder {
+	t.Helper()
+	conn, err := net.ListenPacket(network, address)
+	if err != nil {
+		t.Skipf("cannot listen on %s %s: %v", network, address, err)
+	}
+	r := &responder{conn: conn, mapped: netip.MustParseAddr(mapped)}
+	t.Cleanup(func() { _ = conn.Close() })
+	go r.serve()
+	return r
+}
+
+func (r *responder) addr() string {
+	return r.conn.LocalAddr().String()
+}
+
+func (r *responder) serve() {
+	buf := make([]byte, udpexchange.MaxDatagramSize)
+	for {
+		n, client, err := r.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if n != headerLength || binary.BigEndian.Uint16(buf[0:2]) != bindingRequest || binary.BigEndian.Uint32(buf[4:8]) != magicCookie {
+			continue
+		}
+		r.requests.Add(1)
+		if r.drop.Add(-1) >= 0 {
+			continue
+		}
+		txID := [12]byte(buf[8:20])
+		// A stray datagram with another transaction ID must be ignored.
+		stray := txID
+		stray[0] ^= 0xff
+		_, _ = r.conn.WriteTo(r.response(stray), client)
+		_, _ = r.conn.WriteTo(r.response(txID), client)
+	}
+}
+
+func (r *responder) response(txID [12]byte) []byte {
+	// SOFTWARE with an unpadded length exercises attribute padding.
+	attributes := []byte{0x80, 0x22, 0x00, 0x05, 'u', 'd', 'd', 'n', 's', 0, 0, 0}
+	raw := r.mapped.AsSlice()
+	family := byte(familyIPv4)
+	if r.mapped.Is6() {
+		family = familyIPv6
+	}
+	value := append([]byte{0, family, 0x12, 0x34}, raw...)
+	attrType := uint16(attrMappedAddress)
+	if !r.legacy.Load() {
+		attrType = attrXORMappedAddress
+		var key [16]byte
+		binary.BigEndian.PutUint32(key[0:4], magicCookie)
+		copy(key[4:], txID[:])
+		for i := range raw {
+			value[4+i] ^= key[i]
+		}
+	}
+	attributes = binary.BigEndian.AppendUint16(attributes, attrType)
+	attributes = binary.BigEndian.AppendUint16(attributes, uint16(len(value)))
+	attributes = append(attributes, value...)
+
+	message := binary.BigEndian.AppendUint16(nil, bindingSuccess)
+	message = binary.BigEndian.AppendUint16(message, uint16(len(attributes)))
+	message = binary.BigEndian.AppendUint32(message, magicCookie)
+	message = append(message, txID[:]...)
+	return append(message, attributes...)
+}
+
+func useShortRetransmits(t *testing.T) {
+	t.Helper()
+	previous := retransmitTimeouts
+	retransmitTimeouts = []time.Duration{50 * time.Millisecond, 100 * time.Millisecond}
+	t.Cleanup(func() { retransmitTimeouts = previous })
+}
+
+func TestParseBindingResponseRFC5769Vector(t *testing.T) {
+	// RFC 5769 section 2.2: an IPv4 response with SOFTWARE, XOR-MAPPED-ADDRESS,
+	// MESSAGE-INTEGRITY, and FINGERPRINT attributes.
+	message := []byte{
+		0x01, 0x01, 0x00, 0x3c, 0x21, 0x12, 0xa4, 0x42,
+		0xb7, 0xe7, 0xa7, 0x01, 0xbc, 0x34, 0xd6, 0x86, 0xfa, 0x87, 0xdf, 0xae,
+		0x80, 0x22, 0x00, 0x0b, 0x74, 0x65, 0x73, 0x74, 0x20, 0x76, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x20,
+		0x00, 0x20, 0x00, 0x08, 0x00, 0x01, 0xa1, 0x47, 0xe1, 0x12, 0xa6, 0x43,
+		0x00, 0x08, 0x00, 0x14, 0x2b, 0x91, 0xf5, 0x99, 0xfd, 0x9e, 0x90, 0xc3, 0x8c, 0x74,
+		0x89, 0xf9, 0x2a, 0xf9, 0xba, 0x53, 0xf0, 0x6b, 0xe7, 0xd7,
+		0x80, 0x28, 0x00, 0x04, 0xc0, 0x7d, 0x4c, 0x96,
+	}
+	txID := [12]byte(message[8:20])
+
+	addr, err := parseBindingResponse(message, txID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr != netip.MustParseAddr("192.0.2.1") {
+		t.Fatalf("mapped address = %s, want 192.0.2.1", addr)
+	}
+
+	txID[0] ^= 0xff
+	if _, err := parseBindingResponse(message, txID); err != udpexchange.ErrForeignMessage {
+		t.Fatalf("expected a response to another request to be ignored, got %v", err)
+	}
+	if _, err := parseBindingResponse(message[:40], [12]byte(message[8:20])); err == nil {
+		t.Fatal("expected a truncated response to be rejected")
+	}
+}
+
+func TestGetIPsReturnsMappedIPv4(t *testing.T) {
+	server := newResponder(t, "udp4", "127.0.0.1:0", "8.8.8.8")
+	stun, err := New(&Config{Servers: []string{"stun:" + server.addr()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := stun.GetIPs(context.Background(), provider.FamilyRequest{IPv4: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IPv4 != "8.8.8.8" || result.IPv6 != "" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+}
+
+func TestGetIPsReturnsMappedIPv6(t *testing.T) {
+	server := newResponder(t, "udp6", "[::1]:0", "2606:4700:4700::1111")
+	stun, err := New(&Config{Servers: []string{server.addr()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := stun.GetIPs(context.Background(), provider.FamilyRequest{IPv6: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IPv6 != "2606:4700:4700::1111" || result.IPv4 != "" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+}
+
+func TestGetIPsAcceptsLegacyMappedAddress(t *testing.T) {
+	server := newResponder(t, "udp4", "127.0.0.1:0", "8.8.4.4")
+	server.legacy.Store(true)
+	stun, err := New(&Config{Servers: []string{server.addr()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := stun.GetIPs(context.Background(), provider.FamilyRequest{IPv4: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IPv4 != "8.8.4.4" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+}
+
+func TestGetIPsRetransmitsLostRequests(t *testing.T) {
+	useShortRetransmits(t)
+	server := newResponder(t, "udp4", "127.0.0.1:0", "8.8.8.8")
+	server.drop.Store(1)
+	stun, err := New(&Config{Servers: []string{server.addr()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := stun.GetIPs(context.Background(), provider.FamilyRequest{IPv4: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got := server.requests.Load(); got != 2 {
+		t.Fatalf("expected one retransmission, got %d requests", got)
+	}
+}
+
+func TestGetIPsSkipsNonPublicAndSilentServers(t *testing.T) {
+	useShortRetransmits(t)
+	silent := newResponder(t, "udp4", "127.0.0.1:0", "8.8.8.8")
+	silent.drop.Store(100)
+	private := newResponder(t, "udp4", "127.0.0.1:0", "100.64.0.10")
+	public := newResponder(t, "udp4", "127.0.0.1:0", "8.8.8.8")
+	stun, err := New(&Config{Servers: []string{silent.addr(), private.addr(), public.addr()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := stun.GetIPs(context.Background(), provider.FamilyRequest{IPv4: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IPv4 != "8.8.8.8" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	stun, err = New(&Config{Servers: []string{silent.addr(), private.addr()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = stun.GetIPs(context.Background(), provider.FamilyRequest{IPv4: true})
+	if err == nil || !strings.Contains(err.Error(), "no response") || !strings.Contains(err.Error(), "non-public address") {
+		t.Fatalf("expected both failures to be reported, got %v", err)
+	}
+}
+
+func TestGetIPsStopsWhenContextIsCanceled(t *testing.T) {
+	server := newResponder(t, "udp4", "127.0.0.1:0", "8.8.8.8")
+	server.drop.Store(100)
+	stun, err := New(&Config{Servers: []string{server.addr()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	startedAt := time.Now()
+	_, err = stun.GetIPs(ctx, provider.FamilyRequest{IPv4: true})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected the context error, got %v", err)
+	}
+	if elapsed := time.Since(startedAt); elapsed > time.Second {
+		t.Fatalf("expected cancellation to interrupt the read, took %s", elapsed)
+	}
+}
+
+func TestNewNormalizesServers(t *testing.T) {
+	stun, err := New(&Config{Servers: []string{"stun.example.com", "stun:stun.example.net:19302", "2001:db8::1", "[2001:db8::2]:5349"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"stun.example.com:3478", "stun.example.net:19302", "[2001:db8::1]:3478", "[2001:db8::2]:5349"}
+	for i, server := range stun.servers {
+		if server != want[i] {
+			t.Fatalf("servers = %v, want %v", stun.servers, want)
+		}
+	}
+
+	stun, err = New(&Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stun.servers) != len(DefaultServers) {
+		t.Fatalf("expected default servers, got %v", stun.servers)
+	}
+
+	for _, servers := range [][]string{{""}, {"stun.example.com/path"}, {"bad:host:name:x"}} {
+		if _, err := New(&Config{Servers: servers}); err == nil {
+			t.Fatalf("expected %v to be rejected", servers)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+//go:build linux
+
+package upnp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const rtfGateway = 0x2
+
+// defaultGateway returns the IPv4 gateway of the default route.
+func defaultGateway() (netip.Addr, error) {
+	file, err := os.Open("/proc/net/route")
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	defer file.Close()
+	return parseRouteTable(file)
+}
+
+// parseRouteTable finds the default route in the /proc/net/route format,
+// where addresses are hexadecimal in host byte order.
+func parseRouteTable(r io.Reader) (netip.Addr, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Scan() // Skip the header.
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || fields[1] != "00000000" {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[3], 16, 16)
+		if err != nil || flags&rtfGateway == 0 {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[2], 16, 32)
+		if err != nil {
+			continue
+		}
+		var gateway [4]byte
+		binary.NativeEndian.PutUint32(gateway[:], uint32(value))
+		return netip.AddrFrom4(gateway), nil
+	}
+	if err := scanner.Err(); err != nil {
+		return netip.Addr{}, err
+	}
+	return netip.Addr{}, fmt.Errorf("no default IPv4 route")
+}
@@ -0,0 +1,32 @@
+//go:build linux
+
+package upnp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseRouteTableFindsDefaultGateway(t *testing.T) {
+	hexAddr := func(a, b, c, d byte) string {
+		return fmt.Sprintf("%08X", binary.NativeEndian.Uint32([]byte{a, b, c, d}))
+	}
+	table := "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\t\tMTU\tWindow\tIRTT\n" +
+		"eth0\t" + hexAddr(192, 168, 1, 0) + "\t00000000\t0001\t0\t0\t0\t00FFFFFF\t0\t0\t0\n" +
+		"tun0\t00000000\t00000000\t0001\t0\t0\t0\t00000000\t0\t0\t0\n" +
+		"eth0\t00000000\t" + hexAddr(192, 168, 1, 1) + "\t0003\t0\t0\t100\t00000000\t0\t0\t0\n"
+
+	gateway, err := parseRouteTable(strings.NewReader(table))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gateway.String() != "192.168.1.1" {
+		t.Fatalf("gateway = %s, want 192.168.1.1", gateway)
+	}
+
+	if _, err := parseRouteTable(strings.NewReader(strings.Join(strings.Split(table, "\n")[:3], "\n"))); err == nil {
+		t.Fatal("expected a table without a default gateway to be rejected")
+	}
+}
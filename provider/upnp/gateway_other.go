@@ -0,0 +1,12 @@
+//go:build !linux
+
+package upnp
+
+import (
+	"errors"
+	"net/netip"
+)
+
+func defaultGateway() (netip.Addr, error) {
+	return netip.Addr{}, errors.New("finding the default gateway is only supported on Linux")
+}
@@ -0,0 +1,262 @@
+package upnp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const ssdpMulticastAddress = "239.255.255.250:1900"
+
+var (
+	// ssdpAddress is where M-SEARCH requests are sent.
+	ssdpAddress = ssdpMulticastAddress
+	// discoveryTimeout is how long SSDP discovery waits for a gateway to
+	// answer.
+	discoveryTimeout = 2 * time.Second
+)
+
+// searchTargets are the SSDP search targets of IGD versions 1 and 2.
+var searchTargets = []string{
+	"urn:schemas-upnp-org:device:InternetGatewayDevice:1",
+	"urn:schemas-upnp-org:device:InternetGatewayDevice:2",
+}
+
+// wanServicePrefixes are the service types that implement
+// GetExternalIPAddress.
+var wanServicePrefixes = []string{
+	"urn:schemas-upnp-org:service:WANIPConnection:",
+	"urn:schemas-upnp-org:service:WANPPPConnection:",
+}
+
+type igdService struct {
+	serviceType string
+	controlURL  string
+}
+
+type deviceDescription struct {
+	URLBase string `xml:"URLBase"`
+	Device  device `xml:"device"`
+}
+
+type device struct {
+	Services []struct {
+		ServiceType string `xml:"serviceType"`
+		ControlURL  string `xml:"controlURL"`
+	} `xml:"serviceList>service"`
+	Devices []device `xml:"deviceList>device"`
+}
+
+type soapEnvelope struct {
+	Body struct {
+		Response struct {
+			ExternalIPAddress string `xml:"NewExternalIPAddress"`
+		} `xml:"GetExternalIPAddressResponse"`
+		Fault struct {
+			ErrorCode        string `xml:"detail>UPnPError>errorCode"`
+			ErrorDescription string `xml:"detail>UPnPError>errorDescription"`
+		} `xml:"Fault"`
+	} `xml:"Body"`
+}
+
+// queryIGD calls GetExternalIPAddress on the gateway's WAN connection
+// services, discovering the gateway first when none is cached.
+func (u *UPnP) queryIGD(ctx context.Context) (netip.Addr, error) {
+	u.mu.Lock()
+	services := u.services
+	u.mu.Unlock()
+	if len(services) == 0 {
+		var err error
+		if services, err = u.discoverServices(ctx); err != nil {
+			return netip.Addr{}, err
+		}
+	}
+
+	var failures []error
+	for _, service := range services {
+		addr, err := u.getExternalIPAddress(ctx, service)
+		if err == nil {
+			u.mu.Lock()
+			u.services = services
+			u.mu.Unlock()
+			return addr, nil
+		}
+		if ctx.Err() != nil {
+			return netip.Addr{}, ctx.Err()
+		}
+		failures = append(failures, fmt.Errorf("%s: %w", service.serviceType, err))
+	}
+	// Discover again next time in case the gateway has changed.
+	u.mu.Lock()
+	u.services = nil
+	u.mu.Unlock()
+	return netip.Addr{}, errors.Join(failures...)
+}
+
+func (u *UPnP) discoverServices(ctx context.Context) ([]igdService, error) {
+	location := u.location
+	if location == "" {
+		var err error
+		if location, err = discoverLocation(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := u.httpClient.R().SetContext(ctx).Get(location)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.IsSuccess() {
+		return nil, fmt.Errorf("device description request failed: HTTP status %d", resp.StatusCode())
+	}
+	var description deviceDescription
+	if err := xml.Unmarshal(resp.Body(), &description); err != nil {
+		return nil, fmt.Errorf("invalid device description: %w", err)
+	}
+
+	base, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+	if description.URLBase != "" {
+		if urlBase, err := url.Parse(strings.TrimSpace(description.URLBase)); err == nil {
+			base = base.ResolveReference(urlBase)
+		}
+	}
+	services := wanServices(description.Device, base)
+	if len(services) == 0 {
+		return nil, fmt.Errorf("device at %s has no WAN connection service", location)
+	}
+
+	u.mu.Lock()
+	u.discoveredGateway = base.Hostname()
+	u.mu.Unlock()
+	return services, nil
+}
+
+// wanServices walks the device tree and returns its WAN connection services
+// with control URLs resolved against base.
+func wanServices(d device, base *url.URL) []igdService {
+	var services []igdService
+	for _, service := range d.Services {
+		serviceType := strings.TrimSpace(service.ServiceType)
+		for _, prefix := range wanServicePrefixes {
+			if !strings.HasPrefix(serviceType, prefix) {
+				continue
+			}
+			controlURL, err := url.Parse(strings.TrimSpace(service.ControlURL))
+			if err != nil {
+				break
+			}
+			services = append(services, igdService{serviceType: serviceType, controlURL: base.ResolveReference(controlURL).String()})
+		}
+	}
+	for _, child := range d.Devices {
+		services = append(services, wanServices(child, base)...)
+	}
+	return services
+}
+
+func (u *UPnP) getExternalIPAddress(ctx context.Context, service igdService) (netip.Addr, error) {
+	var serviceType bytes.Buffer
+	if err := xml.EscapeText(&serviceType, []byte(service.serviceType)); err != nil {
+		return netip.Addr{}, err
+	}
+	body := `<?xml version="1.0"?>` +
+		`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">` +
+		`<s:Body><u:GetExternalIPAddress xmlns:u="` + serviceType.String() + `"/></s:Body></s:Envelope>`
+
+	resp, err := u.httpClient.R().
+		SetContext(ctx).
+		SetHeader("Content-Type", `text/xml; charset="utf-8"`).
+		SetHeader("SOAPAction", `"`+service.serviceType+`#GetExternalIPAddress"`).
+		SetBody(body).
+		Post(service.controlURL)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+
+	var envelope soapEnvelope
+	parseErr := xml.Unmarshal(resp.Body(), &envelope)
+	if !resp.IsSuccess() {
+		if parseErr == nil && envelope.Body.Fault.ErrorCode != "" {
+			return netip.Addr{}, fmt.Errorf("UPnP error %s: %s", envelope.Body.Fault.ErrorCode, envelope.Body.Fault.ErrorDescription)
+		}
+		return netip.Addr{}, fmt.Errorf("GetExternalIPAddress failed: HTTP status %d", resp.StatusCode())
+	}
+	if parseErr != nil {
+		return netip.Addr{}, fmt.Errorf("invalid GetExternalIPAddress response: %w", parseErr)
+	}
+	external := strings.TrimSpace(envelope.Body.Response.ExternalIPAddress)
+	if external == "" {
+		return netip.Addr{}, fmt.Errorf("gateway has no external address")
+	}
+	addr, err := netip.ParseAddr(external)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("invalid external address: %s", external)
+	}
+	return addr, nil
+}
+
+// discoverLocation sends SSDP M-SEARCH requests for an Internet Gateway
+// Device and returns the description URL of the first one to answer.
+func discoverLocation(ctx context.Context) (string, error) {
+	target, err := net.ResolveUDPAddr("udp4", ssdpAddress)
+	if err != nil {
+		return "", err
+	}
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	for _, st := range searchTargets {
+		request := "M-SEARCH * HTTP/1.1\r\n" +
+			"HOST: " + ssdpMulticastAddress + "\r\n" +
+			"MAN: \"ssdp:discover\"\r\n" +
+			"MX: 2\r\n" +
+			"ST: " + st + "\r\n\r\n"
+		if _, err := conn.WriteToUDP([]byte(request), target); err != nil {
+			return "", err
+		}
+	}
+	if err := conn.SetReadDeadline(time.Now().Add(discoveryTimeout)); err != nil {
+		return "", err
+	}
+
+	buf := make([]byte, 2048)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() && ctx.Err() == nil {
+				return "", fmt.Errorf("no Internet Gateway Device answered SSDP discovery")
+			}
+			return "", err
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buf[:n])), nil)
+		if err != nil || resp.StatusCode != http.StatusOK {
+			continue
+		}
+		location := strings.TrimSpace(resp.Header.Get("Location"))
+		parsed, err := url.Parse(location)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			continue
+		}
+		return location, nil
+	}
+}
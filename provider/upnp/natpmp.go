@@ -0,0 +1,172 @@
+package upnp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/we11adam/uddns/internal/udpexchange"
+)
+
+const (
+	dialTimeout = 1 * time.Second
+
+	natPMPVersion          = 0
+	natPMPOpExternalAddr   = 0
+	natPMPResponseLength   = 12
+	pcpVersion             = 2
+	pcpOpMap               = 1
+	pcpResponseBit         = 0x80
+	pcpMapLength           = 60
+	pcpProtocolUDP         = 17
+	pcpMappingLifetimeSecs = 30
+)
+
+// retransmitTimeouts are how long each NAT-PMP or PCP request waits for a
+// response before it is sent again, starting at RFC 6886's 250ms and
+// doubling.
+var retransmitTimeouts = []time.Duration{250 * time.Millisecond, 500 * time.Millisecond, 1 * time.Second, 2 * time.Second}
+
+var natPMPResults = map[uint16]string{
+	1: "unsupported version",
+	2: "not authorized",
+	3: "network failure",
+	4: "out of resources",
+	5: "unsupported opcode",
+}
+
+var pcpResults = map[byte]string{
+	1:  "UNSUPP_VERSION",
+	2:  "NOT_AUTHORIZED",
+	3:  "MALFORMED_REQUEST",
+	4:  "UNSUPP_OPCODE",
+	5:  "UNSUPP_OPTION",
+	6:  "MALFORMED_OPTION",
+	7:  "NETWORK_FAILURE",
+	8:  "NO_RESOURCES",
+	9:  "UNSUPP_PROTOCOL",
+	10: "USER_EX_QUOTA",
+	11: "CANNOT_PROVIDE_EXTERNAL",
+	12: "ADDRESS_MISMATCH",
+	13: "EXCESSIVE_REMOTE_PEERS",
+}
+
+// queryNATPMP sends a NAT-PMP external address request (RFC 6886).
+func (u *UPnP) queryNATPMP(ctx context.Context) (netip.Addr, error) {
+	conn, err := u.dialPortMapper(ctx)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	defer conn.Close()
+	return exchange(ctx, conn, []byte{natPMPVersion, natPMPOpExternalAddr}, parseNATPMPResponse)
+}
+
+func parseNATPMPResponse(message []byte) (netip.Addr, error) {
+	if len(message) < 4 || message[0] != natPMPVersion || message[1] != pcpResponseBit|natPMPOpExternalAddr {
+		return netip.Addr{}, udpexchange.ErrForeignMessage
+	}
+	if result := binary.BigEndian.Uint16(message[2:4]); result != 0 {
+		return netip.Addr{}, fmt.Errorf("gateway returned result %d (%s)", result, natPMPResults[result])
+	}
+	if len(message) < natPMPResponseLength {
+		return netip.Addr{}, fmt.Errorf("truncated response")
+	}
+	return netip.AddrFrom4([4]byte(message[8:12])), nil
+}
+
+// queryPCP learns the external address from a PCP MAP response (RFC 6887).
+// PCP has no request for the address alone, so a short-lived UDP mapping is
+// requested for the query socket and deleted again afterwards.
+func (u *UPnP) queryPCP(ctx context.Context) (netip.Addr, error) {
+	conn, err := u.dialPortMapper(ctx)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	defer conn.Close()
+	var nonce [12]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return netip.Addr{}, fmt.Errorf("failed to generate PCP nonce: %w", err)
+	}
+
+	local := conn.LocalAddr().(*net.UDPAddr).AddrPort()
+	addr, err := exchange(ctx, conn, newPCPMapRequest(local, nonce, pcpMappingLifetimeSecs), func(message []byte) (netip.Addr, error) {
+		return parsePCPMapResponse(message, nonce)
+	})
+	if err == nil {
+		// Deleting the mapping is best effort; it expires on its own
+		// otherwise.
+		if _, err := conn.Write(newPCPMapRequest(local, nonce, 0)); err != nil {
+			slog.Debug("failed to delete PCP mapping", "provider", "upnp", "error", err)
+		}
+	}
+	return addr, err
+}
+
+func newPCPMapRequest(local netip.AddrPort, nonce [12]byte, lifetime uint32) []byte {
+	request := make([]byte, pcpMapLength)
+	request[0] = pcpVersion
+	request[1] = pcpOpMap
+	binary.BigEndian.PutUint32(request[4:8], lifetime)
+	client := local.Addr().As16()
+	copy(request[8:24], client[:])
+	copy(request[24:36], nonce[:])
+	request[36] = pcpProtocolUDP
+	binary.BigEndian.PutUint16(request[40:42], local.Port())
+	// Leave the suggested external port empty and suggest the IPv4 wildcard
+	// address, ::ffff:0.0.0.0.
+	request[54], request[55] = 0xff, 0xff
+	return request
+}
+
+func parsePCPMapResponse(message []byte, nonce [12]byte) (netip.Addr, error) {
+	if len(message) < 4 {
+		return netip.Addr{}, udpexchange.ErrForeignMessage
+	}
+	if message[0] != pcpVersion {
+		// A NAT-PMP-only gateway answers with its own version.
+		if message[0] == natPMPVersion {
+			return netip.Addr{}, fmt.Errorf("gateway only supports NAT-PMP")
+		}
+		return netip.Addr{}, udpexchange.ErrForeignMessage
+	}
+	if message[1] != pcpResponseBit|pcpOpMap {
+		return netip.Addr{}, udpexchange.ErrForeignMessage
+	}
+	if result := message[3]; result != 0 {
+		return netip.Addr{}, fmt.Errorf("gateway returned result %d (%s)", result, pcpResults[result])
+	}
+	if len(message) < pcpMapLength {
+		return netip.Addr{}, fmt.Errorf("truncated response")
+	}
+	if [12]byte(message[24:36]) != nonce {
+		return netip.Addr{}, udpexchange.ErrForeignMessage
+	}
+	return netip.AddrFrom16([16]byte(message[44:60])).Unmap(), nil
+}
+
+func (u *UPnP) dialPortMapper(ctx context.Context) (net.Conn, error) {
+	gateway, err := u.portMapper()
+	if err != nil {
+		return nil, err
+	}
+	dialer := net.Dialer{Timeout: dialTimeout}
+	return dialer.DialContext(ctx, "udp4", gateway)
+}
+
+// exchange sends request over conn, retransmitting while no response
+// arrives, and returns the address from the first response that parse
+// accepts.
+func exchange(ctx context.Context, conn net.Conn, request []byte, parse func([]byte) (netip.Addr, error)) (netip.Addr, error) {
+	var addr netip.Addr
+	_, err := udpexchange.Exchange(ctx, conn, request, retransmitTimeouts, func(message []byte) error {
+		var err error
+		addr, err = parse(message)
+		return err
+	})
+	return addr, err
+}
@@ -0,0 +1,210 @@
+package upnp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+
+	"github.com/we11adam/uddns/internal/udpexchange"
+	"github.com/we11adam/uddns/provider"
+)
+
+const (
+	requestTimeout    = 5 * time.Second
+	responseBodyLimit = 1 << 20
+	portMapperPort    = "5351"
+)
+
+// DefaultProtocols are tried in order when no protocols are configured.
+var DefaultProtocols = []string{"upnp", "natpmp", "pcp"}
+
+type Config struct {
+	// Protocols are upnp, natpmp, and pcp, tried in order until one returns a
+	// public address. Defaults to all three.
+	Protocols []string `mapstructure:"protocols"`
+	// Location is the gateway's device description URL. SSDP discovery is
+	// skipped when it is set.
+	Location string `mapstructure:"location"`
+	// Gateway is the NAT-PMP and PCP server as host or host:port; the port
+	// defaults to 5351. Defaults to the device found through SSDP, then the
+	// default route's gateway.
+	Gateway string `mapstructure:"gateway"`
+}
+
+type UPnP struct {
+	protocols  []string
+	location   string
+	gateway    string
+	httpClient *resty.Client
+
+	mu sync.Mutex
+	// services caches the WAN connection services of the discovered gateway
+	// device until a request to them fails.
+	services []igdService
+	// discoveredGateway is the host of the last gateway device description.
+	discoveredGateway string
+}
+
+func init() {
+	provider.Register("UPnP", "providers.upnp", func(v provider.ConfigReader) (provider.Provider, error) {
+		if !v.IsSet("providers.upnp") {
+			return nil, provider.ErrNotConfigured
+		}
+
+		cfg := Config{}
+		err := v.UnmarshalKey("providers.upnp", &cfg)
+		if err != nil {
+			return nil, err
+		}
+		return New(&cfg)
+	})
+}
+
+func New(cfg *Config) (*UPnP, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("UPnP config is nil")
+	}
+
+	configured := cfg.Protocols
+	if len(configured) == 0 {
+		configured = DefaultProtocols
+	}
+	protocols := make([]string, 0, len(configured))
+	for _, protocol := range configured {
+		protocol = strings.ToLower(strings.TrimSpace(protocol))
+		switch protocol {
+		case "upnp", "natpmp", "pcp":
+			protocols = append(protocols, protocol)
+		default:
+			return nil, fmt.Errorf("unsupported UPnP provider protocol %q; supported protocols: upnp, natpmp, pcp", protocol)
+		}
+	}
+
+	location := strings.TrimSpace(cfg.Location)
+	if location != "" {
+		parsed, err := url.Parse(location)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return nil, fmt.Errorf("invalid UPnP location: %s", location)
+		}
+	}
+
+	var gateway string
+	if strings.TrimSpace(cfg.Gateway) != "" {
+		var err error
+		if gateway, err = gatewayAddress(cfg.Gateway); err != nil {
+			return nil, err
+		}
+	}
+
+	httpClient := resty.New().
+		SetTimeout(requestTimeout).
+		SetResponseBodyLimit(responseBodyLimit).
+		RemoveProxy()
+	return &UPnP{
+		protocols:  protocols,
+		location:   location,
+		gateway:    gateway,
+		httpClient: httpClient,
+	}, nil
+}
+
+// gatewayAddress normalizes a NAT-PMP or PCP server to host:port.
+func gatewayAddress(gateway string) (string, error) {
+	address, err := udpexchange.HostPort(gateway, portMapperPort)
+	if err != nil {
+		return "", fmt.Errorf("invalid UPnP gateway: %s", strings.TrimSpace(gateway))
+	}
+	return address, nil
+}
+
+// GetIPs asks the gateway for its external address. The protocols only report
+// IPv4, so an IPv6 request is left unanswered.
+func (u *UPnP) GetIPs(ctx context.Context, families provider.FamilyRequest) (*provider.IpResult, error) {
+	if !families.IPv4 && !families.IPv6 {
+		return nil, fmt.Errorf("no IP families requested")
+	}
+	if !families.IPv4 {
+		return nil, fmt.Errorf("UPnP provider only reports IPv4 addresses")
+	}
+
+	var failures []error
+	for _, protocol := range u.protocols {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		slog.Debug("requesting external address", "provider", "upnp", "protocol", protocol)
+		var addr netip.Addr
+		var err error
+		switch protocol {
+		case "upnp":
+			addr, err = u.queryIGD(ctx)
+		case "natpmp":
+			addr, err = u.queryNATPMP(ctx)
+		case "pcp":
+			addr, err = u.queryPCP(ctx)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			slog.Debug("external address request failed", "provider", "upnp", "protocol", protocol, "error", err)
+			failures = append(failures, fmt.Errorf("%s failed: %w", protocolName(protocol), err))
+			continue
+		}
+		addr = addr.Unmap()
+		if !addr.Is4() || !provider.IsPublicRoutable(addr) {
+			slog.Debug("ignoring non-public external address", "provider", "upnp", "protocol", protocol, "ip", addr)
+			failures = append(failures, fmt.Errorf("%s returned a non-public address", protocolName(protocol)))
+			continue
+		}
+		slog.Debug("got IP address", "provider", "upnp", "protocol", protocol, "family", "ipv4", "ip", addr)
+		return &provider.IpResult{IPv4: addr.String()}, nil
+	}
+	return nil, errors.Join(failures...)
+}
+
+func protocolName(protocol string) string {
+	switch protocol {
+	case "upnp":
+		return "UPnP IGD"
+	case "natpmp":
+		return "NAT-PMP"
+	default:
+		return "PCP"
+	}
+}
+
+// portMapper returns the NAT-PMP and PCP server address: the configured
+// gateway, the host of the discovered gateway device, or the default route's
+// gateway.
+func (u *UPnP) portMapper() (string, error) {
+	if u.gateway != "" {
+		return u.gateway, nil
+	}
+	u.mu.Lock()
+	discovered := u.discoveredGateway
+	u.mu.Unlock()
+	if discovered != "" {
+		return net.JoinHostPort(discovered, portMapperPort), nil
+	}
+	if u.location != "" {
+		if parsed, err := url.Parse(u.location); err == nil {
+			return net.JoinHostPort(parsed.Hostname(), portMapperPort), nil
+		}
+	}
+	gateway, err := defaultGateway()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the default gateway, set gateway explicitly: %w", err)
+	}
+	return net.JoinHostPort(gateway.String(), portMapperPort), nil
+}
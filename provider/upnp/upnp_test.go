@@ -0,0 +1,377 @@
+package upnp
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/we11adam/uddns/internal/udpexchange"
+	"github.com/we11adam/uddns/provider"
+)
+
+const descriptionXML = `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+  <device>
+    <deviceType>urn:schemas-upnp-org:device:InternetGatewayDevice:1</deviceType>
+    <deviceList>
+      <device>
+        <deviceType>urn:schemas-upnp-org:device:WANDevice:1</deviceType>
+        <deviceList>
+          <device>
+            <deviceType>urn:schemas-upnp-org:device:WANConnectionDevice:1</deviceType>
+            <serviceList>
+              <service>
+                <serviceType>urn:schemas-upnp-org:service:WANPPPConnection:1</serviceType>
+                <controlURL>/ctl/PPPConn</controlURL>
+              </service>
+              <service>
+                <serviceType>urn:schemas-upnp-org:service:WANIPConnection:1</serviceType>
+                <controlURL>ctl/IPConn</controlURL>
+              </service>
+            </serviceList>
+          </device>
+        </deviceList>
+      </device>
+    </deviceList>
+  </device>
+</root>`
+
+// gatewayDevice is a local IGD whose PPP connection is down and whose IP
+// connection reports external.
+type gatewayDevice struct {
+	server        *httptest.Server
+	external      atomic.Value
+	descriptions  atomic.Int32
+	lastSOAPError atomic.Value
+}
+
+func newGatewayDevice(t *testing.T, external string) *gatewayDevice {
+	t.Helper()
+	g := &gatewayDevice{}
+	g.external.Store(external)
+	g.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rootDesc.xml":
+			g.descriptions.Add(1)
+			_, _ = io.WriteString(w, descriptionXML)
+		case "/ctl/PPPConn":
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = io.WriteString(w, `<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><s:Fault><faultcode>s:Client</faultcode><faultstring>UPnPError</faultstring><detail><UPnPError xmlns="urn:schemas-upnp-org:control-1-0"><errorCode>714</errorCode><errorDescription>NoSuchEntryInArray</errorDescription></UPnPError></detail></s:Fault></s:Body></s:Envelope>`)
+		case "/ctl/IPConn":
+			if action := r.Header.Get("SOAPAction"); action != `"urn:schemas-upnp-org:service:WANIPConnection:1#GetExternalIPAddress"` {
+				g.lastSOAPError.Store("unexpected SOAPAction " + action)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_, _ = fmt.Fprintf(w, `<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><u:GetExternalIPAddressResponse xmlns:u="urn:schemas-upnp-org:service:WANIPConnection:1"><NewExternalIPAddress>%s</NewExternalIPAddress></u:GetExternalIPAddressResponse></s:Body></s:Envelope>`, g.external.Load())
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(g.server.Close)
+	return g
+}
+
+func (g *gatewayDevice) location() string {
+	return g.server.URL + "/rootDesc.xml"
+}
+
+// announce answers SSDP searches on a local socket with the device location
+// and points discovery at it.
+func (g *gatewayDevice) announce(t *testing.T) *atomic.Int32 {
+	t.Helper()
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	searches := &atomic.Int32{}
+	go func() {
+		buf := make([]byte, 2048)
+		for {
+			n, client, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			request := string(buf[:n])
+			if !strings.HasPrefix(request, "M-SEARCH * HTTP/1.1\r\n") || !strings.Contains(request, "ST: urn:schemas-upnp-org:device:InternetGatewayDevice:1\r\n") {
+				continue
+			}
+			searches.Add(1)
+			_, _ = conn.WriteTo([]byte("NOTIFY * HTTP/1.1\r\n\r\n"), client)
+			response := "HTTP/1.1 200 OK\r\nCACHE-CONTROL: max-age=120\r\nST: urn:schemas-upnp-org:device:InternetGatewayDevice:1\r\nLOCATION: " + g.location() + "\r\n\r\n"
+			_, _ = conn.WriteTo([]byte(response), client)
+		}
+	}()
+
+	previous := ssdpAddress
+	ssdpAddress = conn.LocalAddr().String()
+	t.Cleanup(func() { ssdpAddress = previous })
+	return searches
+}
+
+// portMapper is a local NAT-PMP and PCP server.
+type portMapper struct {
+	conn     net.PacketConn
+	external netip.Addr
+	// natPMP and pcp are the protocols the server speaks. A NAT-PMP-only
+	// server rejects PCP requests with its own version; a PCP-only server
+	// ignores NAT-PMP requests.
+	natPMP, pcp bool
+	drop        atomic.Int32
+	requests    atomic.Int32
+	deletions   atomic.Int32
+}
+
+func newPortMapper(t *testing.T, external string, natPMP, pcp bool) *portMapper {
+	t.Helper()
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &portMapper{conn: conn, external: netip.MustParseAddr(external), natPMP: natPMP, pcp: pcp}
+	t.Cleanup(func() { _ = conn.Close() })
+	go m.serve()
+	return m
+}
+
+func (m *portMapper) addr() string {
+	return m.conn.LocalAddr().String()
+}
+
+func (m *portMapper) serve() {
+	buf := make([]byte, udpexchange.MaxDatagramSize)
+	for {
+		n, client, err := m.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		request := buf[:n]
+		switch {
+		case n == 2 && request[0] == natPMPVersion && request[1] == natPMPOpExternalAddr:
+			m.requests.Add(1)
+			if !m.natPMP || m.drop.Add(-1) >= 0 {
+				continue
+			}
+			response := make([]byte, natPMPResponseLength)
+			response[1] = pcpResponseBit | natPMPOpExternalAddr
+			binary.BigEndian.PutUint32(response[4:8], 3600)
+			copy(response[8:12], m.external.AsSlice())
+			_, _ = m.conn.WriteTo(response, client)
+		case n == pcpMapLength && request[0] == pcpVersion && request[1] == pcpOpMap:
+			if binary.BigEndian.Uint32(request[4:8]) == 0 {
+				m.deletions.Add(1)
+				continue
+			}
+			m.requests.Add(1)
+			if !m.pcp {
+				_, _ = m.conn.WriteTo([]byte{natPMPVersion, pcpResponseBit | pcpOpMap, 0, 1, 0, 0, 0, 0}, client)
+				continue
+			}
+			// A response to another request must be ignored.
+			stray := make([]byte, pcpMapLength)
+			copy(stray, request)
+			stray[1] |= pcpResponseBit
+			stray[24] ^= 0xff
+			_, _ = m.conn.WriteTo(stray, client)
+
+			response := make([]byte, pcpMapLength)
+			copy(response, request)
+			response[1] = pcpResponseBit | pcpOpMap
+			response[2], response[3] = 0, 0
+			clear(response[8:24])
+			binary.BigEndian.PutUint16(response[42:44], 40000)
+			external := m.external.As16()
+			copy(response[44:60], external[:])
+			_, _ = m.conn.WriteTo(response, client)
+		}
+	}
+}
+
+func useShortTimeouts(t *testing.T) {
+	t.Helper()
+	previousRetransmits, previousDiscovery := retransmitTimeouts, discoveryTimeout
+	retransmitTimeouts = []time.Duration{50 * time.Millisecond, 100 * time.Millisecond}
+	discoveryTimeout = 200 * time.Millisecond
+	t.Cleanup(func() {
+		retransmitTimeouts, discoveryTimeout = previousRetransmits, previousDiscovery
+	})
+}
+
+func TestGetIPsDiscoversGatewayWithSSDP(t *testing.T) {
+	device := newGatewayDevice(t, "8.8.8.8")
+	searches := device.announce(t)
+	upnp, err := New(&Config{Protocols: []string{"upnp"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := upnp.GetIPs(context.Background(), provider.FamilyRequest{IPv4: true, IPv6: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IPv4 != "8.8.8.8" || result.IPv6 != "" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if message, ok := device.lastSOAPError.Load().(string); ok {
+		t.Fatal(message)
+	}
+
+	// The control URL is cached, so a PPPoE reconnect is seen on the next run
+	// without discovering again.
+	device.external.Store("8.8.4.4")
+	result, err = upnp.GetIPs(context.Background(), provider.FamilyRequest{IPv4: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IPv4 != "8.8.4.4" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if searches.Load() != 1 || device.descriptions.Load() != 1 {
+		t.Fatalf("expected one discovery, got %d searches and %d descriptions", searches.Load(), device.descriptions.Load())
+	}
+	if got := upnp.discoveredGateway; got != "127.0.0.1" {
+		t.Fatalf("discovered gateway = %q, want 127.0.0.1", got)
+	}
+}
+
+func TestGetIPsFallsBackToNATPMPAndPCP(t *testing.T) {
+	useShortTimeouts(t)
+	device := newGatewayDevice(t, "10.0.0.2")
+
+	natPMP := newPortMapper(t, "8.8.8.8", true, true)
+	natPMP.drop.Store(1)
+	upnp, err := New(&Config{Location: device.location(), Gateway: natPMP.addr()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := upnp.GetIPs(context.Background(), provider.FamilyRequest{IPv4: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IPv4 != "8.8.8.8" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if got := natPMP.requests.Load(); got != 2 {
+		t.Fatalf("expected one NAT-PMP retransmission, got %d requests", got)
+	}
+
+	pcp := newPortMapper(t, "8.8.4.4", false, true)
+	upnp, err = New(&Config{Protocols: []string{"natpmp", "pcp"}, Gateway: pcp.addr()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err = upnp.GetIPs(context.Background(), provider.FamilyRequest{IPv4: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IPv4 != "8.8.4.4" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+}
+
+func TestGetIPsUsesPCP(t *testing.T) {
+	mapper := newPortMapper(t, "8.8.4.4", true, true)
+	upnp, err := New(&Config{Protocols: []string{"pcp"}, Gateway: mapper.addr()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := upnp.GetIPs(context.Background(), provider.FamilyRequest{IPv4: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IPv4 != "8.8.4.4" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	deadline := time.Now().Add(time.Second)
+	for mapper.deletions.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if mapper.deletions.Load() != 1 {
+		t.Fatal("expected the PCP mapping to be deleted")
+	}
+}
+
+func TestGetIPsReportsEveryFailure(t *testing.T) {
+	useShortTimeouts(t)
+	device := newGatewayDevice(t, "192.168.1.2")
+	mapper := newPortMapper(t, "100.64.0.1", true, false)
+	upnp, err := New(&Config{Location: device.location(), Gateway: mapper.addr()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = upnp.GetIPs(context.Background(), provider.FamilyRequest{IPv4: true})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{"UPnP IGD returned a non-public address", "NAT-PMP returned a non-public address", "PCP failed: gateway only supports NAT-PMP"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected error to contain %q, got %v", want, err)
+		}
+	}
+}
+
+func TestGetIPsReportsUPnPErrors(t *testing.T) {
+	useShortTimeouts(t)
+	device := newGatewayDevice(t, "")
+	upnp, err := New(&Config{Protocols: []string{"upnp"}, Location: device.location()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = upnp.GetIPs(context.Background(), provider.FamilyRequest{IPv4: true})
+	if err == nil || !strings.Contains(err.Error(), "UPnP error 714: NoSuchEntryInArray") || !strings.Contains(err.Error(), "no external address") {
+		t.Fatalf("expected both services to fail, got %v", err)
+	}
+
+	upnp, err = New(&Config{Protocols: []string{"upnp"}, Location: device.server.URL + "/missing.xml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = upnp.GetIPs(context.Background(), provider.FamilyRequest{IPv4: true}); err == nil || !strings.Contains(err.Error(), "HTTP status 404") {
+		t.Fatalf("expected the description request to fail, got %v", err)
+	}
+}
+
+func TestGetIPsRejectsIPv6OnlyRequests(t *testing.T) {
+	upnp, err := New(&Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := upnp.GetIPs(context.Background(), provider.FamilyRequest{IPv6: true}); err == nil || !strings.Contains(err.Error(), "only reports IPv4") {
+		t.Fatalf("expected IPv6-only requests to be rejected, got %v", err)
+	}
+}
+
+func TestNewValidatesConfig(t *testing.T) {
+	upnp, err := New(&Config{Protocols: []string{"PCP", " natpmp "}, Gateway: "192.168.1.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(upnp.protocols, ",") != "pcp,natpmp" || upnp.gateway != "192.168.1.1:5351" {
+		t.Fatalf("unexpected provider: %+v", upnp)
+	}
+
+	for _, cfg := range []*Config{
+		nil,
+		{Protocols: []string{"upnp", "ssdp"}},
+		{Location: "ftp://192.168.1.1/rootDesc.xml"},
+		{Location: "rootDesc.xml"},
+		{Gateway: "192.168.1.1/24"},
+	} {
+		if _, err := New(cfg); err == nil {
+			t.Fatalf("expected %+v to be rejected", cfg)
+		}
+	}
+}
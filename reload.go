@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/we11adam/uddns/app"
+	"github.com/we11adam/uddns/internal/config"
+)
+
+// configPollInterval is how often watch_config checks the config file. Polling
+// the content, rather than watching the directory, also follows editors that
+// replace the file and symlinked files such as Kubernetes config maps.
+var configPollInterval = 5 * time.Second
+
+// watchReloads reloads the configuration on SIGHUP and, with watch_config,
+// whenever the config file content changes, until ctx is done.
+func watchReloads(ctx context.Context, a *app.App, rt *runtimeConfig) {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	defer signal.Stop(hangups)
+
+	var changes <-chan struct{}
+	if rt.watchConfig {
+		slog.Info("watching config file for changes", "config", rt.configPath, "poll_interval", configPollInterval)
+		changes = watchConfigFile(ctx, rt.configPath, configPollInterval)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangups:
+			slog.Info("received SIGHUP; reloading configuration", "config", rt.configPath)
+		case <-changes:
+			slog.Info("config file changed; reloading configuration", "config", rt.configPath)
+		}
+		rt = reloadRuntime(ctx, a, rt)
+	}
+}
+
+// reloadRuntime loads and fully validates the config file again and hands the
+// result to the running app. It returns the runtime now in effect, which is
+// current when the new configuration is invalid.
+func reloadRuntime(ctx context.Context, a *app.App, current *runtimeConfig) *runtimeConfig {
+	cfg, err := config.Load(current.configPath)
+	if err != nil {
+		slog.Error("failed to reload config file; keeping current configuration", "error", err)
+		return current
+	}
+	next, err := buildRuntime(cfg)
+	if err != nil {
+		slog.Error("invalid configuration; keeping current configuration", "error", err)
+		return current
+	}
+
+	// These settings are bound when the process starts.
+	next.dryRun = current.dryRun
+	if next.httpListen != current.httpListen {
+		slog.Warn("http.listen changes take effect after a restart", "current", current.httpListen, "configured", next.httpListen)
+		next.httpListen = current.httpListen
+	}
+	if stateFileLogValue(next.stateStore) != stateFileLogValue(current.stateStore) {
+		slog.Warn("state_dir changes take effect after a restart", "current", stateFileLogValue(current.stateStore), "configured", stateFileLogValue(next.stateStore))
+		next.stateStore = current.stateStore
+	}
+	if next.watchConfig != current.watchConfig {
+		slog.Warn("watch_config changes take effect after a restart", "current", current.watchConfig, "configured", next.watchConfig)
+		next.watchConfig = current.watchConfig
+	}
+
+	if err := a.Reload(ctx, newApp(next)); err != nil {
+		closeNotifiers(next.notifiers)
+		return current
+	}
+	configureLoggerFromConfig(cfg)
+	return next
+}
+
+// watchConfigFile polls path and signals on the returned channel when its
+// content changes. Read errors, such as a file that is briefly missing while
+// an editor replaces it, are ignored until the file can be read again.
+func watchConfigFile(ctx context.Context, path string, interval time.Duration) <-chan struct{} {
+	changes := make(chan struct{}, 1)
+	last, _ := configFileDigest(path)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			digest, err := configFileDigest(path)
+			if err != nil || bytes.Equal(digest, last) {
+				continue
+			}
+			last = digest
+			select {
+			case changes <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return changes
+}
+
+func configFileDigest(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(content)
+	return digest[:], nil
+}
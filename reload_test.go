@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/we11adam/uddns/app"
+)
+
+func TestReloadRuntimeSwapsValidConfigAndKeepsCurrentOnError(t *testing.T) {
+	t.Setenv("UDDNS_INTERVAL", "")
+	path := writeRunOnceConfig(t)
+	rt, err := loadRuntime(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := newApp(rt)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		a.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated := strings.Replace(string(content), "record: office", "record: office\n  - name: lab\n    provider: netif\n    updater: duckdns\n    record: lab", 1)
+	if err := os.WriteFile(path, []byte(updated), 0600); err != nil {
+		t.Fatal(err)
+	}
+	next := reloadRuntime(ctx, a, rt)
+	if next == rt || len(next.jobs) != 3 {
+		t.Fatalf("expected the valid config to be applied, got %d jobs", len(next.jobs))
+	}
+	waitForJobs(t, a, "home", "office", "lab")
+
+	if err := os.WriteFile(path, []byte(updated+"max_concurrency: 0\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if current := reloadRuntime(ctx, a, next); current != next {
+		t.Fatal("expected an invalid config to keep the current runtime")
+	}
+	waitForJobs(t, a, "home", "office", "lab")
+}
+
+func TestReloadRuntimeAppliesLoggingSettings(t *testing.T) {
+	t.Setenv("UDDNS_INTERVAL", "")
+	t.Setenv("UDDNS_LOG_LEVEL", "")
+	t.Cleanup(configureLogger)
+	path := writeRunOnceConfig(t)
+	rt, err := loadRuntime(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := newApp(rt)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		a.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	if slog.Default().Enabled(ctx, slog.LevelDebug) {
+		t.Fatal("expected debug logging to be off before the reload")
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, append(content, "logging:\n  level: debug\n"...), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if next := reloadRuntime(ctx, a, rt); next == rt {
+		t.Fatal("expected the valid config to be applied")
+	}
+	if !slog.Default().Enabled(ctx, slog.LevelDebug) {
+		t.Fatal("expected the reloaded log level to take effect")
+	}
+}
+
+func TestWatchConfigFileSignalsContentChanges(t *testing.T) {
+	path := writeTempConfig(t, "interval: 30s\n")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := watchConfigFile(ctx, path, 10*time.Millisecond)
+
+	select {
+	case <-changes:
+		t.Fatal("expected no change before the file is written")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if err := os.WriteFile(path, []byte("interval: 1m\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changes:
+	case <-time.After(time.Second):
+		t.Fatal("expected a change after the file content changed")
+	}
+}
+
+func waitForJobs(t *testing.T, a *app.App, names ...string) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		var current []string
+		for _, status := range a.Status() {
+			current = append(current, status.Name)
+		}
+		if slices.Equal(current, names) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected jobs %v, got %v", names, current)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/we11adam/uddns/app"
+)
+
+const dryRunUsage = "Log planned DNS updates and notifications without sending them"
+
+type runSummary struct {
+	Success bool               `json:"success"`
+	DryRun  bool               `json:"dry_run"`
+	Jobs    []app.JobStatus    `json:"jobs"`
+	Changes []app.RecordChange `json:"changes"`
+}
+
+// jobNames collects repeated --job flags.
+type jobNames []string
+
+func (n *jobNames) String() string {
+	return strings.Join(*n, ",")
+}
+
+func (n *jobNames) Set(value string) error {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return errors.New("job name must not be empty")
+	}
+	*n = append(*n, value)
+	return nil
+}
+
+func runRunCommand(args []string, stdout, stderr io.Writer) int {
+	var configPath string
+	var jobs jobNames
+	flags := flag.NewFlagSet("uddns run", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.StringVar(&configPath, "c", "", "Path to the configuration file")
+	once := flags.Bool("once", false, "Run every job one cycle and exit")
+	flags.Var(&jobs, "job", "Run only the named job with --once; repeatable")
+	jsonOutput := flags.Bool("json", false, "Print a JSON summary of each job with --once")
+	dryRun := flags.Bool("dry-run", false, dryRunUsage)
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if flags.NArg() != 0 {
+		fmt.Fprintf(stderr, "uddns run: unexpected argument %q\n", flags.Arg(0))
+		return 2
+	}
+	if !*once && (len(jobs) > 0 || *jsonOutput) {
+		fmt.Fprintln(stderr, "uddns run: --job and --json require --once")
+		return 2
+	}
+
+	if *jsonOutput {
+		consoleLogOutput = os.Stderr
+	}
+	configureLogger()
+	rt, err := loadRuntime(configPath)
+	if err != nil {
+		slog.Error("failed to validate config", "error", err)
+		return 1
+	}
+	rt.dryRun = *dryRun
+	if !*once {
+		return runDaemon(rt)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	statuses, err := newApp(rt).RunOnce(ctx, jobs...)
+	interrupted := ctx.Err() != nil
+	if err != nil && !interrupted {
+		slog.Error("failed to run jobs", "error", err)
+		return 2
+	}
+	// Jobs that never ran keep their restored status, so an interrupted run
+	// fails even when none of them reports an error.
+	if interrupted {
+		slog.Error("failed to run jobs", "error", err)
+	}
+	summary := runSummary{Success: !interrupted, DryRun: rt.dryRun, Jobs: statuses, Changes: []app.RecordChange{}}
+	failed := 0
+	for _, status := range statuses {
+		if status.Failed() {
+			summary.Success = false
+			failed++
+		}
+		summary.Changes = append(summary.Changes, status.PlannedChanges...)
+	}
+	slog.Info("run finished", "jobs", len(statuses), "failed", failed)
+
+	if *jsonOutput {
+		if err := json.NewEncoder(stdout).Encode(summary); err != nil {
+			fmt.Fprintf(stderr, "uddns run: %v\n", err)
+			return 1
+		}
+	}
+	if !summary.Success {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"os"
+	"strings"
+	"testing"
+)
+
+// loopbackInterfaceName returns an interface that exists on the test host but
+// never has a publishable address, so the netif provider fails offline.
+func loopbackInterfaceName(t *testing.T) string {
+	t.Helper()
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		t.Fatalf("list interfaces: %v", err)
+	}
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			return iface.Name
+		}
+	}
+	t.Skip("no loopback interface")
+	return ""
+}
+
+func writeRunOnceConfig(t *testing.T) string {
+	t.Helper()
+	return writeTempConfig(t, `
+providers:
+  netif:
+    name: `+loopbackInterfaceName(t)+`
+updaters:
+  duckdns:
+    token: test-token
+jobs:
+  - name: home
+    provider: netif
+    updater: duckdns
+    record: home
+  - name: office
+    provider: netif
+    updater: duckdns
+    record: office
+`)
+}
+
+func runCommandForTest(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+	t.Setenv("UDDNS_INTERVAL", "")
+	t.Cleanup(func() {
+		consoleLogOutput = os.Stdout
+	})
+	var stdout, stderr bytes.Buffer
+	code := runWithIO(args, &stdout, &stderr, commandDependencies{})
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRunOncePrintsJSONSummaryAndFailsOnJobError(t *testing.T) {
+	path := writeRunOnceConfig(t)
+
+	code, stdout, stderr := runCommandForTest(t, "run", "--once", "--json", "--job", "office", "-c", path)
+
+	if code != 1 {
+		t.Fatalf("expected provider failure to exit 1, got %d: %s", code, stderr)
+	}
+	var summary runSummary
+	if err := json.Unmarshal([]byte(stdout), &summary); err != nil {
+		t.Fatalf("run output is not pure JSON: %q: %v", stdout, err)
+	}
+	if summary.Success || len(summary.Jobs) != 1 {
+		t.Fatalf("expected one failed job, got %#v", summary)
+	}
+	job := summary.Jobs[0]
+	if job.Name != "office" || job.LastStatus != "provider_error" || job.LastError == "" {
+		t.Fatalf("unexpected job summary: %#v", job)
+	}
+}
+
+func TestRunOnceRejectsUnknownJob(t *testing.T) {
+	path := writeRunOnceConfig(t)
+
+	code, _, _ := runCommandForTest(t, "run", "--once", "--job", "missing", "-c", path)
+
+	if code != 2 {
+		t.Fatalf("expected unknown job to exit 2, got %d", code)
+	}
+}
+
+func TestRunCommandRejectsInvalidArguments(t *testing.T) {
+	tests := [][]string{
+		{"run", "--json"},
+		{"run", "--job", "home"},
+		{"run", "--once", "extra"},
+		{"run", "--once", "--job", ""},
+	}
+	for _, args := range tests {
+		t.Run(strings.Join(args, " "), func(t *testing.T) {
+			code, _, _ := runCommandForTest(t, args...)
+			if code != 2 {
+				t.Fatalf("expected usage error, got %d", code)
+			}
+		})
+	}
+}
+
+func TestRunOnceDryRunReportsModeInJSON(t *testing.T) {
+	path := writeRunOnceConfig(t)
+
+	_, stdout, stderr := runCommandForTest(t, "run", "--once", "--dry-run", "--json", "-c", path)
+
+	var summary runSummary
+	if err := json.Unmarshal([]byte(stdout), &summary); err != nil {
+		t.Fatalf("run output is not pure JSON: %q: %v", stdout, err)
+	}
+	if !summary.DryRun || summary.Changes == nil || len(summary.Jobs) != 2 {
+		t.Fatalf("unexpected dry-run summary: %#v (stderr %s)", summary, stderr)
+	}
+	if !strings.Contains(stdout, `"changes":[]`) {
+		t.Fatalf("expected an empty changes list, got %s", stdout)
+	}
+}
@@ -460,6 +460,8 @@ for expected_line in \
 	'CapabilityBoundingSet=' \
 	'RestrictAddressFamilies=AF_UNIX AF_INET AF_INET6' \
 	'LogsDirectory=uddns' \
+	'Environment="UDDNS_STATE_DIR=%S/uddns"' \
+	'StateDirectory=uddns' \
 	'UMask=0077'; do
 	if ! grep -Fqx "$expected_line" "$unit_file"; then
 		printf 'systemd unit missing expected line: %s\n' "$expected_line" >&2
@@ -0,0 +1,491 @@
+package rfc2136
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/we11adam/uddns/internal/dnsname"
+	"github.com/we11adam/uddns/provider"
+	"github.com/we11adam/uddns/updater"
+)
+
+const (
+	defaultPort    = "53"
+	defaultTTL     = 60
+	requestTimeout = 10 * time.Second
+	maxMessageSize = 65535
+
+	opcodeUpdate = dnsmessage.OpCode(5)
+)
+
+type Config struct {
+	// Server is the primary name server as host or host:port. Port defaults to 53.
+	Server string `mapstructure:"server"`
+	// Transport is udp or tcp. UDP responses with the TC bit retry over TCP.
+	Transport string `mapstructure:"transport"`
+	Domain    string `mapstructure:"domain"`
+	// Optional zone name. If not provided, it is inferred from the Public Suffix List.
+	Zone string `mapstructure:"zone"`
+	// Optional TTL for the DNS record. If not provided, the default TTL (60) will be used.
+	TTL *int `mapstructure:"ttl"`
+
+	TSIGKey       string `mapstructure:"tsig_key"`
+	TSIGAlgorithm string `mapstructure:"tsig_algorithm"`
+	TSIGSecret    string `mapstructure:"tsig_secret"`
+}
+
+type RFC2136 struct {
+	config    Config
+	server    string
+	transport string
+	zone      dnsmessage.Name
+	record    dnsmessage.Name
+	ttl       uint32
+	key       *tsigKey
+	dialer    *net.Dialer
+	now       func() time.Time
+}
+
+func init() {
+	updater.Register("RFC2136", "updaters.rfc2136", func(v updater.ConfigReader) (updater.Updater, error) {
+		if !v.IsSet("updaters.rfc2136") {
+			return nil, updater.ErrNotConfigured
+		}
+
+		cfg := Config{}
+		err := v.UnmarshalKey("updaters.rfc2136", &cfg)
+		if err != nil {
+			return nil, err
+		}
+		return New(&cfg)
+	})
+}
+
+func New(cfg *Config) (*RFC2136, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("RFC2136 config is nil")
+	}
+	if cfg.Server == "" || cfg.Domain == "" {
+		return nil, fmt.Errorf("missing required RFC2136 fields")
+	}
+
+	normalizedConfig := *cfg
+	server, err := serverAddress(cfg.Server)
+	if err != nil {
+		return nil, err
+	}
+	transport := strings.ToLower(strings.TrimSpace(cfg.Transport))
+	switch transport {
+	case "":
+		transport = "udp"
+	case "udp", "tcp":
+	default:
+		return nil, fmt.Errorf("unsupported RFC2136 transport %q; supported values: udp, tcp", cfg.Transport)
+	}
+	normalizedConfig.Transport = transport
+
+	normalizedConfig.Domain, err = dnsname.Normalize(cfg.Domain)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RFC2136 domain: %w", err)
+	}
+	if cfg.Zone != "" {
+		normalizedConfig.Zone, err = dnsname.Normalize(cfg.Zone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RFC2136 zone: %w", err)
+		}
+	}
+	normalizedConfig.Zone, _, err = dnsname.SplitRecord(normalizedConfig.Domain, normalizedConfig.Zone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RFC2136 DNS record: %w", err)
+	}
+
+	ttl := defaultTTL
+	if cfg.TTL != nil {
+		ttl = *cfg.TTL
+	}
+	if ttl < 0 || int64(ttl) > int64(^uint32(0)>>1) {
+		return nil, fmt.Errorf("invalid TTL value: %d", ttl)
+	}
+	normalizedConfig.TTL = &ttl
+
+	var key *tsigKey
+	if cfg.TSIGKey != "" || cfg.TSIGSecret != "" {
+		if cfg.TSIGKey == "" || cfg.TSIGSecret == "" {
+			return nil, fmt.Errorf("RFC2136 TSIG requires both tsig_key and tsig_secret")
+		}
+		key, err = newTSIGKey(cfg.TSIGKey, cfg.TSIGAlgorithm, cfg.TSIGSecret)
+		if err != nil {
+			return nil, err
+		}
+		normalizedConfig.TSIGAlgorithm = strings.TrimSuffix(key.algorithm, ".")
+	}
+
+	zone, err := dnsmessage.NewName(normalizedConfig.Zone + ".")
+	if err != nil {
+		return nil, fmt.Errorf("invalid RFC2136 zone: %w", err)
+	}
+	record, err := dnsmessage.NewName(normalizedConfig.Domain + ".")
+	if err != nil {
+		return nil, fmt.Errorf("invalid RFC2136 domain: %w", err)
+	}
+
+	return &RFC2136{
+		config:    normalizedConfig,
+		server:    server,
+		transport: transport,
+		zone:      zone,
+		record:    record,
+		ttl:       uint32(ttl),
+		key:       key,
+		dialer:    &net.Dialer{},
+		now:       time.Now,
+	}, nil
+}
+
+func serverAddress(server string) (string, error) {
+	server = strings.TrimSpace(server)
+	if host, port, err := net.SplitHostPort(server); err == nil {
+		if host == "" || port == "" {
+			return "", fmt.Errorf("invalid RFC2136 server: %s", server)
+		}
+		return server, nil
+	}
+	host := strings.TrimSuffix(strings.TrimPrefix(server, "["), "]")
+	if host == "" || strings.ContainsAny(host, "[]/ ") {
+		return "", fmt.Errorf("invalid RFC2136 server: %s", server)
+	}
+	return net.JoinHostPort(host, defaultPort), nil
+}
+
+// Update replaces the A and AAAA RRsets of the record in a single UPDATE
+// message, so both families change atomically on the server.
+func (r *RFC2136) Update(ctx context.Context, ips *provider.IpResult) error {
+	if ips == nil {
+		return fmt.Errorf("RFC2136 IP result is nil")
+	}
+
+	id, err := messageID()
+	if err != nil {
+		return err
+	}
+	builder := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, OpCode: opcodeUpdate})
+	if err := builder.StartQuestions(); err != nil {
+		return err
+	}
+	if err := builder.Question(dnsmessage.Question{Name: r.zone, Type: dnsmessage.TypeSOA, Class: dnsmessage.ClassINET}); err != nil {
+		return err
+	}
+	if err := builder.StartAuthorities(); err != nil {
+		return err
+	}
+
+	updated := make([]any, 0, 4)
+	if ips.IPv4 != "" {
+		addr, err := netip.ParseAddr(ips.IPv4)
+		if err != nil || !addr.Is4() {
+			return fmt.Errorf("invalid IPv4 address: %s", ips.IPv4)
+		}
+		if err := r.replaceRRset(&builder, dnsmessage.TypeA, func(header dnsmessage.ResourceHeader) error {
+			return builder.AResource(header, dnsmessage.AResource{A: addr.As4()})
+		}); err != nil {
+			return err
+		}
+		updated = append(updated, "ipv4", ips.IPv4)
+	}
+	if ips.IPv6 != "" {
+		addr, err := netip.ParseAddr(ips.IPv6)
+		if err != nil || !addr.Is6() || addr.Is4In6() {
+			return fmt.Errorf("invalid IPv6 address: %s", ips.IPv6)
+		}
+		if err := r.replaceRRset(&builder, dnsmessage.TypeAAAA, func(header dnsmessage.ResourceHeader) error {
+			return builder.AAAAResource(header, dnsmessage.AAAAResource{AAAA: addr.As16()})
+		}); err != nil {
+			return err
+		}
+		updated = append(updated, "ipv6", ips.IPv6)
+	}
+	if len(updated) == 0 {
+		return nil
+	}
+
+	msg, err := builder.Finish()
+	if err != nil {
+		return fmt.Errorf("failed to build RFC2136 update: %w", err)
+	}
+	header, _, err := r.exchange(ctx, msg)
+	if err != nil {
+		return fmt.Errorf("RFC2136 update failed: %w", err)
+	}
+	if header.RCode != dnsmessage.RCodeSuccess {
+		return fmt.Errorf("RFC2136 update rejected by %s: %s", r.server, rcodeName(header.RCode))
+	}
+
+	slog.Info("updated DNS record", append([]any{"updater", "rfc2136", "record", r.config.Domain, "zone", r.config.Zone}, updated...)...)
+	return nil
+}
+
+func (r *RFC2136) replaceRRset(builder *dnsmessage.Builder, recordType dnsmessage.Type, add func(dnsmessage.ResourceHeader) error) error {
+	// RFC 2136 section 2.5.2: class ANY with TTL 0 and no RDATA deletes an RRset.
+	if err := builder.UnknownResource(dnsmessage.ResourceHeader{Name: r.record, Class: dnsmessage.ClassANY}, dnsmessage.UnknownResource{Type: recordType}); err != nil {
+		return err
+	}
+	return add(dnsmessage.ResourceHeader{Name: r.record, Class: dnsmessage.ClassINET, TTL: r.ttl})
+}
+
+// Current queries the configured primary server directly, so the result is not
+// affected by resolver caching.
+func (r *RFC2136) Current(ctx context.Context, families provider.FamilyRequest) (*provider.IpResult, error) {
+	if !families.IPv4 && !families.IPv6 {
+		return nil, fmt.Errorf("no IP families requested")
+	}
+
+	result := &provider.IpResult{}
+	if families.IPv4 {
+		ipv4, err := r.currentRecord(ctx, dnsmessage.TypeA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get RFC2136 IPv4 record: %w", err)
+		}
+		result.IPv4 = ipv4
+	}
+	if families.IPv6 {
+		ipv6, err := r.currentRecord(ctx, dnsmessage.TypeAAAA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get RFC2136 IPv6 record: %w", err)
+		}
+		result.IPv6 = ipv6
+	}
+	return result, nil
+}
+
+func (r *RFC2136) currentRecord(ctx context.Context, recordType dnsmessage.Type) (string, error) {
+	id, err := messageID()
+	if err != nil {
+		return "", err
+	}
+	builder := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id})
+	if err := builder.StartQuestions(); err != nil {
+		return "", err
+	}
+	if err := builder.Question(dnsmessage.Question{Name: r.record, Type: recordType, Class: dnsmessage.ClassINET}); err != nil {
+		return "", err
+	}
+	msg, err := builder.Finish()
+	if err != nil {
+		return "", fmt.Errorf("failed to build DNS query: %w", err)
+	}
+
+	header, response, err := r.exchange(ctx, msg)
+	if err != nil {
+		return "", err
+	}
+	switch header.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return "", nil
+	default:
+		return "", fmt.Errorf("DNS query rejected by %s: %s", r.server, rcodeName(header.RCode))
+	}
+
+	var parser dnsmessage.Parser
+	if _, err := parser.Start(response); err != nil {
+		return "", fmt.Errorf("failed to parse DNS response: %w", err)
+	}
+	if err := parser.SkipAllQuestions(); err != nil {
+		return "", fmt.Errorf("failed to parse DNS response: %w", err)
+	}
+
+	var values []string
+	for {
+		answer, err := parser.AnswerHeader()
+		if errors.Is(err, dnsmessage.ErrSectionDone) {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to parse DNS response: %w", err)
+		}
+		if answer.Type != recordType || answer.Class != dnsmessage.ClassINET || !strings.EqualFold(answer.Name.String(), r.record.String()) {
+			if err := parser.SkipAnswer(); err != nil {
+				return "", fmt.Errorf("failed to parse DNS response: %w", err)
+			}
+			continue
+		}
+		switch recordType {
+		case dnsmessage.TypeA:
+			resource, err := parser.AResource()
+			if err != nil {
+				return "", fmt.Errorf("failed to parse DNS response: %w", err)
+			}
+			values = append(values, netip.AddrFrom4(resource.A).String())
+		case dnsmessage.TypeAAAA:
+			resource, err := parser.AAAAResource()
+			if err != nil {
+				return "", fmt.Errorf("failed to parse DNS response: %w", err)
+			}
+			values = append(values, netip.AddrFrom16(resource.AAAA).String())
+		}
+	}
+
+	if len(values) == 0 {
+		return "", nil
+	}
+	for _, value := range values[1:] {
+		if value != values[0] {
+			return "", nil
+		}
+	}
+	return values[0], nil
+}
+
+// exchange signs msg when a TSIG key is configured, sends it to the server,
+// and returns the verified response.
+func (r *RFC2136) exchange(ctx context.Context, msg []byte) (dnsmessage.Header, []byte, error) {
+	requestCtx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	var requestMAC []byte
+	if r.key != nil {
+		var err error
+		msg, requestMAC, err = r.key.sign(msg, r.now())
+		if err != nil {
+			return dnsmessage.Header{}, nil, err
+		}
+	}
+
+	response, err := r.roundTrip(requestCtx, r.transport, msg)
+	if err != nil {
+		return dnsmessage.Header{}, nil, err
+	}
+	var parser dnsmessage.Parser
+	header, err := parser.Start(response)
+	if err != nil {
+		return dnsmessage.Header{}, nil, fmt.Errorf("failed to parse DNS response: %w", err)
+	}
+	if header.Truncated && r.transport == "udp" {
+		slog.Debug("retrying truncated DNS response over TCP", "updater", "rfc2136", "server", r.server)
+		if response, err = r.roundTrip(requestCtx, "tcp", msg); err != nil {
+			return dnsmessage.Header{}, nil, err
+		}
+		if header, err = parser.Start(response); err != nil {
+			return dnsmessage.Header{}, nil, fmt.Errorf("failed to parse DNS response: %w", err)
+		}
+	}
+	if !header.Response || header.OpCode != dnsmessage.OpCode(binary.BigEndian.Uint16(msg[2:])>>11&0xF) {
+		return dnsmessage.Header{}, nil, fmt.Errorf("unexpected DNS response from %s", r.server)
+	}
+
+	if r.key != nil {
+		err := r.key.verify(response, requestMAC, r.now())
+		if errors.Is(err, errNoTSIG) && header.RCode != dnsmessage.RCodeSuccess {
+			// Servers may answer unsigned when they reject the request itself.
+			return header, response, nil
+		}
+		if err != nil {
+			return dnsmessage.Header{}, nil, err
+		}
+	}
+	return header, response, nil
+}
+
+func (r *RFC2136) roundTrip(ctx context.Context, network string, msg []byte) ([]byte, error) {
+	conn, err := r.dialer.DialContext(ctx, network, r.server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	response, err := r.transfer(conn, network, msg)
+	if err != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return response, err
+}
+
+func (r *RFC2136) transfer(conn net.Conn, network string, msg []byte) ([]byte, error) {
+	id := binary.BigEndian.Uint16(msg)
+	if network == "tcp" {
+		framed := binary.BigEndian.AppendUint16(make([]byte, 0, len(msg)+2), uint16(len(msg)))
+		if _, err := conn.Write(append(framed, msg...)); err != nil {
+			return nil, err
+		}
+		var length [2]byte
+		if _, err := io.ReadFull(conn, length[:]); err != nil {
+			return nil, err
+		}
+		response := make([]byte, binary.BigEndian.Uint16(length[:]))
+		if _, err := io.ReadFull(conn, response); err != nil {
+			return nil, err
+		}
+		if len(response) < headerLen || binary.BigEndian.Uint16(response) != id {
+			return nil, fmt.Errorf("unexpected DNS response from %s", r.server)
+		}
+		return response, nil
+	}
+
+	if _, err := conn.Write(msg); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		// Ignore stray datagrams that do not answer this request.
+		if n >= headerLen && binary.BigEndian.Uint16(buf) == id {
+			return append([]byte(nil), buf[:n]...), nil
+		}
+	}
+}
+
+func messageID() (uint16, error) {
+	var id [2]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return 0, fmt.Errorf("failed to generate DNS message ID: %w", err)
+	}
+	return binary.BigEndian.Uint16(id[:]), nil
+}
+
+func rcodeName(code dnsmessage.RCode) string {
+	switch code {
+	case dnsmessage.RCodeFormatError:
+		return "FORMERR"
+	case dnsmessage.RCodeServerFailure:
+		return "SERVFAIL"
+	case dnsmessage.RCodeNameError:
+		return "NXDOMAIN"
+	case dnsmessage.RCodeNotImplemented:
+		return "NOTIMP"
+	case dnsmessage.RCodeRefused:
+		return "REFUSED"
+	case 6:
+		return "YXDOMAIN"
+	case 7:
+		return "YXRRSET"
+	case 8:
+		return "NXRRSET"
+	case 9:
+		return "NOTAUTH"
+	case 10:
+		return "NOTZONE"
+	default:
+		return fmt.Sprintf("RCODE %d", code)
+	}
+}
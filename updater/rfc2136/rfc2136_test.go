@@ -0,0 +1,434 @@
+package rfc2136
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/we11adam/uddns/provider"
+)
+
+const testSecret = "c2VjcmV0LWtleS1mb3ItdWRkbnMtdGVzdHM="
+
+type testServer struct {
+	t           *testing.T
+	key         *tsigKey
+	responseKey *tsigKey
+	udp         net.PacketConn
+	tcp         net.Listener
+
+	mu          sync.Mutex
+	records     map[dnsmessage.Type][]string
+	updates     int
+	tcpRequests int
+	rcode       dnsmessage.RCode
+	truncateUDP bool
+}
+
+func newTestServer(t *testing.T, key *tsigKey) *testServer {
+	t.Helper()
+
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen tcp: %v", err)
+	}
+	udp, err := net.ListenPacket("udp", tcp.Addr().String())
+	if err != nil {
+		_ = tcp.Close()
+		t.Fatalf("listen udp: %v", err)
+	}
+	server := &testServer{
+		t:           t,
+		key:         key,
+		responseKey: key,
+		udp:         udp,
+		tcp:         tcp,
+		records:     map[dnsmessage.Type][]string{},
+	}
+	t.Cleanup(func() {
+		_ = udp.Close()
+		_ = tcp.Close()
+	})
+	go server.serveUDP()
+	go server.serveTCP()
+	return server
+}
+
+// locked runs fn while holding the server lock, because the race detector does
+// not see the ordering provided by the DNS round trips.
+func (s *testServer) locked(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn()
+}
+
+func (s *testServer) addr() string {
+	return s.tcp.Addr().String()
+}
+
+func (s *testServer) serveUDP() {
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, addr, err := s.udp.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		truncate := s.truncateUDP
+		s.mu.Unlock()
+		response := s.handle(append([]byte(nil), buf[:n]...), truncate)
+		_, _ = s.udp.WriteTo(response, addr)
+	}
+}
+
+func (s *testServer) serveTCP() {
+	for {
+		conn, err := s.tcp.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			var length [2]byte
+			if _, err := io.ReadFull(conn, length[:]); err != nil {
+				return
+			}
+			msg := make([]byte, binary.BigEndian.Uint16(length[:]))
+			if _, err := io.ReadFull(conn, msg); err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.tcpRequests++
+			s.mu.Unlock()
+			response := s.handle(msg, false)
+			framed := binary.BigEndian.AppendUint16(nil, uint16(len(response)))
+			_, _ = conn.Write(append(framed, response...))
+		}()
+	}
+}
+
+func (s *testServer) handle(msg []byte, truncate bool) []byte {
+	var requestMAC []byte
+	if s.key != nil {
+		start, record, err := lastTSIG(msg)
+		if err != nil {
+			s.t.Errorf("request is not signed: %v", err)
+			return nil
+		}
+		unsigned := append([]byte(nil), msg[:start]...)
+		binary.BigEndian.PutUint16(unsigned[10:], binary.BigEndian.Uint16(unsigned[10:])-1)
+		if record.name != s.key.name || record.algorithm != s.key.algorithm {
+			s.t.Errorf("unexpected TSIG key %q %q", record.name, record.algorithm)
+		}
+		if string(s.key.mac(nil, unsigned, record)) != string(record.mac) {
+			s.t.Errorf("request TSIG signature is invalid")
+		}
+		requestMAC = record.mac
+		msg = unsigned
+	}
+
+	var parser dnsmessage.Parser
+	header, err := parser.Start(msg)
+	if err != nil {
+		s.t.Errorf("parse request: %v", err)
+		return nil
+	}
+	question, err := parser.Question()
+	if err != nil {
+		s.t.Errorf("parse question: %v", err)
+		return nil
+	}
+	if err := parser.SkipAllQuestions(); err != nil {
+		s.t.Errorf("skip questions: %v", err)
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	response := dnsmessage.Message{
+		Header: dnsmessage.Header{
+			ID:            header.ID,
+			Response:      true,
+			OpCode:        header.OpCode,
+			Authoritative: true,
+			Truncated:     truncate,
+			RCode:         s.rcode,
+		},
+		Questions: []dnsmessage.Question{question},
+	}
+	if s.rcode == dnsmessage.RCodeSuccess && !truncate {
+		if header.OpCode == opcodeUpdate {
+			s.applyUpdate(&parser)
+		} else {
+			for _, value := range s.records[question.Type] {
+				addr := netip.MustParseAddr(value)
+				resourceHeader := dnsmessage.ResourceHeader{Name: question.Name, Class: dnsmessage.ClassINET, TTL: 60}
+				var body dnsmessage.ResourceBody
+				if addr.Is4() {
+					body = &dnsmessage.AResource{A: addr.As4()}
+				} else {
+					body = &dnsmessage.AAAAResource{AAAA: addr.As16()}
+				}
+				response.Answers = append(response.Answers, dnsmessage.Resource{Header: resourceHeader, Body: body})
+			}
+		}
+	}
+
+	packed, err := response.Pack()
+	if err != nil {
+		s.t.Errorf("pack response: %v", err)
+		return nil
+	}
+	if s.responseKey != nil {
+		packed = signResponse(s.t, s.responseKey, packed, requestMAC, time.Now())
+	}
+	return packed
+}
+
+func (s *testServer) applyUpdate(parser *dnsmessage.Parser) {
+	if err := parser.SkipAllAnswers(); err != nil {
+		s.t.Errorf("skip prerequisites: %v", err)
+		return
+	}
+	s.updates++
+	for {
+		header, err := parser.AuthorityHeader()
+		if errors.Is(err, dnsmessage.ErrSectionDone) {
+			return
+		}
+		if err != nil {
+			s.t.Errorf("parse update: %v", err)
+			return
+		}
+		switch {
+		case header.Class == dnsmessage.ClassANY:
+			delete(s.records, header.Type)
+			_ = parser.SkipAuthority()
+		case header.Type == dnsmessage.TypeA:
+			resource, _ := parser.AResource()
+			s.records[header.Type] = append(s.records[header.Type], netip.AddrFrom4(resource.A).String())
+		case header.Type == dnsmessage.TypeAAAA:
+			resource, _ := parser.AAAAResource()
+			s.records[header.Type] = append(s.records[header.Type], netip.AddrFrom16(resource.AAAA).String())
+		default:
+			_ = parser.SkipAuthority()
+		}
+	}
+}
+
+func signResponse(t *testing.T, key *tsigKey, msg, requestMAC []byte, now time.Time) []byte {
+	t.Helper()
+	record := tsigRecord{
+		name:       key.name,
+		algorithm:  key.algorithm,
+		timeSigned: uint64(now.Unix()),
+		fudge:      tsigFudge,
+		originalID: binary.BigEndian.Uint16(msg),
+	}
+	record.mac = key.mac(requestMAC, msg, record)
+	signed, err := appendTSIG(append([]byte(nil), msg...), record)
+	if err != nil {
+		t.Fatalf("sign response: %v", err)
+	}
+	binary.BigEndian.PutUint16(signed[10:], binary.BigEndian.Uint16(signed[10:])+1)
+	return signed
+}
+
+func mustNewTSIGKey(t *testing.T, algorithm, secret string) *tsigKey {
+	t.Helper()
+	key, err := newTSIGKey("uddns-key", algorithm, secret)
+	if err != nil {
+		t.Fatalf("newTSIGKey returned error: %v", err)
+	}
+	return key
+}
+
+func mustNewRFC2136(t *testing.T, cfg *Config) *RFC2136 {
+	t.Helper()
+	updater, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	return updater
+}
+
+func TestUpdateReplacesRecordsWithTSIG(t *testing.T) {
+	for _, tt := range []struct {
+		algorithm string
+		transport string
+	}{
+		{algorithm: "hmac-sha256", transport: "udp"},
+		{algorithm: "hmac-sha512", transport: "tcp"},
+	} {
+		t.Run(tt.algorithm+"/"+tt.transport, func(t *testing.T) {
+			server := newTestServer(t, mustNewTSIGKey(t, tt.algorithm, testSecret))
+			server.locked(func() { server.records[dnsmessage.TypeA] = []string{"198.51.100.1", "198.51.100.2"} })
+			updater := mustNewRFC2136(t, &Config{
+				Server:        server.addr(),
+				Transport:     tt.transport,
+				Domain:        "home.example.com",
+				Zone:          "example.com",
+				TSIGKey:       "uddns-key.",
+				TSIGAlgorithm: tt.algorithm,
+				TSIGSecret:    testSecret,
+			})
+
+			current, err := updater.Current(context.Background(), provider.FamilyRequest{IPv4: true, IPv6: true})
+			if err != nil {
+				t.Fatalf("Current returned error: %v", err)
+			}
+			if current.IPv4 != "" || current.IPv6 != "" {
+				t.Fatalf("expected conflicting and missing records to read as empty, got %#v", current)
+			}
+
+			if err := updater.Update(context.Background(), &provider.IpResult{IPv4: "192.0.2.10", IPv6: "2001:db8::10"}); err != nil {
+				t.Fatalf("Update returned error: %v", err)
+			}
+			current, err = updater.Current(context.Background(), provider.FamilyRequest{IPv4: true, IPv6: true})
+			if err != nil {
+				t.Fatalf("Current returned error: %v", err)
+			}
+			if current.IPv4 != "192.0.2.10" || current.IPv6 != "2001:db8::10" {
+				t.Fatalf("unexpected current records: %#v", current)
+			}
+			server.locked(func() {
+				if server.updates != 1 {
+					t.Fatalf("expected one UPDATE message, got %d", server.updates)
+				}
+			})
+		})
+	}
+}
+
+func TestUpdateWithoutTSIG(t *testing.T) {
+	server := newTestServer(t, nil)
+	updater := mustNewRFC2136(t, &Config{Server: server.addr(), Domain: "home.example.com"})
+
+	if err := updater.Update(context.Background(), &provider.IpResult{IPv4: "192.0.2.10"}); err != nil {
+		t.Fatalf("Update returned error: %v", err)
+	}
+	server.locked(func() {
+		if got := server.records[dnsmessage.TypeA]; len(got) != 1 || got[0] != "192.0.2.10" {
+			t.Fatalf("unexpected A records: %v", got)
+		}
+		if _, ok := server.records[dnsmessage.TypeAAAA]; ok {
+			t.Fatal("expected IPv4-only update not to touch AAAA records")
+		}
+	})
+}
+
+func TestUpdateRetriesTruncatedUDPResponseOverTCP(t *testing.T) {
+	server := newTestServer(t, mustNewTSIGKey(t, "", testSecret))
+	server.locked(func() { server.truncateUDP = true })
+	updater := mustNewRFC2136(t, &Config{Server: server.addr(), Domain: "home.example.com", TSIGKey: "uddns-key", TSIGSecret: testSecret})
+
+	if err := updater.Update(context.Background(), &provider.IpResult{IPv4: "192.0.2.10"}); err != nil {
+		t.Fatalf("Update returned error: %v", err)
+	}
+	server.locked(func() {
+		if server.tcpRequests != 1 || server.updates != 1 {
+			t.Fatalf("expected one TCP retry applying the update, got tcp=%d updates=%d", server.tcpRequests, server.updates)
+		}
+	})
+}
+
+func TestUpdateReportsRejectedUpdate(t *testing.T) {
+	server := newTestServer(t, mustNewTSIGKey(t, "", testSecret))
+	server.locked(func() { server.rcode = dnsmessage.RCode(9) })
+	updater := mustNewRFC2136(t, &Config{Server: server.addr(), Domain: "home.example.com", TSIGKey: "uddns-key", TSIGSecret: testSecret})
+
+	err := updater.Update(context.Background(), &provider.IpResult{IPv4: "192.0.2.10"})
+	if err == nil || !strings.Contains(err.Error(), "NOTAUTH") {
+		t.Fatalf("expected NOTAUTH error, got %v", err)
+	}
+}
+
+func TestUpdateRejectsInvalidResponseSignature(t *testing.T) {
+	server := newTestServer(t, mustNewTSIGKey(t, "", testSecret))
+	otherKey := mustNewTSIGKey(t, "", "b3RoZXItc2VjcmV0")
+	server.locked(func() { server.responseKey = otherKey })
+	updater := mustNewRFC2136(t, &Config{Server: server.addr(), Domain: "home.example.com", TSIGKey: "uddns-key", TSIGSecret: testSecret})
+
+	err := updater.Update(context.Background(), &provider.IpResult{IPv4: "192.0.2.10"})
+	if err == nil || !strings.Contains(err.Error(), "signature is invalid") {
+		t.Fatalf("expected invalid signature error, got %v", err)
+	}
+}
+
+func TestUpdateRejectsUnsignedSuccessfulResponse(t *testing.T) {
+	server := newTestServer(t, mustNewTSIGKey(t, "", testSecret))
+	server.locked(func() { server.responseKey = nil })
+	updater := mustNewRFC2136(t, &Config{Server: server.addr(), Domain: "home.example.com", TSIGKey: "uddns-key", TSIGSecret: testSecret})
+
+	if err := updater.Update(context.Background(), &provider.IpResult{IPv4: "192.0.2.10"}); !errors.Is(err, errNoTSIG) {
+		t.Fatalf("expected unsigned response error, got %v", err)
+	}
+}
+
+func TestVerifyRejectsStaleResponse(t *testing.T) {
+	key := mustNewTSIGKey(t, "", testSecret)
+	_, requestMAC, err := key.sign(make([]byte, headerLen), time.Now())
+	if err != nil {
+		t.Fatalf("sign returned error: %v", err)
+	}
+	response := signResponse(t, key, make([]byte, headerLen), requestMAC, time.Now().Add(-time.Hour))
+
+	if err := key.verify(response, requestMAC, time.Now()); err == nil || !strings.Contains(err.Error(), "time") {
+		t.Fatalf("expected stale response error, got %v", err)
+	}
+}
+
+func TestNewValidatesConfig(t *testing.T) {
+	negativeTTL := -1
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{name: "missing server", cfg: Config{Domain: "home.example.com"}},
+		{name: "missing domain", cfg: Config{Server: "192.0.2.53"}},
+		{name: "invalid domain", cfg: Config{Server: "192.0.2.53", Domain: "home_example.com"}},
+		{name: "record outside zone", cfg: Config{Server: "192.0.2.53", Domain: "home.example.com", Zone: "example.net"}},
+		{name: "transport", cfg: Config{Server: "192.0.2.53", Domain: "home.example.com", Transport: "tls"}},
+		{name: "ttl", cfg: Config{Server: "192.0.2.53", Domain: "home.example.com", TTL: &negativeTTL}},
+		{name: "key without secret", cfg: Config{Server: "192.0.2.53", Domain: "home.example.com", TSIGKey: "uddns-key"}},
+		{name: "secret encoding", cfg: Config{Server: "192.0.2.53", Domain: "home.example.com", TSIGKey: "uddns-key", TSIGSecret: "not base64!"}},
+		{name: "algorithm", cfg: Config{Server: "192.0.2.53", Domain: "home.example.com", TSIGKey: "uddns-key", TSIGSecret: testSecret, TSIGAlgorithm: "hmac-md5"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(&tt.cfg); err == nil {
+				t.Fatal("expected config error")
+			}
+		})
+	}
+}
+
+func TestServerAddressDefaultsPort(t *testing.T) {
+	tests := map[string]string{
+		"ns1.example.com":      "ns1.example.com:53",
+		"ns1.example.com:5353": "ns1.example.com:5353",
+		"192.0.2.53":           "192.0.2.53:53",
+		"2001:db8::53":         "[2001:db8::53]:53",
+		"[2001:db8::53]:5353":  "[2001:db8::53]:5353",
+	}
+	for input, want := range tests {
+		got, err := serverAddress(input)
+		if err != nil {
+			t.Fatalf("serverAddress(%q) returned error: %v", input, err)
+		}
+		if got != want {
+			t.Fatalf("serverAddress(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
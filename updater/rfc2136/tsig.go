@@ -0,0 +1,365 @@
+package rfc2136
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+	"time"
+)
+
+const (
+	typeTSIG  = 250
+	classANY  = 255
+	tsigFudge = 300
+
+	headerLen = 12
+)
+
+var (
+	tsigAlgorithms = map[string]func() hash.Hash{
+		"hmac-sha256": sha256.New,
+		"hmac-sha512": sha512.New,
+	}
+
+	errNoTSIG = errors.New("message is not TSIG signed")
+)
+
+type tsigKey struct {
+	name      string
+	algorithm string
+	hash      func() hash.Hash
+	secret    []byte
+}
+
+type tsigRecord struct {
+	name       string
+	algorithm  string
+	timeSigned uint64
+	fudge      uint16
+	mac        []byte
+	originalID uint16
+	err        uint16
+	other      []byte
+}
+
+func newTSIGKey(name, algorithm, secret string) (*tsigKey, error) {
+	name, err := canonicalName(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TSIG key name: %w", err)
+	}
+	algorithm = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(algorithm), "."))
+	if algorithm == "" {
+		algorithm = "hmac-sha256"
+	}
+	newHash, ok := tsigAlgorithms[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("unsupported TSIG algorithm %q; supported algorithms: hmac-sha256, hmac-sha512", algorithm)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(secret))
+	if err != nil {
+		return nil, fmt.Errorf("TSIG secret must be base64 encoded")
+	}
+	if len(decoded) == 0 {
+		return nil, fmt.Errorf("TSIG secret is empty")
+	}
+	return &tsigKey{
+		name:      name,
+		algorithm: algorithm + ".",
+		hash:      newHash,
+		secret:    decoded,
+	}, nil
+}
+
+// sign appends a TSIG record to an unsigned message and returns the signed
+// message together with its MAC, which is needed to verify the response.
+func (k *tsigKey) sign(msg []byte, now time.Time) ([]byte, []byte, error) {
+	if len(msg) < headerLen {
+		return nil, nil, fmt.Errorf("DNS message is too short to sign")
+	}
+	record := tsigRecord{
+		name:       k.name,
+		algorithm:  k.algorithm,
+		timeSigned: uint64(now.Unix()),
+		fudge:      tsigFudge,
+		originalID: binary.BigEndian.Uint16(msg),
+	}
+	record.mac = k.mac(nil, msg, record)
+
+	signed := append([]byte(nil), msg...)
+	signed, err := appendTSIG(signed, record)
+	if err != nil {
+		return nil, nil, err
+	}
+	arcount := binary.BigEndian.Uint16(signed[10:])
+	binary.BigEndian.PutUint16(signed[10:], arcount+1)
+	return signed, record.mac, nil
+}
+
+// verify checks the TSIG record of a response to a request signed with
+// requestMAC, as described in RFC 8945 section 5.3.
+func (k *tsigKey) verify(msg, requestMAC []byte, now time.Time) error {
+	start, record, err := lastTSIG(msg)
+	if err != nil {
+		return err
+	}
+	if record.name != k.name || record.algorithm != k.algorithm {
+		return fmt.Errorf("response is signed with unexpected TSIG key %q (%s)", record.name, strings.TrimSuffix(record.algorithm, "."))
+	}
+	if record.err != 0 {
+		return fmt.Errorf("server rejected TSIG: %s", tsigErrorName(record.err))
+	}
+
+	unsigned := append([]byte(nil), msg[:start]...)
+	binary.BigEndian.PutUint16(unsigned, record.originalID)
+	arcount := binary.BigEndian.Uint16(unsigned[10:])
+	binary.BigEndian.PutUint16(unsigned[10:], arcount-1)
+	if !hmac.Equal(k.mac(requestMAC, unsigned, record), record.mac) {
+		return fmt.Errorf("response TSIG signature is invalid")
+	}
+
+	signedAt := int64(record.timeSigned)
+	if delta := now.Unix() - signedAt; delta > int64(record.fudge) || -delta > int64(record.fudge) {
+		return fmt.Errorf("response TSIG time is outside the allowed %ds window", record.fudge)
+	}
+	return nil
+}
+
+func (k *tsigKey) mac(requestMAC, msg []byte, record tsigRecord) []byte {
+	h := hmac.New(k.hash, k.secret)
+	if requestMAC != nil {
+		_ = binary.Write(h, binary.BigEndian, uint16(len(requestMAC)))
+		h.Write(requestMAC)
+	}
+	h.Write(msg)
+
+	variables, _ := appendName(nil, record.name)
+	variables = binary.BigEndian.AppendUint16(variables, classANY)
+	variables = binary.BigEndian.AppendUint32(variables, 0)
+	variables, _ = appendName(variables, record.algorithm)
+	variables = appendUint48(variables, record.timeSigned)
+	variables = binary.BigEndian.AppendUint16(variables, record.fudge)
+	variables = binary.BigEndian.AppendUint16(variables, record.err)
+	variables = binary.BigEndian.AppendUint16(variables, uint16(len(record.other)))
+	variables = append(variables, record.other...)
+	h.Write(variables)
+	return h.Sum(nil)
+}
+
+func appendTSIG(msg []byte, record tsigRecord) ([]byte, error) {
+	msg, err := appendName(msg, record.name)
+	if err != nil {
+		return nil, err
+	}
+	msg = binary.BigEndian.AppendUint16(msg, typeTSIG)
+	msg = binary.BigEndian.AppendUint16(msg, classANY)
+	msg = binary.BigEndian.AppendUint32(msg, 0)
+
+	rdata, err := appendName(nil, record.algorithm)
+	if err != nil {
+		return nil, err
+	}
+	rdata = appendUint48(rdata, record.timeSigned)
+	rdata = binary.BigEndian.AppendUint16(rdata, record.fudge)
+	rdata = binary.BigEndian.AppendUint16(rdata, uint16(len(record.mac)))
+	rdata = append(rdata, record.mac...)
+	rdata = binary.BigEndian.AppendUint16(rdata, record.originalID)
+	rdata = binary.BigEndian.AppendUint16(rdata, record.err)
+	rdata = binary.BigEndian.AppendUint16(rdata, uint16(len(record.other)))
+	rdata = append(rdata, record.other...)
+
+	msg = binary.BigEndian.AppendUint16(msg, uint16(len(rdata)))
+	return append(msg, rdata...), nil
+}
+
+// lastTSIG returns the offset and contents of the TSIG record, which RFC 8945
+// requires to be the last record in the additional section.
+func lastTSIG(msg []byte) (int, tsigRecord, error) {
+	if len(msg) < headerLen {
+		return 0, tsigRecord{}, fmt.Errorf("DNS message is too short")
+	}
+	counts := [4]int{}
+	for i := range counts {
+		counts[i] = int(binary.BigEndian.Uint16(msg[4+2*i:]))
+	}
+	if counts[3] == 0 {
+		return 0, tsigRecord{}, errNoTSIG
+	}
+
+	off := headerLen
+	var err error
+	for range counts[0] {
+		if _, off, err = readName(msg, off); err != nil {
+			return 0, tsigRecord{}, err
+		}
+		off += 4
+	}
+	for range counts[1] + counts[2] + counts[3] - 1 {
+		if off, err = skipRecord(msg, off); err != nil {
+			return 0, tsigRecord{}, err
+		}
+	}
+
+	start := off
+	name, off, err := readName(msg, off)
+	if err != nil {
+		return 0, tsigRecord{}, err
+	}
+	if off+10 > len(msg) {
+		return 0, tsigRecord{}, fmt.Errorf("truncated DNS record")
+	}
+	if binary.BigEndian.Uint16(msg[off:]) != typeTSIG {
+		return 0, tsigRecord{}, errNoTSIG
+	}
+	rdlength := int(binary.BigEndian.Uint16(msg[off+8:]))
+	off += 10
+	end := off + rdlength
+	if end != len(msg) {
+		return 0, tsigRecord{}, fmt.Errorf("malformed TSIG record")
+	}
+
+	record := tsigRecord{name: name}
+	if record.algorithm, off, err = readName(msg, off); err != nil {
+		return 0, tsigRecord{}, err
+	}
+	if off+10 > end {
+		return 0, tsigRecord{}, fmt.Errorf("malformed TSIG record")
+	}
+	record.timeSigned = uint64(binary.BigEndian.Uint16(msg[off:]))<<32 | uint64(binary.BigEndian.Uint32(msg[off+2:]))
+	record.fudge = binary.BigEndian.Uint16(msg[off+6:])
+	macSize := int(binary.BigEndian.Uint16(msg[off+8:]))
+	off += 10
+	if off+macSize+6 > end {
+		return 0, tsigRecord{}, fmt.Errorf("malformed TSIG record")
+	}
+	record.mac = append([]byte(nil), msg[off:off+macSize]...)
+	off += macSize
+	record.originalID = binary.BigEndian.Uint16(msg[off:])
+	record.err = binary.BigEndian.Uint16(msg[off+2:])
+	otherLen := int(binary.BigEndian.Uint16(msg[off+4:]))
+	off += 6
+	if off+otherLen != end {
+		return 0, tsigRecord{}, fmt.Errorf("malformed TSIG record")
+	}
+	record.other = append([]byte(nil), msg[off:end]...)
+	return start, record, nil
+}
+
+func skipRecord(msg []byte, off int) (int, error) {
+	_, off, err := readName(msg, off)
+	if err != nil {
+		return 0, err
+	}
+	if off+10 > len(msg) {
+		return 0, fmt.Errorf("truncated DNS record")
+	}
+	off += 10 + int(binary.BigEndian.Uint16(msg[off+8:]))
+	if off > len(msg) {
+		return 0, fmt.Errorf("truncated DNS record")
+	}
+	return off, nil
+}
+
+// readName decodes a possibly compressed domain name into its lower-case
+// presentation form with a trailing dot.
+func readName(msg []byte, off int) (string, int, error) {
+	var builder strings.Builder
+	next := -1
+	for jumps := 0; ; {
+		if off >= len(msg) {
+			return "", 0, fmt.Errorf("truncated DNS name")
+		}
+		length := int(msg[off])
+		switch {
+		case length == 0:
+			off++
+			if next < 0 {
+				next = off
+			}
+			if builder.Len() == 0 {
+				return ".", next, nil
+			}
+			return strings.ToLower(builder.String()), next, nil
+		case length&0xC0 == 0xC0:
+			if off+1 >= len(msg) {
+				return "", 0, fmt.Errorf("truncated DNS name pointer")
+			}
+			if jumps++; jumps > 32 {
+				return "", 0, fmt.Errorf("too many DNS name compression pointers")
+			}
+			if next < 0 {
+				next = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3FFF)
+		case length&0xC0 != 0:
+			return "", 0, fmt.Errorf("unsupported DNS label type")
+		default:
+			off++
+			if off+length > len(msg) {
+				return "", 0, fmt.Errorf("truncated DNS label")
+			}
+			builder.Write(msg[off : off+length])
+			builder.WriteByte('.')
+			off += length
+		}
+	}
+}
+
+// canonicalName validates a domain name and returns its lower-case form with a
+// trailing dot. TSIG key names are not restricted to host name syntax.
+func canonicalName(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+	if name == "" {
+		return "", fmt.Errorf("name is empty")
+	}
+	if _, err := appendName(nil, name+"."); err != nil {
+		return "", err
+	}
+	return name + ".", nil
+}
+
+func appendName(msg []byte, name string) ([]byte, error) {
+	name = strings.TrimSuffix(name, ".")
+	if name == "" {
+		return append(msg, 0), nil
+	}
+	if len(name) > 253 {
+		return nil, fmt.Errorf("name %q is too long", name)
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 {
+			return nil, fmt.Errorf("name %q has an invalid label", name)
+		}
+		if strings.ContainsFunc(label, func(r rune) bool { return r <= ' ' || r > '~' }) {
+			return nil, fmt.Errorf("name %q contains unsupported characters", name)
+		}
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, strings.ToLower(label)...)
+	}
+	return append(msg, 0), nil
+}
+
+func appendUint48(b []byte, v uint64) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(v>>32))
+	return binary.BigEndian.AppendUint32(b, uint32(v))
+}
+
+func tsigErrorName(code uint16) string {
+	switch code {
+	case 16:
+		return "BADSIG"
+	case 17:
+		return "BADKEY"
+	case 18:
+		return "BADTIME"
+	case 22:
+		return "BADTRUNC"
+	default:
+		return fmt.Sprintf("error %d", code)
+	}
+}
@@ -31,9 +31,9 @@ func GetUpdater(config ConfigReader) (string, Updater, error) {
 	return updaters.Get(config)
 }
 
-// ConfigKey returns the registered configuration root for an updater selector.
-// Updater job records use the common "domain" and optional "zone" fields below
-// this root.
-func ConfigKey(selector string) (string, bool) {
-	return updaters.ConfigKey(selector)
+// ConfigKey returns the configuration root for an updater selector, which is
+// the instance section for a named instance. Updater job records use the
+// common "domain" and optional "zone" fields below this root.
+func ConfigKey(config ConfigReader, selector string) (string, error) {
+	return updaters.ConfigKey(config, selector)
 }